@@ -1,17 +1,55 @@
 package config
 
-import "gopkg.in/ini.v1"
+import (
+	"gopkg.in/ini.v1"
+	"time"
+)
 
 type Config struct {
 	Bot struct {
 		Token    string `ini:"token_first"`
 		TokenTwo string `ini:"token_second"`
+		// Секрет подписи callback data; пустой - подпись выключена
+		CallbackSecret string        `ini:"callback_secret"`
+		CallbackTTL    time.Duration `ini:"callback_ttl"`
+		// Telegram ID владельцев админ-бота через запятую, роль не отзывается командами
+		Owners []int64 `ini:"owners" delim:","`
+		// часовой пояс времени запланированных рассылок, если он не указан явно
+		TimeZone string `ini:"timezone"`
+		// каталог файлов рассылок; в отличие от временного каталога должен переживать
+		// перезапуск: по расписанию файлы отправляются через дни и недели
+		FilesDir string `ini:"files_dir"`
 	} `ini:"bot"`
+	Webhook struct {
+		// polling или webhook; обработка обновлений в обоих режимах одинаковая
+		Mode string `ini:"mode"`
+		// адрес, на котором слушает сервер, например :8443
+		Listen string `ini:"listen"`
+		// публичный адрес сервера; бот добавляет к нему свой путь
+		URL    string `ini:"url"`
+		Secret string `ini:"secret"`
+		// TLS на стороне сервера; пусто - TLS завершает прокси
+		CertFile string `ini:"cert_file"`
+		KeyFile  string `ini:"key_file"`
+		// самоподписанный сертификат для setWebhook
+		PublicCert string `ini:"public_cert"`
+		// пути ботов
+		UserPath  string `ini:"user_path"`
+		AdminPath string `ini:"admin_path"`
+	} `ini:"webhook"`
 	Redis struct {
 		Addr     string `ini:"addr"`
 		Username string `ini:"username"`
 		Password string `ini:"password"`
 	} `ini:"redis"`
+	Leader struct {
+		// выбор ведущей реплики через Redis: обновления получает и задачи выполняет
+		// только ведущая; выключен - реплика одна
+		Enabled bool   `ini:"enabled"`
+		Key     string `ini:"key"`
+		// срок аренды: столько реплики остаются без ведущего, если он упал
+		TTL time.Duration `ini:"ttl"`
+	} `ini:"leader"`
 }
 
 func ReadFromFile[config any](fileName string) (*config, error) {
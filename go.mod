@@ -4,11 +4,14 @@ go 1.25.1
 
 require (
 	github.com/and3rson/telemux/v2 v2.0.2
+	github.com/go-pdf/fpdf v0.9.0
 	github.com/go-redis/redis v6.15.9+incompatible
 	github.com/go-telegram-bot-api/telegram-bot-api/v5 v5.5.1
-	github.com/liyue201/goqr v0.0.0-20200803022322-df443203d4ea
+	github.com/ledongthuc/pdf v0.0.0-20260907135840-6c8c28e0e8a0
+	github.com/makiuchi-d/gozxing v0.1.1
 	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
 	github.com/xuri/excelize/v2 v2.10.0
+	golang.org/x/image v0.32.0
 	golang.org/x/text v0.30.0
 	gopkg.in/ini.v1 v1.67.0
 )
@@ -24,4 +27,5 @@ require (
 	github.com/xuri/nfp v0.0.2-0.20250530014748-2ddeb826f9a9 // indirect
 	golang.org/x/crypto v0.43.0 // indirect
 	golang.org/x/net v0.46.0 // indirect
+	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 )
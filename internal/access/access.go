@@ -0,0 +1,271 @@
+package access
+
+import (
+	"errors"
+	"fmt"
+	"github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log/slog"
+	"main/internal/database/entitybase"
+	"main/internal/entity"
+	"main/internal/telegram"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Действия в журнале
+const (
+	AuditGrant  = "grant"
+	AuditRevoke = "revoke"
+	AuditDenied = "denied"
+)
+
+var (
+	ErrUnknownRole  = errors.New("неизвестная роль")
+	ErrBadTarget    = errors.New("укажите Telegram ID или @username")
+	ErrNotStaff     = errors.New("у пользователя нет роли")
+	ErrConfigOwner  = errors.New("владельца из конфигурации нельзя изменить командой")
+	ErrSelfDemotion = errors.New("нельзя изменить собственную роль")
+)
+
+// Roles - роли сотрудников админ-бота и журнал доступа.
+// Владельцы из конфигурации есть всегда, остальные роли выдаются командами
+type Roles struct {
+	staff  entitybase.EntityBase[entity.Staff]
+	audit  entitybase.EntityBase[entity.AuditEntry]
+	owners map[int64]bool
+	mutex  sync.Mutex
+}
+
+func InitRoles(
+	staff entitybase.EntityBase[entity.Staff],
+	audit entitybase.EntityBase[entity.AuditEntry],
+	owners ...int64) *Roles {
+	roles := &Roles{staff: staff, audit: audit, owners: make(map[int64]bool, len(owners))}
+	for _, owner := range owners {
+		roles.owners[owner] = true
+	}
+	return roles
+}
+
+// ParseRole - роль по имени: owner, admin, moderator, support, analyst
+func ParseRole(text string) (entity.Role, error) {
+	role := entity.Role(strings.ToLower(strings.TrimSpace(text)))
+	if role.Rank() == 0 {
+		return "", fmt.Errorf("%w: %s", ErrUnknownRole, text)
+	}
+	return role, nil
+}
+
+// ParseTarget - сотрудник по Telegram ID или @username
+func ParseTarget(text string) (telegramID int64, userName string, err error) {
+	text = strings.TrimSpace(text)
+	if id, err := strconv.ParseInt(text, 10, 64); err == nil && id > 0 {
+		return id, "", nil
+	}
+	userName = strings.TrimPrefix(text, "@")
+	if len(userName) == 0 || strings.ContainsAny(userName, " @") {
+		return 0, "", ErrBadTarget
+	}
+	return 0, userName, nil
+}
+
+// RoleOf - роль пользователя, пустая если роли нет. Роль, выданная по username,
+// при первом обращении привязывается к Telegram ID
+func (r *Roles) RoleOf(telegramID int64, userName string) (entity.Role, error) {
+	if r.owners[telegramID] {
+		return entity.RoleOwner, nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	staff, found, err := r.find(telegramID, userName, false)
+	if err != nil || !found {
+		return "", err
+	}
+	if staff.TelegramID != telegramID || staff.UserName != userName {
+		staff.TelegramID, staff.UserName = telegramID, userName
+		if err := r.staff.Update(staff); err != nil {
+			return "", err
+		}
+	}
+	return staff.Role, nil
+}
+
+// find - сотрудник по Telegram ID, затем по username. Без bound username ищется
+// только среди еще не привязанных к Telegram ID: username можно сменить и занять
+func (r *Roles) find(telegramID int64, userName string, bound bool) (entity.Staff, bool, error) {
+	all, err := r.staff.GetAll()
+	if err != nil {
+		return entity.Staff{}, false, err
+	}
+	if telegramID != 0 {
+		for _, staff := range all {
+			if staff.TelegramID == telegramID {
+				return staff, true, nil
+			}
+		}
+	}
+	if len(userName) > 0 {
+		for _, staff := range all {
+			if (bound || staff.TelegramID == 0) && strings.EqualFold(staff.UserName, userName) {
+				return staff, true, nil
+			}
+		}
+	}
+	return entity.Staff{}, false, nil
+}
+
+// Grant - выдает роль сотруднику по Telegram ID или @username
+func (r *Roles) Grant(by int64, target string, role entity.Role) (entity.Staff, error) {
+	telegramID, userName, err := ParseTarget(target)
+	if err != nil {
+		return entity.Staff{}, err
+	}
+	if role.Rank() == 0 {
+		return entity.Staff{}, fmt.Errorf("%w: %s", ErrUnknownRole, role)
+	}
+	if err := r.checkTarget(by, telegramID); err != nil {
+		return entity.Staff{}, err
+	}
+	r.mutex.Lock()
+	staff, found, err := r.find(telegramID, userName, true)
+	if err == nil {
+		staff.Role, staff.GrantedBy, staff.GrantedAt = role, by, time.Now()
+		if found {
+			err = r.staff.Update(staff)
+		} else {
+			staff.TelegramID, staff.UserName = telegramID, userName
+			err = r.staff.Add(staff)
+		}
+	}
+	r.mutex.Unlock()
+	if err != nil {
+		return entity.Staff{}, err
+	}
+	r.Audit(entity.AuditEntry{TelegramID: by, Action: AuditGrant, Allowed: true,
+		Details: fmt.Sprintf("%s -> %s", target, role)})
+	return staff, nil
+}
+
+// Revoke - отзывает роль сотрудника
+func (r *Roles) Revoke(by int64, target string) (entity.Staff, error) {
+	telegramID, userName, err := ParseTarget(target)
+	if err != nil {
+		return entity.Staff{}, err
+	}
+	if err := r.checkTarget(by, telegramID); err != nil {
+		return entity.Staff{}, err
+	}
+	r.mutex.Lock()
+	staff, found, err := r.find(telegramID, userName, true)
+	if err == nil && !found {
+		err = ErrNotStaff
+	}
+	if err == nil {
+		err = r.staff.Delete(staff)
+	}
+	r.mutex.Unlock()
+	if err != nil {
+		return entity.Staff{}, err
+	}
+	r.Audit(entity.AuditEntry{TelegramID: by, Action: AuditRevoke, Allowed: true,
+		Details: fmt.Sprintf("%s (%s)", target, staff.Role)})
+	return staff, nil
+}
+
+// checkTarget - владельцев из конфигурации и самого себя менять нельзя
+func (r *Roles) checkTarget(by int64, telegramID int64) error {
+	if r.owners[telegramID] {
+		return ErrConfigOwner
+	}
+	if telegramID != 0 && telegramID == by {
+		return ErrSelfDemotion
+	}
+	return nil
+}
+
+// Staff - сотрудники от старшей роли к младшей
+func (r *Roles) Staff() ([]entity.Staff, error) {
+	all, err := r.staff.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Role != all[j].Role {
+			return all[i].Role.Rank() > all[j].Role.Rank()
+		}
+		return all[i].ID < all[j].ID
+	})
+	return all, nil
+}
+
+// Audit - запись в журнал; ошибка журнала не мешает работе бота
+func (r *Roles) Audit(entry entity.AuditEntry) {
+	if entry.TimeStamp.IsZero() {
+		entry.TimeStamp = time.Now()
+	}
+	if err := r.audit.Add(entry); err != nil {
+		slog.Error("audit entry", "action", entry.Action, "error", err)
+	}
+}
+
+// Check - есть ли у автора обновления роль required. Если нет - отказ
+// отправляется пользователю и записывается в журнал
+func (r *Roles) Check(u *telemux.Update, required entity.Role) bool {
+	user := u.SentFrom()
+	if user == nil {
+		return false
+	}
+	role, err := r.RoleOf(user.ID, user.UserName)
+	if err != nil {
+		slog.Error("telegram role", "user_id", user.ID, "error", err)
+	}
+	if role.Includes(required) {
+		return true
+	}
+	details := ""
+	if u.CallbackQuery != nil {
+		details = u.CallbackQuery.Data
+	} else if u.Message != nil {
+		details = u.Message.Text
+	}
+	r.Audit(entity.AuditEntry{TelegramID: user.ID, UserName: user.UserName, Command: telegram.CommandName(u),
+		Action: AuditDenied, Details: details})
+	deny(u, required)
+	return false
+}
+
+// Require - middleware для команд с TelegramCommand.Role
+func (r *Roles) Require(required entity.Role) telegram.Middleware {
+	return func(next telegram.Action) telegram.Action {
+		return telegram.SimpleActionStruct{
+			SimpleAction: func(u *telemux.Update) {
+				if r == nil {
+					deny(u, required)
+					return
+				}
+				if r.Check(u, required) {
+					next.Action(u)
+				}
+			},
+		}
+	}
+}
+
+// deny - сообщение об отказе: всплывающее окно для кнопок, ответ для сообщений
+func deny(u *telemux.Update, required entity.Role) {
+	if u.Bot == nil {
+		return
+	}
+	text := fmt.Sprintf("Недостаточно прав: нужна роль «%s»", required.Title())
+	if u.CallbackQuery != nil {
+		_, _ = u.Bot.Request(tgbotapi.NewCallbackWithAlert(u.CallbackQuery.ID, text))
+		return
+	}
+	if chat := u.FromChat(); chat != nil {
+		_, _ = u.Bot.Send(tgbotapi.NewMessage(chat.ID, text))
+	}
+}
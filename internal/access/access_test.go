@@ -0,0 +1,105 @@
+package access
+
+import (
+	"errors"
+	"github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"main/internal/database/entitybase/memoryentitybase"
+	"main/internal/entity"
+	"main/internal/telegram"
+	"testing"
+)
+
+func newRoles(owners ...int64) (*Roles, *memoryentitybase.MemoryEntityBase[entity.AuditEntry]) {
+	audit := memoryentitybase.InitMemoryEntityBase[entity.AuditEntry]()
+	return InitRoles(memoryentitybase.InitMemoryEntityBase[entity.Staff](), audit, owners...), audit
+}
+
+func messageFrom(id int64, userName, text string) *telemux.Update {
+	return &telemux.Update{Update: tgbotapi.Update{Message: &tgbotapi.Message{
+		Text: text,
+		From: &tgbotapi.User{ID: id, UserName: userName},
+		Chat: &tgbotapi.Chat{ID: id},
+	}}}
+}
+
+func TestRoleIncludes(t *testing.T) {
+	if !entity.RoleOwner.Includes(entity.RoleAnalyst) || !entity.RoleAdmin.Includes(entity.RoleAdmin) {
+		t.Errorf("старшая роль должна включать младшую")
+	}
+	if entity.RoleSupport.Includes(entity.RoleModerator) || entity.Role("").Includes(entity.RoleAnalyst) {
+		t.Errorf("младшая роль или отсутствие роли не дают прав старшей")
+	}
+}
+
+func TestGrantByUserNameBindsID(t *testing.T) {
+	roles, audit := newRoles(1)
+	if _, err := roles.Grant(1, "@Moder", entity.RoleModerator); err != nil {
+		t.Fatal(err)
+	}
+	role, err := roles.RoleOf(42, "moder")
+	if err != nil || role != entity.RoleModerator {
+		t.Fatalf("ожидали роль модератора, получили %q, %v", role, err)
+	}
+	// после привязки роль определяется по ID, даже если username сменили
+	if role, _ := roles.RoleOf(42, "renamed"); role != entity.RoleModerator {
+		t.Errorf("роль должна сохраниться после смены username, получили %q", role)
+	}
+	// а освободившийся username не дает роли другому пользователю
+	if role, _ := roles.RoleOf(43, "moder"); role != "" {
+		t.Errorf("чужой username не должен давать роль, получили %q", role)
+	}
+	if _, err := roles.Grant(1, "42", entity.RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	staff, _ := roles.Staff()
+	if len(staff) != 1 || staff[0].Role != entity.RoleAdmin {
+		t.Errorf("повторная выдача должна менять роль, а не добавлять сотрудника: %+v", staff)
+	}
+	if entries, _ := audit.GetAll(); len(entries) != 2 {
+		t.Errorf("ожидали 2 записи журнала, получили %d", len(entries))
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	roles, _ := newRoles(1)
+	if _, err := roles.Grant(1, "5", entity.RoleSupport); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := roles.Revoke(1, "5"); err != nil {
+		t.Fatal(err)
+	}
+	if role, _ := roles.RoleOf(5, ""); role != "" {
+		t.Errorf("роль должна быть отозвана, получили %q", role)
+	}
+	if _, err := roles.Revoke(1, "5"); !errors.Is(err, ErrNotStaff) {
+		t.Errorf("ожидали ErrNotStaff, получили %v", err)
+	}
+	if _, err := roles.Revoke(2, "1"); !errors.Is(err, ErrConfigOwner) {
+		t.Errorf("владельца из конфигурации нельзя отозвать, получили %v", err)
+	}
+	if _, err := roles.Grant(1, "bad name", entity.RoleAdmin); !errors.Is(err, ErrBadTarget) {
+		t.Errorf("ожидали ErrBadTarget, получили %v", err)
+	}
+}
+
+func TestRequireDeniesAndAudits(t *testing.T) {
+	roles, audit := newRoles(1)
+	if _, err := roles.Grant(1, "7", entity.RoleAnalyst); err != nil {
+		t.Fatal(err)
+	}
+	handled := 0
+	action := telegram.Chain(telegram.SimpleActionStruct{SimpleAction: func(u *telemux.Update) { handled++ }},
+		roles.Require(entity.RoleAdmin))
+	action.Action(messageFrom(1, "owner", "/requisites"))
+	denied := messageFrom(7, "analyst", "/requisites")
+	telegram.SetCommandName(denied, "requisites")
+	action.Action(denied)
+	if handled != 1 {
+		t.Fatalf("команду должен выполнить только владелец, выполнено %d", handled)
+	}
+	entry, _ := audit.Get(entity.AuditEntry{Action: AuditDenied})
+	if entry.TelegramID != 7 || entry.Command != "requisites" || entry.Allowed {
+		t.Errorf("отказ должен попасть в журнал: %+v", entry)
+	}
+}
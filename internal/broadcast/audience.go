@@ -0,0 +1,152 @@
+package broadcast
+
+import (
+	"errors"
+	"fmt"
+	"main/internal/database/entitybase"
+	"main/internal/entity"
+	"main/internal/reachability"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Audience - источники получателей рассылки. Legacy может быть nil, если импорта не было
+type Audience struct {
+	Users         entitybase.EntityBase[entity.User]
+	Subscriptions entitybase.EntityBase[entity.Subscription]
+	Payments      entitybase.EntityBase[entity.Payment]
+	Legacy        entitybase.EntityBase[entity.OldUser]
+}
+
+// SegmentTitle - описание сегмента для администратора
+func SegmentTitle(segment entity.Segment, param int) string {
+	switch segment {
+	case entity.SegmentAll:
+		return "все пользователи"
+	case entity.SegmentActive:
+		return "действующие подписчики"
+	case entity.SegmentExpired:
+		return fmt.Sprintf("подписка закончилась за последние %d дн.", param)
+	case entity.SegmentTariff:
+		return fmt.Sprintf("подписчики тарифа #%d", param)
+	case entity.SegmentNeverPaid:
+		return "ни разу не оплачивали"
+	case entity.SegmentLegacy:
+		return "пользователи старого бота"
+	}
+	return string(segment)
+}
+
+// Resolve - Telegram ID получателей сегмента на момент now, без повторов и по возрастанию.
+// Пользователи, которым бот не может писать, в сегмент не входят
+func (a Audience) Resolve(segment entity.Segment, param int, now time.Time) ([]int64, error) {
+	users, err := a.Users.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if segment == entity.SegmentLegacy {
+		return a.legacy(users)
+	}
+	var include func(user entity.User) bool
+	switch segment {
+	case entity.SegmentAll:
+		include = func(entity.User) bool { return true }
+	case entity.SegmentActive, entity.SegmentExpired, entity.SegmentTariff:
+		subscriptions, err := a.Subscriptions.GetAll()
+		if err != nil {
+			return nil, err
+		}
+		active, lastEnd, tariffs := subscriptionState(subscriptions, now)
+		switch segment {
+		case entity.SegmentActive:
+			include = func(user entity.User) bool { return active[user.ID] }
+		case entity.SegmentExpired:
+			if param <= 0 {
+				return nil, errors.New("укажите число дней больше нуля")
+			}
+			since := now.AddDate(0, 0, -param)
+			include = func(user entity.User) bool {
+				end, ok := lastEnd[user.ID]
+				return ok && !active[user.ID] && !end.Before(since)
+			}
+		case entity.SegmentTariff:
+			include = func(user entity.User) bool { return tariffs[user.ID][param] }
+		}
+	case entity.SegmentNeverPaid:
+		payments, err := a.Payments.GetAll()
+		if err != nil {
+			return nil, err
+		}
+		paid := make(map[int]bool, len(payments))
+		for _, payment := range payments {
+			paid[payment.UserID] = true
+		}
+		include = func(user entity.User) bool { return !paid[user.ID] }
+	default:
+		return nil, fmt.Errorf("неизвестный сегмент: %s", segment)
+	}
+	ids := make([]int64, 0, len(users))
+	for _, user := range users {
+		if user.UserTelegramId != 0 && reachability.Reachable(user) && include(user) {
+			ids = append(ids, user.UserTelegramId)
+		}
+	}
+	return unique(ids), nil
+}
+
+// subscriptionState - у кого подписка действует сейчас, когда закончилась последняя
+// и на какие тарифы действуют подписки
+func subscriptionState(subscriptions []entity.Subscription, now time.Time) (map[int]bool, map[int]time.Time, map[int]map[int]bool) {
+	active := make(map[int]bool)
+	lastEnd := make(map[int]time.Time)
+	tariffs := make(map[int]map[int]bool)
+	for _, subscription := range subscriptions {
+		if subscription.EndDate.After(now) && !subscription.StartDate.After(now) {
+			active[subscription.UserId] = true
+			if tariffs[subscription.UserId] == nil {
+				tariffs[subscription.UserId] = make(map[int]bool)
+			}
+			tariffs[subscription.UserId][subscription.TariffID] = true
+		} else if !subscription.EndDate.After(now) && subscription.EndDate.After(lastEnd[subscription.UserId]) {
+			lastEnd[subscription.UserId] = subscription.EndDate
+		}
+	}
+	return active, lastEnd, tariffs
+}
+
+// legacy - пользователи старого бота; недоступные известны, только если они уже есть в users
+func (a Audience) legacy(users []entity.User) ([]int64, error) {
+	if a.Legacy == nil {
+		return nil, nil
+	}
+	oldUsers, err := a.Legacy.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	unreachable := make(map[int64]bool)
+	for _, user := range users {
+		if !reachability.Reachable(user) {
+			unreachable[user.UserTelegramId] = true
+		}
+	}
+	ids := make([]int64, 0, len(oldUsers))
+	for _, oldUser := range oldUsers {
+		if id, err := strconv.ParseInt(strings.TrimSpace(oldUser.UserID), 10, 64); err == nil && id > 0 && !unreachable[id] {
+			ids = append(ids, id)
+		}
+	}
+	return unique(ids), nil
+}
+
+func unique(ids []int64) []int64 {
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	result := ids[:0]
+	for i, id := range ids {
+		if i == 0 || id != ids[i-1] {
+			result = append(result, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+package broadcast
+
+import (
+	"main/internal/database/entitybase/memoryentitybase"
+	"main/internal/entity"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testAudience(now time.Time) Audience {
+	day := 24 * time.Hour
+	users := memoryentitybase.InitMemoryEntityBase(
+		entity.User{ID: 1, UserTelegramId: 101},
+		entity.User{ID: 2, UserTelegramId: 102},
+		entity.User{ID: 3, UserTelegramId: 103},
+		entity.User{ID: 4, UserTelegramId: 104},
+		entity.User{ID: 5},
+		// 6 - заблокировал бота, ни в один сегмент не входит
+		entity.User{ID: 6, UserTelegramId: 500, Unreachable: true, UnreachableReason: entity.UnreachableBlocked},
+	)
+	subscriptions := memoryentitybase.InitMemoryEntityBase(
+		// 1 - действующая подписка на тариф 7 и старая закончившаяся
+		entity.Subscription{UserId: 1, TariffID: 7, StartDate: now.Add(-10 * day), EndDate: now.Add(20 * day)},
+		entity.Subscription{UserId: 1, TariffID: 3, StartDate: now.Add(-60 * day), EndDate: now.Add(-30 * day)},
+		// 2 - закончилась 3 дня назад
+		entity.Subscription{UserId: 2, TariffID: 3, StartDate: now.Add(-33 * day), EndDate: now.Add(-3 * day)},
+		// 3 - закончилась 40 дней назад
+		entity.Subscription{UserId: 3, TariffID: 7, StartDate: now.Add(-70 * day), EndDate: now.Add(-40 * day)},
+	)
+	payments := memoryentitybase.InitMemoryEntityBase(
+		entity.Payment{ID: 1, UserID: 1}, entity.Payment{ID: 2, UserID: 2}, entity.Payment{ID: 3, UserID: 3},
+	)
+	legacy := memoryentitybase.InitMemoryEntityBase(
+		entity.OldUser{UserID: "500"}, entity.OldUser{UserID: " 200 "}, entity.OldUser{UserID: "300"},
+		entity.OldUser{UserID: "300"},
+		entity.OldUser{UserID: "bad"},
+	)
+	return Audience{Users: users, Subscriptions: subscriptions, Payments: payments, Legacy: legacy}
+}
+
+func TestResolveSegments(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	audience := testAudience(now)
+	cases := []struct {
+		segment entity.Segment
+		param   int
+		want    []int64
+	}{
+		{entity.SegmentAll, 0, []int64{101, 102, 103, 104}},
+		{entity.SegmentActive, 0, []int64{101}},
+		{entity.SegmentExpired, 7, []int64{102}},
+		{entity.SegmentExpired, 60, []int64{102, 103}},
+		{entity.SegmentTariff, 7, []int64{101}},
+		{entity.SegmentTariff, 3, []int64{}},
+		{entity.SegmentNeverPaid, 0, []int64{104}},
+		{entity.SegmentLegacy, 0, []int64{200, 300}},
+	}
+	for _, c := range cases {
+		got, err := audience.Resolve(c.segment, c.param, now)
+		if err != nil {
+			t.Fatalf("%s/%d: ошибка %v", c.segment, c.param, err)
+		}
+		if len(got) == 0 && len(c.want) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s/%d: получатели %v, ожидалось %v", c.segment, c.param, got, c.want)
+		}
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	audience := testAudience(time.Now())
+	if _, err := audience.Resolve(entity.SegmentExpired, 0, time.Now()); err == nil {
+		t.Errorf("сегмент expired без числа дней должен давать ошибку")
+	}
+	if _, err := audience.Resolve("unknown", 0, time.Now()); err == nil {
+		t.Errorf("неизвестный сегмент должен давать ошибку")
+	}
+	audience.Legacy = nil
+	if ids, err := audience.Resolve(entity.SegmentLegacy, 0, time.Now()); err != nil || len(ids) != 0 {
+		t.Errorf("без импорта старого бота получателей нет: %v, %v", ids, err)
+	}
+}
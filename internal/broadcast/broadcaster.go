@@ -0,0 +1,498 @@
+package broadcast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log/slog"
+	"main/internal/database/entitybase"
+	"main/internal/entity"
+	"main/internal/entity/mapper"
+	"main/internal/telegram"
+	"sync"
+	"time"
+)
+
+// window - сообщений рассылки одновременно в очереди отправки: пауза и отмена
+// срабатывают, не дожидаясь, пока уйдет вся рассылка
+const window = 30
+
+// DefaultProgressInterval - как часто сообщать о ходе рассылки
+const DefaultProgressInterval = 5 * time.Second
+
+// DefaultPickupInterval - как часто Run ищет рассылки, сохраненные другими репликами
+const DefaultPickupInterval = 10 * time.Second
+
+var (
+	ErrNotRunning   = errors.New("рассылка не выполняется")
+	ErrNoRecipients = errors.New("в сегменте нет получателей")
+)
+
+// Enqueuer - очередь отправки бота, от имени которого идет рассылка, см. telegram.Outbox
+type Enqueuer interface {
+	Enqueue(c tgbotapi.Chattable, priority telegram.Priority, callback func(telegram.SendResult))
+}
+
+// Broadcaster - рассылки сегментам пользователей через очередь отправки с приоритетом рассылок.
+// Статус доставки каждому получателю сохраняется, рассылку можно приостановить,
+// продолжить и отменить
+type Broadcaster struct {
+	broadcasts entitybase.EntityBase[entity.Broadcast]
+	deliveries entitybase.EntityBase[entity.BroadcastDelivery]
+	audience   Audience
+	outbox     Enqueuer
+	// Progress - ход рассылки: при запуске, паузе, не чаще ProgressInterval во время отправки
+	// и по завершении. Вызывается из горутин рассылки
+	Progress         func(broadcast entity.Broadcast)
+	ProgressInterval time.Duration
+	PickupInterval   time.Duration
+
+	mutex sync.Mutex
+	runs  map[int]*run
+	// leading - в процессе работает Run: Start запускает отправку сразу
+	leading bool
+	// running - горутины рассылок, см. Run
+	running sync.WaitGroup
+}
+
+// run - выполняющаяся рассылка
+type run struct {
+	mutex     sync.Mutex
+	resume    *sync.Cond
+	broadcast entity.Broadcast
+	paused    bool
+	cancelled bool
+	// stopped - рассылка остановлена без завершения, см. stop; продолжится после Restore
+	stopped  bool
+	reported time.Time
+}
+
+func NewBroadcaster(
+	broadcasts entitybase.EntityBase[entity.Broadcast],
+	deliveries entitybase.EntityBase[entity.BroadcastDelivery],
+	audience Audience,
+	outbox Enqueuer) *Broadcaster {
+	return &Broadcaster{
+		broadcasts:       broadcasts,
+		deliveries:       deliveries,
+		audience:         audience,
+		outbox:           outbox,
+		ProgressInterval: DefaultProgressInterval,
+		PickupInterval:   DefaultPickupInterval,
+		runs:             make(map[int]*run),
+	}
+}
+
+// Recipients - получатели сегмента на текущий момент
+func (b *Broadcaster) Recipients(segment entity.Segment, param int) ([]int64, error) {
+	return b.audience.Resolve(segment, param, time.Now())
+}
+
+// Start - сохраняет рассылку и статусы доставки получателям сегмента. ID рассылки
+// и доставок назначаются здесь. Отправляет рассылку Run ведущей реплики: сразу, если
+// он работает в этом процессе, иначе в течение PickupInterval
+func (b *Broadcaster) Start(broadcast entity.Broadcast) (entity.Broadcast, error) {
+	recipients, err := b.Recipients(broadcast.Segment, broadcast.SegmentParam)
+	if err != nil {
+		return broadcast, err
+	}
+	if len(recipients) == 0 {
+		return broadcast, ErrNoRecipients
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if broadcast.ID, err = entitybase.NextID(b.broadcasts, func(item entity.Broadcast) int { return item.ID }); err != nil {
+		return broadcast, err
+	}
+	broadcast.Status = entity.BroadcastRunning
+	broadcast.Total = len(recipients)
+	broadcast.Sent, broadcast.Failed = 0, 0
+	broadcast.CreatedAt = time.Now()
+	if err := b.broadcasts.Add(broadcast); err != nil {
+		return broadcast, err
+	}
+	deliveryID, err := entitybase.NextID(b.deliveries, func(item entity.BroadcastDelivery) int { return item.ID })
+	if err != nil {
+		return broadcast, err
+	}
+	deliveries := make([]entity.BroadcastDelivery, len(recipients))
+	for i, telegramID := range recipients {
+		deliveries[i] = entity.BroadcastDelivery{ID: deliveryID + i, BroadcastID: broadcast.ID,
+			TelegramID: telegramID, Status: entity.DeliveryPending, UpdatedAt: broadcast.CreatedAt}
+		if err := b.deliveries.Add(deliveries[i]); err != nil {
+			return broadcast, err
+		}
+	}
+	if b.leading {
+		b.launch(broadcast, deliveries)
+	}
+	return broadcast, nil
+}
+
+// Run - отправляет рассылки до отмены ctx: продолжает прерванные (Restore), запускает
+// новые из Start этого процесса сразу, а сохраненные другими репликами - раз
+// в PickupInterval. После отмены останавливает все рассылки процесса: недоставленные
+// получатели остаются pending, и рассылки продолжит следующий Run. Возвращается, когда
+// уже поставленные в очередь сообщения отправлены. При нескольких репликах Run должен
+// работать только на ведущей, иначе каждая реплика отправит одни и те же рассылки
+func (b *Broadcaster) Run(ctx context.Context) error {
+	b.mutex.Lock()
+	b.leading = true
+	b.mutex.Unlock()
+	defer func() {
+		b.mutex.Lock()
+		b.leading = false
+		for _, r := range b.runs {
+			r.stop()
+		}
+		b.mutex.Unlock()
+		b.running.Wait()
+	}()
+	if err := b.Restore(); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(b.PickupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := b.Restore(); err != nil {
+				slog.Error("broadcast pickup", "error", err)
+			}
+		}
+	}
+}
+
+// Restore - запускает сохраненные рассылки, которые не отправляются в этом процессе:
+// прерванные перезапуском и сохраненные Start другой реплики, - недоставленным получателям.
+// Приостановленные рассылки остаются на паузе
+func (b *Broadcaster) Restore() error {
+	// чтение под блокировкой: рассылка, завершившаяся между чтением и проверкой runs,
+	// иначе запустилась бы заново по устаревшим статусам доставки
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	broadcasts, err := b.broadcasts.GetAll()
+	if err != nil {
+		return err
+	}
+	deliveries, err := b.deliveries.GetAll()
+	if err != nil {
+		return err
+	}
+	for _, broadcast := range broadcasts {
+		if broadcast.Status != entity.BroadcastRunning && broadcast.Status != entity.BroadcastPaused {
+			continue
+		}
+		if _, ok := b.runs[broadcast.ID]; ok {
+			continue
+		}
+		var pending []entity.BroadcastDelivery
+		broadcast.Sent, broadcast.Failed = 0, 0
+		for _, delivery := range deliveries {
+			if delivery.BroadcastID != broadcast.ID {
+				continue
+			}
+			switch delivery.Status {
+			case entity.DeliverySent:
+				broadcast.Sent++
+			case entity.DeliveryFailed:
+				broadcast.Failed++
+			default:
+				pending = append(pending, delivery)
+			}
+		}
+		b.launch(broadcast, pending)
+	}
+	return nil
+}
+
+// Get - рассылка с текущими счетчиками
+func (b *Broadcaster) Get(id int) (entity.Broadcast, error) {
+	if r, ok := b.run(id); ok {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		return r.broadcast, nil
+	}
+	broadcast, err := b.broadcasts.Get(entity.Broadcast{ID: id})
+	if errors.Is(err, entitybase.ErrNotFound) {
+		err = errors.New("рассылка не найдена")
+	}
+	return broadcast, err
+}
+
+// Pause - новые сообщения рассылки перестают ставиться в очередь отправки
+func (b *Broadcaster) Pause(id int) error {
+	return b.control(id, func(r *run) {
+		r.paused = true
+		r.broadcast.Status = entity.BroadcastPaused
+	})
+}
+
+// Resume - продолжает приостановленную рассылку
+func (b *Broadcaster) Resume(id int) error {
+	return b.control(id, func(r *run) {
+		r.paused = false
+		r.broadcast.Status = entity.BroadcastRunning
+	})
+}
+
+// Cancel - отменяет рассылку; уже поставленные в очередь сообщения будут отправлены,
+// остальные получатели останутся в статусе pending
+func (b *Broadcaster) Cancel(id int) error {
+	return b.control(id, func(r *run) {
+		r.cancelled = true
+	})
+}
+
+func (b *Broadcaster) control(id int, change func(r *run)) error {
+	r, ok := b.run(id)
+	if !ok {
+		return ErrNotRunning
+	}
+	r.mutex.Lock()
+	change(r)
+	r.resume.Broadcast()
+	broadcast := r.broadcast
+	r.mutex.Unlock()
+	b.save(broadcast)
+	b.report(r, true)
+	return nil
+}
+
+func (b *Broadcaster) run(id int) (*run, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	r, ok := b.runs[id]
+	return r, ok
+}
+
+// launch - запускает отправку deliveries; вызывается под b.mutex
+func (b *Broadcaster) launch(broadcast entity.Broadcast, deliveries []entity.BroadcastDelivery) {
+	r := &run{broadcast: broadcast, paused: broadcast.Status == entity.BroadcastPaused}
+	r.resume = sync.NewCond(&r.mutex)
+	b.runs[broadcast.ID] = r
+	b.running.Add(1)
+	go func() {
+		defer b.running.Done()
+		b.process(r, deliveries)
+	}()
+}
+
+func (b *Broadcaster) process(r *run, deliveries []entity.BroadcastDelivery) {
+	b.report(r, true)
+	slots := make(chan struct{}, window)
+	// ждет, пока все поставленные в очередь сообщения будут отправлены
+	drain := func() {
+		for i := 0; i < window; i++ {
+			slots <- struct{}{}
+		}
+		for i := 0; i < window; i++ {
+			<-slots
+		}
+	}
+	for i, delivery := range deliveries {
+		if !r.proceed() {
+			break
+		}
+		slots <- struct{}{}
+		b.deliver(r, delivery, func() { <-slots })
+		// файл загружается один раз, остальным получателям уходит его FileID
+		if i == 0 && r.needsUpload() {
+			drain()
+		}
+	}
+	drain()
+
+	r.mutex.Lock()
+	if r.stopped {
+		// статус не меняется: Restore при следующем запуске отправит оставшимся получателям
+		broadcast := r.broadcast
+		r.mutex.Unlock()
+		b.save(broadcast)
+		b.mutex.Lock()
+		delete(b.runs, broadcast.ID)
+		b.mutex.Unlock()
+		return
+	}
+	r.broadcast.Status = entity.BroadcastFinished
+	if r.cancelled {
+		r.broadcast.Status = entity.BroadcastCancelled
+	}
+	r.broadcast.FinishedAt = time.Now()
+	broadcast := r.broadcast
+	r.mutex.Unlock()
+	b.save(broadcast)
+	b.mutex.Lock()
+	delete(b.runs, broadcast.ID)
+	b.mutex.Unlock()
+	b.report(r, true)
+}
+
+// deliver - отправляет получателю все части сообщения по порядку; release вызывается,
+// когда доставка завершена успешно или с ошибкой
+func (b *Broadcaster) deliver(r *run, delivery entity.BroadcastDelivery, release func()) {
+	r.mutex.Lock()
+	message := r.broadcast.Message
+	message.Files = append([]entity.File{}, message.Files...)
+	r.mutex.Unlock()
+	message.TelegramID = delivery.TelegramID
+	parts := mapper.SentMessageToSend(message)
+	if len(parts) == 0 {
+		b.record(r, delivery, errors.New("пустое сообщение"), 0)
+		release()
+		return
+	}
+	attempts := 0
+	var send func(part int)
+	send = func(part int) {
+		b.outbox.Enqueue(parts[part], telegram.PriorityMarketing, func(result telegram.SendResult) {
+			// получатель остается в статусе pending; если часть сообщения уже ушла,
+			// после Restore оно будет отправлено заново целиком
+			if errors.Is(result.Err, telegram.ErrOutboxStopped) {
+				r.stop()
+				release()
+				return
+			}
+			attempts += result.Attempts
+			if result.Err == nil {
+				r.rememberFileID(result.Message)
+				if part+1 < len(parts) {
+					send(part + 1)
+					return
+				}
+			}
+			b.record(r, delivery, result.Err, attempts)
+			release()
+		})
+	}
+	send(0)
+}
+
+// record - сохраняет статус доставки и обновляет счетчики рассылки
+func (b *Broadcaster) record(r *run, delivery entity.BroadcastDelivery, err error, attempts int) {
+	delivery.Status = entity.DeliverySent
+	delivery.Error = ""
+	if err != nil {
+		delivery.Status = entity.DeliveryFailed
+		delivery.Error = err.Error()
+	}
+	delivery.Attempts = attempts
+	delivery.UpdatedAt = time.Now()
+	if err := b.deliveries.Update(delivery); err != nil {
+		slog.Error("broadcast delivery", "broadcast", delivery.BroadcastID, "telegram_id", delivery.TelegramID, "error", err)
+	}
+	r.mutex.Lock()
+	if delivery.Status == entity.DeliverySent {
+		r.broadcast.Sent++
+	} else {
+		r.broadcast.Failed++
+	}
+	r.mutex.Unlock()
+	b.report(r, false)
+}
+
+// report - сохраняет счетчики и сообщает о ходе рассылки; без force - не чаще ProgressInterval
+func (b *Broadcaster) report(r *run, force bool) {
+	r.mutex.Lock()
+	now := time.Now()
+	if !force && now.Sub(r.reported) < b.ProgressInterval {
+		r.mutex.Unlock()
+		return
+	}
+	r.reported = now
+	broadcast := r.broadcast
+	r.mutex.Unlock()
+	if !force {
+		b.save(broadcast)
+	}
+	if b.Progress != nil {
+		b.Progress(broadcast)
+	}
+}
+
+func (b *Broadcaster) save(broadcast entity.Broadcast) {
+	if err := b.broadcasts.Update(broadcast); err != nil {
+		slog.Error("broadcast save", "broadcast", broadcast.ID, "error", err)
+	}
+}
+
+// proceed - ждет, пока рассылка на паузе; false - рассылка отменена или очередь
+// отправки остановлена
+func (r *run) proceed() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for r.paused && !r.cancelled && !r.stopped {
+		r.resume.Wait()
+	}
+	return !r.cancelled && !r.stopped
+}
+
+// stop - рассылка останавливается без завершения: очередь отправки больше не принимает
+// ее сообщения или процесс останавливается, см. Broadcaster.Run
+func (r *run) stop() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.stopped = true
+	r.resume.Broadcast()
+}
+
+// needsUpload - файл рассылки еще не загружен в Telegram
+func (r *run) needsUpload() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.broadcast.Message.Files) == 1 && len(r.broadcast.Message.Files[0].FileID) == 0
+}
+
+// rememberFileID - FileID загруженного файла из ответа Telegram
+func (r *run) rememberFileID(message tgbotapi.Message) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	files := r.broadcast.Message.Files
+	if len(files) != 1 || len(files[0].FileID) > 0 {
+		return
+	}
+	if fileID := uploadedFileID(message, files[0].Type); len(fileID) > 0 {
+		r.broadcast.Message.Files = []entity.File{{Filename: files[0].Filename, Type: files[0].Type, FileID: fileID}}
+	}
+}
+
+func uploadedFileID(message tgbotapi.Message, fileType entity.TypeFile) string {
+	switch {
+	case fileType == entity.Photo && len(message.Photo) > 0:
+		return message.Photo[len(message.Photo)-1].FileID
+	case fileType == entity.Doc && message.Document != nil:
+		return message.Document.FileID
+	case fileType == entity.Video && message.Video != nil:
+		return message.Video.FileID
+	case fileType == entity.Audio && message.Audio != nil:
+		return message.Audio.FileID
+	case fileType == entity.Animation && message.Animation != nil:
+		return message.Animation.FileID
+	case fileType == entity.Voice && message.Voice != nil:
+		return message.Voice.FileID
+	case fileType == entity.VideoVoice && message.VideoNote != nil:
+		return message.VideoNote.FileID
+	}
+	return ""
+}
+
+// ProgressText - ход рассылки для администратора
+func ProgressText(broadcast entity.Broadcast) string {
+	status := map[entity.BroadcastStatus]string{
+		entity.BroadcastRunning:   "⏳ идет",
+		entity.BroadcastPaused:    "⏸ на паузе",
+		entity.BroadcastCancelled: "⛔ отменена",
+		entity.BroadcastFinished:  "✅ завершена",
+	}[broadcast.Status]
+	done := broadcast.Sent + broadcast.Failed
+	percent := 0
+	if broadcast.Total > 0 {
+		percent = done * 100 / broadcast.Total
+	}
+	return fmt.Sprintf("Рассылка #%d — %s\nАудитория: %s\nОтправлено: %d из %d (%d%%)\nОшибок: %d",
+		broadcast.ID, status, SegmentTitle(broadcast.Segment, broadcast.SegmentParam),
+		broadcast.Sent, broadcast.Total, percent, broadcast.Failed)
+}
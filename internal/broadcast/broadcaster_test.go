@@ -0,0 +1,332 @@
+package broadcast
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"main/internal/database/entitybase/memoryentitybase"
+	"main/internal/entity"
+	"main/internal/telegram"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeOutbox - очередь отправки, которая сразу отвечает; получатели из failFor получают ошибку
+type fakeOutbox struct {
+	mutex   sync.Mutex
+	sent    []tgbotapi.Chattable
+	failFor map[int64]bool
+	gate    chan struct{}
+}
+
+func (f *fakeOutbox) Enqueue(c tgbotapi.Chattable, priority telegram.Priority, callback func(telegram.SendResult)) {
+	if priority != telegram.PriorityMarketing {
+		panic("рассылка должна идти с приоритетом marketing")
+	}
+	go func() {
+		if f.gate != nil {
+			<-f.gate
+		}
+		f.mutex.Lock()
+		f.sent = append(f.sent, c)
+		f.mutex.Unlock()
+		result := telegram.SendResult{Attempts: 1}
+		switch config := c.(type) {
+		case tgbotapi.MessageConfig:
+			if f.failFor[config.ChatID] {
+				result.Err = errors.New("Forbidden: bot was blocked by the user")
+			}
+		case tgbotapi.PhotoConfig:
+			result.Message.Photo = []tgbotapi.PhotoSize{{FileID: "uploaded"}}
+		}
+		callback(result)
+	}()
+}
+
+func (f *fakeOutbox) messages() []tgbotapi.Chattable {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]tgbotapi.Chattable{}, f.sent...)
+}
+
+func newTestBroadcaster(outbox Enqueuer) (*Broadcaster, *memoryentitybase.MemoryEntityBase[entity.Broadcast], *memoryentitybase.MemoryEntityBase[entity.BroadcastDelivery], chan entity.Broadcast) {
+	broadcasts := memoryentitybase.InitMemoryEntityBase[entity.Broadcast]()
+	deliveries := memoryentitybase.InitMemoryEntityBase[entity.BroadcastDelivery]()
+	b := NewBroadcaster(broadcasts, deliveries, testAudience(time.Now()), outbox)
+	finished := make(chan entity.Broadcast, 1)
+	b.Progress = func(broadcast entity.Broadcast) {
+		if broadcast.Status == entity.BroadcastFinished || broadcast.Status == entity.BroadcastCancelled {
+			finished <- broadcast
+		}
+	}
+	return b, broadcasts, deliveries, finished
+}
+
+// lead - делает рассылки ведущими: Run работает до конца теста, и Start отправляет сразу
+func lead(t *testing.T, b *Broadcaster) {
+	t.Helper()
+	b.PickupInterval = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- b.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	deadline := time.Now().Add(2 * time.Second)
+	for !b.isLeading() {
+		if time.Now().After(deadline) {
+			t.Fatal("Run не запустился")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (b *Broadcaster) isLeading() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.leading
+}
+
+func waitFinished(t *testing.T, finished chan entity.Broadcast) entity.Broadcast {
+	t.Helper()
+	select {
+	case broadcast := <-finished:
+		return broadcast
+	case <-time.After(2 * time.Second):
+		t.Fatalf("рассылка не завершилась")
+	}
+	return entity.Broadcast{}
+}
+
+func TestBroadcastDeliveries(t *testing.T) {
+	outbox := &fakeOutbox{failFor: map[int64]bool{103: true}}
+	b, broadcasts, deliveries, finished := newTestBroadcaster(outbox)
+	lead(t, b)
+	started, err := b.Start(entity.Broadcast{Message: entity.MessageFromAdminBot{Text: "Новости"}, Segment: entity.SegmentAll})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if started.ID != 1 || started.Total != 4 {
+		t.Errorf("рассылка #%d на %d получателей, ожидалась #1 на 4", started.ID, started.Total)
+	}
+	result := waitFinished(t, finished)
+	if result.Status != entity.BroadcastFinished || result.Sent != 3 || result.Failed != 1 {
+		t.Errorf("итог: %s, отправлено %d, ошибок %d", result.Status, result.Sent, result.Failed)
+	}
+	if len(outbox.messages()) != 4 {
+		t.Errorf("отправлено %d сообщений, ожидалось 4", len(outbox.messages()))
+	}
+	stored, _ := broadcasts.Get(entity.Broadcast{ID: 1})
+	if stored.Status != entity.BroadcastFinished || stored.Sent != 3 {
+		t.Errorf("сохраненная рассылка: %s, отправлено %d", stored.Status, stored.Sent)
+	}
+	all, _ := deliveries.GetAll()
+	for _, delivery := range all {
+		want := entity.DeliverySent
+		if delivery.TelegramID == 103 {
+			want = entity.DeliveryFailed
+		}
+		if delivery.Status != want || delivery.Attempts != 1 {
+			t.Errorf("доставка %d: %s, попыток %d, ожидалось %s", delivery.TelegramID, delivery.Status, delivery.Attempts, want)
+		}
+	}
+}
+
+func TestBroadcastNoRecipients(t *testing.T) {
+	b, _, _, _ := newTestBroadcaster(&fakeOutbox{})
+	_, err := b.Start(entity.Broadcast{Message: entity.MessageFromAdminBot{Text: "x"}, Segment: entity.SegmentTariff, SegmentParam: 99})
+	if !errors.Is(err, ErrNoRecipients) {
+		t.Errorf("пустой сегмент должен давать ErrNoRecipients, получено %v", err)
+	}
+}
+
+func TestBroadcastPauseCancel(t *testing.T) {
+	outbox := &fakeOutbox{gate: make(chan struct{})}
+	b, _, deliveries, finished := newTestBroadcaster(outbox)
+	lead(t, b)
+	started, err := b.Start(entity.Broadcast{Message: entity.MessageFromAdminBot{Text: "x"}, Segment: entity.SegmentAll})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Pause(started.ID); err != nil {
+		t.Fatal(err)
+	}
+	if current, _ := b.Get(started.ID); current.Status != entity.BroadcastPaused {
+		t.Errorf("после паузы статус %s", current.Status)
+	}
+	if err := b.Resume(started.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Cancel(started.ID); err != nil {
+		t.Fatal(err)
+	}
+	close(outbox.gate)
+	result := waitFinished(t, finished)
+	if result.Status != entity.BroadcastCancelled {
+		t.Errorf("после отмены статус %s", result.Status)
+	}
+	if err := b.Pause(started.ID); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("пауза завершенной рассылки должна давать ErrNotRunning, получено %v", err)
+	}
+	all, _ := deliveries.GetAll()
+	if len(all) != 4 {
+		t.Errorf("сохранено %d доставок, ожидалось 4", len(all))
+	}
+}
+
+func TestBroadcastUploadsFileOnce(t *testing.T) {
+	outbox := &fakeOutbox{}
+	b, _, _, finished := newTestBroadcaster(outbox)
+	lead(t, b)
+	message := entity.MessageFromAdminBot{Text: "Акция", Files: []entity.File{{Filename: "promo.jpg", Type: entity.Photo}}}
+	if _, err := b.Start(entity.Broadcast{Message: message, Segment: entity.SegmentAll}); err != nil {
+		t.Fatal(err)
+	}
+	waitFinished(t, finished)
+	uploads := 0
+	for _, sent := range outbox.messages() {
+		photo, ok := sent.(tgbotapi.PhotoConfig)
+		if !ok {
+			t.Fatalf("ожидалось фото с подписью, отправлено %T", sent)
+		}
+		if _, ok := photo.File.(tgbotapi.FileID); !ok {
+			uploads++
+		}
+	}
+	if uploads != 1 {
+		t.Errorf("файл загружен %d раз, ожидался 1", uploads)
+	}
+}
+
+func TestBroadcastRestore(t *testing.T) {
+	outbox := &fakeOutbox{}
+	b, broadcasts, deliveries, finished := newTestBroadcaster(outbox)
+	_ = broadcasts.Add(entity.Broadcast{ID: 1, Status: entity.BroadcastRunning, Total: 2,
+		Message: entity.MessageFromAdminBot{Text: "x"}, Segment: entity.SegmentAll})
+	_ = deliveries.Add(entity.BroadcastDelivery{ID: 1, BroadcastID: 1, TelegramID: 101, Status: entity.DeliverySent})
+	_ = deliveries.Add(entity.BroadcastDelivery{ID: 2, BroadcastID: 1, TelegramID: 102, Status: entity.DeliveryPending})
+	if err := b.Restore(); err != nil {
+		t.Fatal(err)
+	}
+	result := waitFinished(t, finished)
+	if result.Sent != 2 || len(outbox.messages()) != 1 {
+		t.Errorf("после восстановления отправлено %d (новых сообщений %d), ожидалось 2 (1)", result.Sent, len(outbox.messages()))
+	}
+}
+
+func TestBroadcastStartOnFollower(t *testing.T) {
+	outbox := &fakeOutbox{}
+	follower, broadcasts, deliveries, _ := newTestBroadcaster(outbox)
+	started, err := follower.Start(entity.Broadcast{Message: entity.MessageFromAdminBot{Text: "x"}, Segment: entity.SegmentAll})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, running := follower.run(started.ID); running || len(outbox.messages()) != 0 {
+		t.Fatal("рассылка не ведущей реплики не должна отправляться")
+	}
+
+	// ведущая реплика с общими хранилищами подхватывает рассылку
+	leader := NewBroadcaster(broadcasts, deliveries, testAudience(time.Now()), outbox)
+	finished := make(chan entity.Broadcast, 1)
+	leader.Progress = func(broadcast entity.Broadcast) {
+		if broadcast.Status == entity.BroadcastFinished {
+			finished <- broadcast
+		}
+	}
+	leader.PickupInterval = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = leader.Run(ctx) }()
+	if result := waitFinished(t, finished); result.ID != started.ID || result.Sent != 4 {
+		t.Errorf("ведущая реплика отправила рассылку %d получателям %d, ожидалось %d и 4", result.ID, result.Sent, started.ID)
+	}
+	if len(outbox.messages()) != 4 {
+		t.Errorf("отправлено %d сообщений, ожидалось 4", len(outbox.messages()))
+	}
+}
+
+// blockedSender - Telegram, до которого сообщения не доходят
+type blockedSender struct{}
+
+func (blockedSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	return tgbotapi.Message{}, errors.New("сообщение не должно отправляться")
+}
+
+func TestBroadcastOutboxStopped(t *testing.T) {
+	outbox := telegram.NewOutbox(blockedSender{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_ = outbox.Run(ctx)
+	b, broadcasts, deliveries, finished := newTestBroadcaster(outbox)
+	lead(t, b)
+	started, err := b.Start(entity.Broadcast{Message: entity.MessageFromAdminBot{Text: "x"}, Segment: entity.SegmentAll})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for _, running := b.run(started.ID); running; _, running = b.run(started.ID) {
+		if time.Now().After(deadline) {
+			t.Fatal("рассылка не остановилась вместе с очередью отправки")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// остановка процесса не завершает рассылку и не считает получателей ошибочными
+	stored, _ := broadcasts.Get(entity.Broadcast{ID: started.ID})
+	if stored.Status != entity.BroadcastRunning || stored.Failed != 0 || !stored.FinishedAt.IsZero() {
+		t.Errorf("после остановки очереди: %s, ошибок %d", stored.Status, stored.Failed)
+	}
+	all, _ := deliveries.GetAll()
+	for _, delivery := range all {
+		if delivery.Status != entity.DeliveryPending {
+			t.Errorf("доставка %d: %s, ожидался pending", delivery.TelegramID, delivery.Status)
+		}
+	}
+
+	b.outbox = &fakeOutbox{}
+	if err := b.Restore(); err != nil {
+		t.Fatal(err)
+	}
+	if result := waitFinished(t, finished); result.Sent != 4 || result.Failed != 0 {
+		t.Errorf("после перезапуска отправлено %d, ошибок %d, ожидалось 4 и 0", result.Sent, result.Failed)
+	}
+}
+
+func TestBroadcastRunStopsWithoutFinishing(t *testing.T) {
+	outbox := &fakeOutbox{gate: make(chan struct{})}
+	b, broadcasts, deliveries, finished := newTestBroadcaster(outbox)
+	_ = broadcasts.Add(entity.Broadcast{ID: 1, Status: entity.BroadcastRunning, Total: 1,
+		Message: entity.MessageFromAdminBot{Text: "x"}, Segment: entity.SegmentAll})
+	_ = deliveries.Add(entity.BroadcastDelivery{ID: 1, BroadcastID: 1, TelegramID: 101, Status: entity.DeliveryPending})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- b.Run(ctx) }()
+	deadline := time.Now().Add(2 * time.Second)
+	for _, running := b.run(1); !running; _, running = b.run(1) {
+		if time.Now().After(deadline) {
+			t.Fatal("Run не продолжил прерванную рассылку")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// реплика перестала быть ведущей: рассылку продолжит другая
+	cancel()
+	select {
+	case <-done:
+		t.Fatal("Run вернулся, не дождавшись сообщения в очереди отправки")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(outbox.gate)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case result := <-finished:
+		t.Errorf("остановленная рассылка не должна завершаться: %s", result.Status)
+	default:
+	}
+	if stored, _ := broadcasts.Get(entity.Broadcast{ID: 1}); stored.Status != entity.BroadcastRunning || stored.Sent != 1 {
+		t.Errorf("после остановки: %s, отправлено %d", stored.Status, stored.Sent)
+	}
+}
@@ -0,0 +1,211 @@
+package broadcast
+
+import (
+	"errors"
+	"fmt"
+	"main/internal/entity"
+	"strconv"
+	"strings"
+	"time"
+	// база часовых поясов внутри бинарника: в минимальных образах нет /usr/share/zoneinfo
+	_ "time/tzdata"
+)
+
+// ScheduleUsage - подсказка по формату времени рассылки
+const ScheduleUsage = "Когда отправить:\n" +
+	"• 10:00 — ближайшие 10:00\n" +
+	"• завтра 10:00, пт 10:00, 06.06.2025 10:00 — один раз\n" +
+	"• ежедневно 09:30\n" +
+	"• каждый пн 10:00\n" +
+	"• ежемесячно 1 12:00 — 1-го числа каждого месяца\n" +
+	"В конце можно указать часовой пояс, например: пт 10:00 Asia/Yekaterinburg"
+
+var errScheduleFormat = errors.New("Не понял время рассылки.\n" + ScheduleUsage)
+
+// weekdays - основы названий дней недели: сокращение и полное название в любом падеже
+var weekdays = []struct {
+	short string
+	stem  string
+	day   time.Weekday
+}{
+	{"пн", "понедельник", time.Monday},
+	{"вт", "вторник", time.Tuesday},
+	{"ср", "сред", time.Wednesday},
+	{"чт", "четверг", time.Thursday},
+	{"пт", "пятниц", time.Friday},
+	{"сб", "суббот", time.Saturday},
+	{"вс", "воскресень", time.Sunday},
+}
+
+var weekdayTitles = map[time.Weekday]string{
+	time.Monday: "понедельник", time.Tuesday: "вторник", time.Wednesday: "среду", time.Thursday: "четверг",
+	time.Friday: "пятницу", time.Saturday: "субботу", time.Sunday: "воскресенье",
+}
+
+// ParseRule - разбирает время рассылки, см. ScheduleUsage. Без явного часового пояса
+// время считается в location; now нужен для однократных рассылок без даты
+func ParseRule(text string, location *time.Location, now time.Time) (entity.ScheduleRule, error) {
+	fields := strings.Fields(strings.ToLower(strings.TrimSpace(text)))
+	if len(fields) == 0 {
+		return entity.ScheduleRule{}, errScheduleFormat
+	}
+	if len(fields) > 1 && !strings.Contains(fields[len(fields)-1], ":") {
+		// названия поясов чувствительны к регистру: берем из исходного текста
+		original := strings.Fields(text)
+		zone, err := time.LoadLocation(original[len(original)-1])
+		if err != nil {
+			return entity.ScheduleRule{}, fmt.Errorf("неизвестный часовой пояс %q", original[len(original)-1])
+		}
+		location = zone
+		fields = fields[:len(fields)-1]
+	}
+	rule := entity.ScheduleRule{TimeZone: location.String()}
+	var err error
+	if rule.Hour, rule.Minute, err = parseClock(fields[len(fields)-1]); err != nil {
+		return rule, err
+	}
+	fields = fields[:len(fields)-1]
+
+	local := now.In(location)
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, rule.Hour, rule.Minute, 0, 0, location)
+	}
+	rule.Recurrence = entity.RecurrenceOnce
+	switch {
+	case len(fields) == 0:
+		daily := rule
+		daily.Recurrence = entity.RecurrenceDaily
+		rule.Date, _ = Next(daily, now)
+	case len(fields) == 1 && fields[0] == "сегодня":
+		rule.Date = at(local.Year(), local.Month(), local.Day())
+	case len(fields) == 1 && fields[0] == "завтра":
+		rule.Date = at(local.Year(), local.Month(), local.Day()+1)
+	case len(fields) == 1 && strings.Contains(fields[0], "."):
+		date, err := parseDate(fields[0], local)
+		if err != nil {
+			return rule, err
+		}
+		rule.Date = at(date.Year(), date.Month(), date.Day())
+	case len(fields) == 1 && fields[0] == "ежедневно",
+		len(fields) == 2 && strings.HasPrefix(fields[0], "кажд") && fields[1] == "день":
+		rule.Recurrence = entity.RecurrenceDaily
+	case len(fields) == 2 && fields[0] == "ежемесячно":
+		day, err := strconv.Atoi(fields[1])
+		if err != nil || day < 1 || day > 31 {
+			return rule, errors.New("день месяца - число от 1 до 31")
+		}
+		rule.Recurrence = entity.RecurrenceMonthly
+		rule.Day = day
+	case len(fields) == 2 && strings.HasPrefix(fields[0], "кажд"):
+		day, ok := parseWeekday(fields[1])
+		if !ok {
+			return rule, errScheduleFormat
+		}
+		rule.Recurrence = entity.RecurrenceWeekly
+		rule.Weekday = day
+	case len(fields) == 1:
+		day, ok := parseWeekday(fields[0])
+		if !ok {
+			return rule, errScheduleFormat
+		}
+		weekly := rule
+		weekly.Recurrence, weekly.Weekday = entity.RecurrenceWeekly, day
+		rule.Date, _ = Next(weekly, now)
+	default:
+		return rule, errScheduleFormat
+	}
+	return rule, nil
+}
+
+func parseClock(text string) (int, int, error) {
+	clock, err := time.Parse("15:04", text)
+	if err != nil {
+		return 0, 0, errScheduleFormat
+	}
+	return clock.Hour(), clock.Minute(), nil
+}
+
+// parseDate - ДД.ММ.ГГГГ или ДД.ММ текущего года
+func parseDate(text string, local time.Time) (time.Time, error) {
+	if date, err := time.Parse("02.01.2006", text); err == nil {
+		return date, nil
+	}
+	date, err := time.Parse("02.01", text)
+	if err != nil {
+		return time.Time{}, errScheduleFormat
+	}
+	return time.Date(local.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+func parseWeekday(text string) (time.Weekday, bool) {
+	for _, day := range weekdays {
+		if text == day.short || strings.HasPrefix(text, day.stem) {
+			return day.day, true
+		}
+	}
+	return 0, false
+}
+
+// Location - часовой пояс правила; неизвестный пояс заменяется на fallback
+func Location(rule entity.ScheduleRule, fallback *time.Location) *time.Location {
+	location, err := time.LoadLocation(rule.TimeZone)
+	if err != nil || len(rule.TimeZone) == 0 {
+		return fallback
+	}
+	return location
+}
+
+// Next - ближайший запуск по правилу строго после after; false - запусков больше не будет.
+// Время суток считается в часовом поясе правила, поэтому переход на летнее время его не сдвигает
+func Next(rule entity.ScheduleRule, after time.Time) (time.Time, bool) {
+	location := Location(rule, time.UTC)
+	local := after.In(location)
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, rule.Hour, rule.Minute, 0, 0, location)
+	}
+	switch rule.Recurrence {
+	case entity.RecurrenceOnce:
+		return rule.Date, rule.Date.After(after)
+	case entity.RecurrenceDaily, entity.RecurrenceWeekly:
+		for day := 0; day <= 7; day++ {
+			next := at(local.Year(), local.Month(), local.Day()+day)
+			if next.After(after) && (rule.Recurrence == entity.RecurrenceDaily || next.Weekday() == rule.Weekday) {
+				return next, true
+			}
+		}
+	case entity.RecurrenceMonthly:
+		for month := 0; month <= 12; month++ {
+			// 0-е число следующего месяца - последний день этого
+			last := time.Date(local.Year(), local.Month()+time.Month(month)+1, 0, 0, 0, 0, 0, location).Day()
+			next := at(local.Year(), local.Month()+time.Month(month), min(rule.Day, last))
+			if next.After(after) {
+				return next, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// RuleTitle - правило словами для администратора
+func RuleTitle(rule entity.ScheduleRule) string {
+	clock := fmt.Sprintf("%02d:%02d", rule.Hour, rule.Minute)
+	var title string
+	switch rule.Recurrence {
+	case entity.RecurrenceOnce:
+		title = "однократно " + rule.Date.In(Location(rule, time.UTC)).Format("02.01.2006 15:04")
+	case entity.RecurrenceDaily:
+		title = "ежедневно в " + clock
+	case entity.RecurrenceWeekly:
+		title = fmt.Sprintf("каждый %s в %s", weekdayTitles[rule.Weekday], clock)
+		if rule.Weekday == time.Wednesday || rule.Weekday == time.Friday || rule.Weekday == time.Saturday {
+			title = "каждую" + strings.TrimPrefix(title, "каждый")
+		} else if rule.Weekday == time.Sunday {
+			title = "каждое" + strings.TrimPrefix(title, "каждый")
+		}
+	case entity.RecurrenceMonthly:
+		title = fmt.Sprintf("ежемесячно %d-го в %s", rule.Day, clock)
+	default:
+		title = string(rule.Recurrence)
+	}
+	return title + " (" + rule.TimeZone + ")"
+}
@@ -0,0 +1,88 @@
+package broadcast
+
+import (
+	"main/internal/entity"
+	"testing"
+	"time"
+)
+
+func TestParseRule(t *testing.T) {
+	moscow, _ := time.LoadLocation("Europe/Moscow")
+	// среда, 4 июня 2025, 12:00 по Москве
+	now := time.Date(2025, 6, 4, 12, 0, 0, 0, moscow)
+	cases := []struct {
+		text string
+		want entity.ScheduleRule
+	}{
+		{"10:00", entity.ScheduleRule{Recurrence: entity.RecurrenceOnce, Date: time.Date(2025, 6, 5, 10, 0, 0, 0, moscow)}},
+		{"18:30", entity.ScheduleRule{Recurrence: entity.RecurrenceOnce, Date: time.Date(2025, 6, 4, 18, 30, 0, 0, moscow)}},
+		{"завтра 9:05", entity.ScheduleRule{Recurrence: entity.RecurrenceOnce, Date: time.Date(2025, 6, 5, 9, 5, 0, 0, moscow)}},
+		{"пт 10:00", entity.ScheduleRule{Recurrence: entity.RecurrenceOnce, Date: time.Date(2025, 6, 6, 10, 0, 0, 0, moscow)}},
+		{"Среда 11:00", entity.ScheduleRule{Recurrence: entity.RecurrenceOnce, Date: time.Date(2025, 6, 11, 11, 0, 0, 0, moscow)}},
+		{"20.06.2025 10:00", entity.ScheduleRule{Recurrence: entity.RecurrenceOnce, Date: time.Date(2025, 6, 20, 10, 0, 0, 0, moscow)}},
+		{"ежедневно 09:30", entity.ScheduleRule{Recurrence: entity.RecurrenceDaily, Hour: 9, Minute: 30}},
+		{"каждый день 09:30", entity.ScheduleRule{Recurrence: entity.RecurrenceDaily, Hour: 9, Minute: 30}},
+		{"каждый пн 10:00", entity.ScheduleRule{Recurrence: entity.RecurrenceWeekly, Weekday: time.Monday, Hour: 10}},
+		{"каждую пятницу 10:00", entity.ScheduleRule{Recurrence: entity.RecurrenceWeekly, Weekday: time.Friday, Hour: 10}},
+		{"ежемесячно 31 12:00", entity.ScheduleRule{Recurrence: entity.RecurrenceMonthly, Day: 31, Hour: 12}},
+	}
+	for _, c := range cases {
+		rule, err := ParseRule(c.text, moscow, now)
+		if err != nil {
+			t.Errorf("%q: ошибка %v", c.text, err)
+			continue
+		}
+		if rule.TimeZone != "Europe/Moscow" {
+			t.Errorf("%q: часовой пояс %s", c.text, rule.TimeZone)
+		}
+		if rule.Recurrence != c.want.Recurrence || !rule.Date.Equal(c.want.Date) || rule.Weekday != c.want.Weekday ||
+			rule.Day != c.want.Day || (c.want.Recurrence != entity.RecurrenceOnce && (rule.Hour != c.want.Hour || rule.Minute != c.want.Minute)) {
+			t.Errorf("%q: правило %+v, ожидалось %+v", c.text, rule, c.want)
+		}
+	}
+
+	rule, err := ParseRule("пт 10:00 Asia/Yekaterinburg", moscow, now)
+	if err != nil || rule.TimeZone != "Asia/Yekaterinburg" || rule.Date.UTC().Hour() != 5 {
+		t.Errorf("явный часовой пояс: %+v, %v", rule, err)
+	}
+	for _, text := range []string{"", "когда-нибудь", "25:00", "пт 10:00 Mars/Olympus", "ежемесячно 32 10:00", "каждый 10:00"} {
+		if _, err := ParseRule(text, moscow, now); err == nil {
+			t.Errorf("%q: ожидалась ошибка", text)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	weekly := entity.ScheduleRule{Recurrence: entity.RecurrenceWeekly, Weekday: time.Monday, Hour: 10, TimeZone: "Europe/Berlin"}
+	// воскресенье перед переходом на летнее время: понедельник уже в CEST, но все так же 10:00
+	next, ok := Next(weekly, time.Date(2025, 3, 30, 12, 0, 0, 0, berlin))
+	if !ok || !next.Equal(time.Date(2025, 3, 31, 10, 0, 0, 0, berlin)) {
+		t.Errorf("еженедельно: %v", next)
+	}
+	if next, _ = Next(weekly, next); !next.Equal(time.Date(2025, 4, 7, 10, 0, 0, 0, berlin)) {
+		t.Errorf("следующий запуск должен быть через неделю: %v", next)
+	}
+
+	daily := entity.ScheduleRule{Recurrence: entity.RecurrenceDaily, Hour: 9, TimeZone: "Europe/Berlin"}
+	if next, _ = Next(daily, time.Date(2025, 6, 1, 9, 0, 0, 0, berlin)); !next.Equal(time.Date(2025, 6, 2, 9, 0, 0, 0, berlin)) {
+		t.Errorf("ежедневно: запуск строго после after, получено %v", next)
+	}
+
+	monthly := entity.ScheduleRule{Recurrence: entity.RecurrenceMonthly, Day: 31, Hour: 12, TimeZone: "Europe/Berlin"}
+	if next, _ = Next(monthly, time.Date(2025, 2, 1, 0, 0, 0, 0, berlin)); !next.Equal(time.Date(2025, 2, 28, 12, 0, 0, 0, berlin)) {
+		t.Errorf("ежемесячно: в феврале - последний день, получено %v", next)
+	}
+
+	once := entity.ScheduleRule{Recurrence: entity.RecurrenceOnce, Date: time.Date(2025, 6, 6, 10, 0, 0, 0, berlin)}
+	if _, ok = Next(once, once.Date); ok {
+		t.Errorf("однократная рассылка не должна повторяться")
+	}
+}
+
+func TestRuleTitle(t *testing.T) {
+	rule := entity.ScheduleRule{Recurrence: entity.RecurrenceWeekly, Weekday: time.Friday, Hour: 10, TimeZone: "Europe/Moscow"}
+	if title := RuleTitle(rule); title != "каждую пятницу в 10:00 (Europe/Moscow)" {
+		t.Errorf("описание правила: %q", title)
+	}
+}
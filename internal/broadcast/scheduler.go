@@ -0,0 +1,299 @@
+package broadcast
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"main/internal/database/entitybase"
+	"main/internal/entity"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultFilesDir - каталог файлов рассылок, если он не задан
+const DefaultFilesDir = "data/broadcast"
+
+// LateLimit - насколько запуск может опоздать, например если бот был выключен.
+// Более старый запуск пропускается: вчерашний пост «на утро понедельника» в четверг не нужен
+const LateLimit = 6 * time.Hour
+
+var (
+	ErrScheduleNotFound = errors.New("запланированная рассылка не найдена")
+	ErrScheduleInPast   = errors.New("это время уже прошло")
+)
+
+// Scheduler - рассылки по расписанию. Расписание хранится в schedules, поэтому переживает
+// перезапуск: Tick, вызываемый раз в минуту, запускает все наступившие рассылки
+type Scheduler struct {
+	schedules   entitybase.EntityBase[entity.ScheduledBroadcast]
+	broadcaster *Broadcaster
+	location    *time.Location
+	// filesDir - файлы расписаний и запущенных рассылок; в отличие от временного
+	// каталога переживает перезапуск, как и сами расписания
+	filesDir string
+	// Announce - сообщение администратору о запуске по расписанию;
+	// возвращает ID сообщения, в котором будет ход рассылки, или 0
+	Announce func(schedule entity.ScheduledBroadcast) int
+	// Failed - сообщение администратору о том, что рассылка по расписанию не запустилась
+	Failed func(schedule entity.ScheduledBroadcast, err error)
+
+	mutex sync.Mutex
+}
+
+// NewScheduler - location - часовой пояс по умолчанию для времени без явного пояса,
+// nil - пояс сервера. filesDir - каталог файлов, пустой - DefaultFilesDir
+func NewScheduler(
+	schedules entitybase.EntityBase[entity.ScheduledBroadcast],
+	broadcaster *Broadcaster,
+	location *time.Location,
+	filesDir string) *Scheduler {
+	if location == nil {
+		location = time.Local
+	}
+	if len(filesDir) == 0 {
+		filesDir = DefaultFilesDir
+	}
+	return &Scheduler{schedules: schedules, broadcaster: broadcaster, location: location, filesDir: filesDir}
+}
+
+// FilesDir - каталог, в котором должны лежать файлы сообщений расписаний
+func (s *Scheduler) FilesDir() string {
+	return s.filesDir
+}
+
+// Location - часовой пояс по умолчанию
+func (s *Scheduler) Location() *time.Location {
+	return s.location
+}
+
+// Add - сохраняет рассылку по расписанию. Файлы сообщения переходят во владение
+// планировщика и удаляются, когда запусков больше не будет; они должны лежать
+// в FilesDir, иначе могут пропасть до запуска
+func (s *Scheduler) Add(schedule entity.ScheduledBroadcast) (entity.ScheduledBroadcast, error) {
+	now := time.Now()
+	next, ok := Next(schedule.Rule, now)
+	if !ok {
+		return schedule, ErrScheduleInPast
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	id, err := entitybase.NextID(s.schedules, func(item entity.ScheduledBroadcast) int { return item.ID })
+	if err != nil {
+		return schedule, err
+	}
+	schedule.ID = id
+	schedule.Status = entity.ScheduleActive
+	schedule.NextRun = next
+	schedule.CreatedAt = now
+	return schedule, s.schedules.Add(schedule)
+}
+
+// Active - действующие расписания по времени ближайшего запуска
+func (s *Scheduler) Active() ([]entity.ScheduledBroadcast, error) {
+	all, err := s.schedules.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	active := make([]entity.ScheduledBroadcast, 0, len(all))
+	for _, schedule := range all {
+		if schedule.Status == entity.ScheduleActive {
+			active = append(active, schedule)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].NextRun.Before(active[j].NextRun) })
+	return active, nil
+}
+
+// Pending - расписания для /scheduled: действующие и не запустившиеся однократные
+func (s *Scheduler) Pending() ([]entity.ScheduledBroadcast, error) {
+	all, err := s.schedules.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]entity.ScheduledBroadcast, 0, len(all))
+	for _, schedule := range all {
+		if schedule.Status == entity.ScheduleActive || schedule.Status == entity.ScheduleFailed {
+			pending = append(pending, schedule)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].NextRun.Before(pending[j].NextRun) })
+	return pending, nil
+}
+
+// Get - действующее или не запустившееся расписание: его можно изменить или отменить
+func (s *Scheduler) Get(id int) (entity.ScheduledBroadcast, error) {
+	schedule, err := s.schedules.Get(entity.ScheduledBroadcast{ID: id})
+	if errors.Is(err, entitybase.ErrNotFound) {
+		return schedule, ErrScheduleNotFound
+	}
+	if err != nil {
+		return schedule, err
+	}
+	if schedule.Status != entity.ScheduleActive && schedule.Status != entity.ScheduleFailed {
+		return schedule, ErrScheduleNotFound
+	}
+	return schedule, nil
+}
+
+// Reschedule - новое время рассылки; не запустившаяся рассылка снова становится действующей
+func (s *Scheduler) Reschedule(id int, rule entity.ScheduleRule) (entity.ScheduledBroadcast, error) {
+	return s.change(id, func(schedule *entity.ScheduledBroadcast) error {
+		next, ok := Next(rule, time.Now())
+		if !ok {
+			return ErrScheduleInPast
+		}
+		schedule.Rule = rule
+		schedule.NextRun = next
+		schedule.Status = entity.ScheduleActive
+		return nil
+	})
+}
+
+// SetText - новый текст рассылки; у сообщения с файлом - подпись
+func (s *Scheduler) SetText(id int, text string) (entity.ScheduledBroadcast, error) {
+	return s.change(id, func(schedule *entity.ScheduledBroadcast) error {
+		schedule.Message.Text = text
+		return nil
+	})
+}
+
+// Cancel - отменяет будущие запуски; уже идущая рассылка не останавливается
+func (s *Scheduler) Cancel(id int) error {
+	_, err := s.change(id, func(schedule *entity.ScheduledBroadcast) error {
+		schedule.Status = entity.ScheduleCancelled
+		removeFiles(schedule.Message.Files)
+		return nil
+	})
+	return err
+}
+
+func (s *Scheduler) change(id int, change func(schedule *entity.ScheduledBroadcast) error) (entity.ScheduledBroadcast, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	schedule, err := s.Get(id)
+	if err != nil {
+		return schedule, err
+	}
+	if err := change(&schedule); err != nil {
+		return schedule, err
+	}
+	return schedule, s.schedules.Update(schedule)
+}
+
+// Tick - запускает наступившие рассылки и назначает следующий запуск
+func (s *Scheduler) Tick(now time.Time) {
+	active, err := s.Active()
+	if err != nil {
+		slog.Error("broadcast schedule", "error", err)
+		return
+	}
+	for _, schedule := range active {
+		if schedule.NextRun.After(now) {
+			break
+		}
+		s.run(schedule.ID, now)
+	}
+}
+
+func (s *Scheduler) run(id int, now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	// расписание могли отменить или перенести, пока шли предыдущие запуски
+	schedule, err := s.Get(id)
+	if err != nil || schedule.Status != entity.ScheduleActive || schedule.NextRun.After(now) {
+		return
+	}
+	var failure error
+	if late := now.Sub(schedule.NextRun); late > LateLimit {
+		slog.Warn("broadcast schedule: run skipped", "schedule", schedule.ID, "planned", schedule.NextRun, "late", late)
+	} else if broadcast, err := s.start(schedule); err != nil {
+		slog.Error("broadcast schedule: run failed", "schedule", schedule.ID, "error", err)
+		failure = err
+		schedule.LastError = err.Error()
+	} else {
+		schedule.LastBroadcastID = broadcast.ID
+		schedule.Runs++
+		schedule.LastError = ""
+	}
+	schedule.LastRun = now
+	next, ok := Next(schedule.Rule, now)
+	switch {
+	case ok:
+		schedule.NextRun = next
+	case failure != nil:
+		// файлы остаются: рассылку можно перенести на другое время
+		schedule.Status = entity.ScheduleFailed
+	default:
+		schedule.Status = entity.ScheduleDone
+		removeFiles(schedule.Message.Files)
+	}
+	if err := s.schedules.Update(schedule); err != nil {
+		slog.Error("broadcast schedule", "schedule", schedule.ID, "error", err)
+	}
+	if failure != nil && s.Failed != nil {
+		s.Failed(schedule, failure)
+	}
+}
+
+// start - запускает рассылку с копией файлов: рассылка удаляет свои файлы по завершении,
+// а файлы расписания нужны следующим запускам
+func (s *Scheduler) start(schedule entity.ScheduledBroadcast) (entity.Broadcast, error) {
+	message := schedule.Message
+	message.Files = make([]entity.File, 0, len(schedule.Message.Files))
+	for _, file := range schedule.Message.Files {
+		path, err := copyFile(file.Filename, s.filesDir)
+		if err != nil {
+			removeFiles(message.Files)
+			return entity.Broadcast{}, err
+		}
+		message.Files = append(message.Files, entity.File{Filename: path, Type: file.Type})
+	}
+	broadcast := entity.Broadcast{
+		Message:      message,
+		Segment:      schedule.Segment,
+		SegmentParam: schedule.SegmentParam,
+		CreatedBy:    schedule.CreatedBy,
+		AdminChatID:  schedule.AdminChatID,
+	}
+	if s.Announce != nil {
+		broadcast.ProgressMessageID = s.Announce(schedule)
+	}
+	started, err := s.broadcaster.Start(broadcast)
+	if err != nil {
+		removeFiles(message.Files)
+	}
+	return started, err
+}
+
+// copyFile - копия файла в каталоге dir
+func copyFile(path, dir string) (string, error) {
+	source, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer source.Close()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	target, err := os.CreateTemp(dir, "broadcast-*"+filepath.Ext(path))
+	if err != nil {
+		return "", err
+	}
+	defer target.Close()
+	if _, err := io.Copy(target, source); err != nil {
+		_ = os.Remove(target.Name())
+		return "", err
+	}
+	return target.Name(), nil
+}
+
+func removeFiles(files []entity.File) {
+	for _, file := range files {
+		if err := os.Remove(file.Filename); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("broadcast schedule: remove file", "path", file.Filename, "error", err)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package broadcast
+
+import (
+	"errors"
+	"main/internal/database/entitybase/memoryentitybase"
+	"main/internal/entity"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestScheduler(t *testing.T) (*Scheduler, *memoryentitybase.MemoryEntityBase[entity.ScheduledBroadcast], *fakeOutbox, chan entity.Broadcast) {
+	t.Helper()
+	outbox := &fakeOutbox{}
+	b, _, _, finished := newTestBroadcaster(outbox)
+	lead(t, b)
+	schedules := memoryentitybase.InitMemoryEntityBase[entity.ScheduledBroadcast]()
+	return NewScheduler(schedules, b, time.UTC, t.TempDir()), schedules, outbox, finished
+}
+
+func TestSchedulerRecurring(t *testing.T) {
+	scheduler, schedules, outbox, finished := newTestScheduler(t)
+	announced := 0
+	scheduler.Announce = func(schedule entity.ScheduledBroadcast) int {
+		announced++
+		return 42
+	}
+	schedule, err := scheduler.Add(entity.ScheduledBroadcast{
+		Message: entity.MessageFromAdminBot{Text: "Доброе утро"},
+		Segment: entity.SegmentActive,
+		Rule:    entity.ScheduleRule{Recurrence: entity.RecurrenceDaily, Hour: 9, TimeZone: "UTC"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	planned := schedule.NextRun
+	scheduler.Tick(planned.Add(-time.Minute))
+	if announced != 0 {
+		t.Fatalf("рассылка не должна запускаться раньше времени")
+	}
+
+	// новый планировщик поверх того же хранилища - как после перезапуска
+	restarted := NewScheduler(schedules, scheduler.broadcaster, time.UTC, scheduler.FilesDir())
+	restarted.Announce = scheduler.Announce
+	restarted.Tick(planned.Add(time.Minute))
+	result := waitFinished(t, finished)
+	if announced != 1 || result.ProgressMessageID != 42 || len(outbox.messages()) != 1 {
+		t.Errorf("запуск: объявлений %d, сообщение хода %d, отправлено %d", announced, result.ProgressMessageID, len(outbox.messages()))
+	}
+	stored, err := restarted.Get(schedule.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Runs != 1 || stored.LastBroadcastID != result.ID || !stored.NextRun.Equal(planned.AddDate(0, 0, 1)) {
+		t.Errorf("после запуска: %+v", stored)
+	}
+	restarted.Tick(planned.Add(2 * time.Minute))
+	if announced != 1 {
+		t.Errorf("повторный Tick не должен запускать рассылку еще раз")
+	}
+}
+
+func TestSchedulerOnceWithFile(t *testing.T) {
+	scheduler, _, outbox, finished := newTestScheduler(t)
+	file, err := os.CreateTemp(scheduler.FilesDir(), "scheduled-*.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = file.Close()
+	at := time.Now().Add(time.Hour)
+	schedule, err := scheduler.Add(entity.ScheduledBroadcast{
+		Message: entity.MessageFromAdminBot{Text: "Акция", Files: []entity.File{{Filename: file.Name(), Type: entity.Photo}}},
+		Segment: entity.SegmentAll,
+		Rule:    entity.ScheduleRule{Recurrence: entity.RecurrenceOnce, Date: at, TimeZone: "UTC"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler.Tick(at)
+	result := waitFinished(t, finished)
+	// копия файла для запуска создается там же: временный каталог не переживает перезапуск,
+	// после которого Restore продолжит рассылку
+	if copied := result.Message.Files[0].Filename; filepath.Dir(copied) != scheduler.FilesDir() {
+		t.Errorf("копия файла создана в %s, ожидался каталог %s", copied, scheduler.FilesDir())
+	}
+	if len(outbox.messages()) != 4 {
+		t.Errorf("отправлено %d сообщений, ожидалось 4", len(outbox.messages()))
+	}
+	if _, err := scheduler.Get(schedule.ID); !errors.Is(err, ErrScheduleNotFound) {
+		t.Errorf("однократное расписание после запуска должно завершиться")
+	}
+	if _, err := os.Stat(file.Name()); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("файл завершенного расписания должен удаляться")
+	}
+}
+
+func TestSchedulerSkipsLateAndCancel(t *testing.T) {
+	scheduler, _, outbox, _ := newTestScheduler(t)
+	schedule, err := scheduler.Add(entity.ScheduledBroadcast{
+		Message: entity.MessageFromAdminBot{Text: "x"},
+		Segment: entity.SegmentAll,
+		Rule:    entity.ScheduleRule{Recurrence: entity.RecurrenceWeekly, Weekday: time.Monday, Hour: 10, TimeZone: "UTC"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler.Tick(schedule.NextRun.Add(LateLimit + time.Hour))
+	stored, _ := scheduler.Get(schedule.ID)
+	if len(outbox.messages()) != 0 || stored.Runs != 0 || !stored.NextRun.After(schedule.NextRun) {
+		t.Errorf("сильно опоздавший запуск должен пропускаться: %+v", stored)
+	}
+
+	if _, err := scheduler.SetText(schedule.ID, "новый текст"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scheduler.Reschedule(schedule.ID, entity.ScheduleRule{Recurrence: entity.RecurrenceOnce,
+		Date: time.Now().Add(-time.Hour)}); !errors.Is(err, ErrScheduleInPast) {
+		t.Errorf("перенос в прошлое должен давать ErrScheduleInPast, получено %v", err)
+	}
+	if err := scheduler.Cancel(schedule.ID); err != nil {
+		t.Fatal(err)
+	}
+	if active, _ := scheduler.Active(); len(active) != 0 {
+		t.Errorf("отмененное расписание не должно быть в списке: %+v", active)
+	}
+	if err := scheduler.Cancel(schedule.ID); !errors.Is(err, ErrScheduleNotFound) {
+		t.Errorf("повторная отмена должна давать ErrScheduleNotFound, получено %v", err)
+	}
+}
+
+func TestSchedulerOnceFailed(t *testing.T) {
+	scheduler, _, _, _ := newTestScheduler(t)
+	file, err := os.CreateTemp(scheduler.FilesDir(), "scheduled-*.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = file.Close()
+	var reported error
+	scheduler.Failed = func(schedule entity.ScheduledBroadcast, err error) {
+		reported = err
+	}
+	at := time.Now().Add(time.Hour)
+	schedule, err := scheduler.Add(entity.ScheduledBroadcast{
+		Message:      entity.MessageFromAdminBot{Text: "Тариф", Files: []entity.File{{Filename: file.Name(), Type: entity.Photo}}},
+		Segment:      entity.SegmentTariff,
+		SegmentParam: 99,
+		Rule:         entity.ScheduleRule{Recurrence: entity.RecurrenceOnce, Date: at, TimeZone: "UTC"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduler.Tick(at)
+	if !errors.Is(reported, ErrNoRecipients) {
+		t.Errorf("ожидали сообщение об ошибке запуска, получено %v", reported)
+	}
+	stored, err := scheduler.Get(schedule.ID)
+	if err != nil || stored.Status != entity.ScheduleFailed || stored.LastError != ErrNoRecipients.Error() {
+		t.Fatalf("не запустившаяся рассылка должна остаться с ошибкой: %+v, %v", stored, err)
+	}
+	if pending, _ := scheduler.Pending(); len(pending) != 1 {
+		t.Errorf("не запустившаяся рассылка должна быть в /scheduled: %+v", pending)
+	}
+	if active, _ := scheduler.Active(); len(active) != 0 {
+		t.Errorf("не запустившаяся рассылка не должна запускаться снова: %+v", active)
+	}
+	if _, err := os.Stat(file.Name()); err != nil {
+		t.Errorf("файл не запустившейся рассылки нужен для переноса: %v", err)
+	}
+	rescheduled, err := scheduler.Reschedule(schedule.ID, entity.ScheduleRule{Recurrence: entity.RecurrenceOnce,
+		Date: time.Now().Add(2 * time.Hour), TimeZone: "UTC"})
+	if err != nil || rescheduled.Status != entity.ScheduleActive {
+		t.Errorf("перенос должен снова делать рассылку действующей: %+v, %v", rescheduled, err)
+	}
+}
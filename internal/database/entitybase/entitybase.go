@@ -1,5 +1,25 @@
 package entitybase
 
+import "errors"
+
+var (
+	ErrNotFound = errors.New("entity not found")
+	ErrExists   = errors.New("entity already exists")
+	ErrNoID     = errors.New("entity has no ID")
+)
+
+// EntityBase - хранилище сущностей с целочисленным полем ID:
+//   - Add сохраняет новую сущность. Нулевой ID хранилище заменяет следующим свободным
+//     (наибольший ID + 1); сущность с заданным ID сохраняется под ним, занятый ID - ErrExists.
+//     Add не возвращает присвоенный ID: если он нужен сразу, его берут из NextID
+//   - Get ищет по образцу: возвращает сущность с наименьшим ID, у которой совпадают все
+//     ненулевые поля образца, например Get(User{UserTelegramId: id}). Нет такой - ErrNotFound
+//   - Update заменяет сущность с тем же ID, нет такой - ErrNotFound
+//   - Delete удаляет сущность по ID, отсутствие сущности ошибкой не считается
+//   - GetAll возвращает все сущности по возрастанию ID
+//
+// Сущности без поля ID (Subscription, OldUser) только добавляются и читаются: GetAll
+// возвращает их в порядке добавления, Update и Delete - ErrNoID
 type EntityBase[Anything any] interface {
 	Add(Anything) error
 	Update(Anything) error
@@ -7,3 +27,17 @@ type EntityBase[Anything any] interface {
 	Delete(Anything) error
 	GetAll() ([]Anything, error)
 }
+
+// NextID - ID, который Add присвоил бы следующей сущности с нулевым ID. Вызывающий
+// отвечает за то, чтобы между NextID и Add никто другой не добавлял сущности
+func NextID[Anything any](base EntityBase[Anything], id func(Anything) int) (int, error) {
+	all, err := base.GetAll()
+	if err != nil {
+		return 0, err
+	}
+	next := 1
+	for _, item := range all {
+		next = max(next, id(item)+1)
+	}
+	return next, nil
+}
@@ -0,0 +1,130 @@
+package memoryentitybase
+
+import (
+	"main/internal/database/entitybase"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// MemoryEntityBase - EntityBase в памяти процесса, для тестов и запуска без базы.
+// Сущности без поля ID int хранятся в порядке добавления, см. entitybase.ErrNoID
+type MemoryEntityBase[Anything any] struct {
+	mutex sync.RWMutex
+	items map[int]Anything
+	keyed bool
+}
+
+func (m *MemoryEntityBase[Anything]) Add(item Anything) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	key := m.key(&item)
+	if key == 0 {
+		key = 1
+		for stored := range m.items {
+			key = max(key, stored+1)
+		}
+		if m.keyed {
+			idField(&item).SetInt(int64(key))
+		}
+	} else if _, ok := m.items[key]; ok {
+		return entitybase.ErrExists
+	}
+	m.items[key] = item
+	return nil
+}
+
+func (m *MemoryEntityBase[Anything]) Update(item Anything) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if !m.keyed {
+		return entitybase.ErrNoID
+	}
+	key := m.key(&item)
+	if _, ok := m.items[key]; !ok {
+		return entitybase.ErrNotFound
+	}
+	m.items[key] = item
+	return nil
+}
+
+func (m *MemoryEntityBase[Anything]) Get(example Anything) (Anything, error) {
+	all, _ := m.GetAll()
+	for _, item := range all {
+		if matches(item, example) {
+			return item, nil
+		}
+	}
+	var empty Anything
+	return empty, entitybase.ErrNotFound
+}
+
+func (m *MemoryEntityBase[Anything]) Delete(item Anything) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if !m.keyed {
+		return entitybase.ErrNoID
+	}
+	delete(m.items, m.key(&item))
+	return nil
+}
+
+func (m *MemoryEntityBase[Anything]) GetAll() ([]Anything, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	keys := make([]int, 0, len(m.items))
+	for key := range m.items {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	result := make([]Anything, len(keys))
+	for i, key := range keys {
+		result[i] = m.items[key]
+	}
+	return result, nil
+}
+
+// key - ID сущности; у сущностей без ID всегда 0, им выдается порядковый номер
+func (m *MemoryEntityBase[Anything]) key(item *Anything) int {
+	if !m.keyed {
+		return 0
+	}
+	return int(idField(item).Int())
+}
+
+// idField - поле ID сущности, доступное для записи
+func idField[Anything any](item *Anything) reflect.Value {
+	return reflect.ValueOf(item).Elem().FieldByName("ID")
+}
+
+// matches - совпадают ли у item все ненулевые поля образца
+func matches[Anything any](item, example Anything) bool {
+	stored, wanted := reflect.ValueOf(item), reflect.ValueOf(example)
+	for i := 0; i < wanted.NumField(); i++ {
+		field := wanted.Field(i)
+		if !wanted.Type().Field(i).IsExported() || field.IsZero() {
+			continue
+		}
+		if !reflect.DeepEqual(stored.Field(i).Interface(), field.Interface()) {
+			return false
+		}
+	}
+	return true
+}
+
+// InitMemoryEntityBase - хранилище с начальными сущностями items, см. EntityBase.Add.
+// Anything - структура; паникует на других типах
+func InitMemoryEntityBase[Anything any](items ...Anything) *MemoryEntityBase[Anything] {
+	var empty Anything
+	if kind := reflect.TypeOf(empty).Kind(); kind != reflect.Struct {
+		panic("memoryentitybase: " + reflect.TypeOf(empty).String() + " is not a struct")
+	}
+	id := idField(&empty)
+	m := &MemoryEntityBase[Anything]{items: make(map[int]Anything), keyed: id.IsValid() && id.Kind() == reflect.Int}
+	for _, item := range items {
+		if err := m.Add(item); err != nil {
+			panic("memoryentitybase: " + err.Error())
+		}
+	}
+	return m
+}
@@ -0,0 +1,67 @@
+package memoryentitybase
+
+import (
+	"errors"
+	"main/internal/database/entitybase"
+	"main/internal/entity"
+	"testing"
+)
+
+func TestMemoryEntityBaseContract(t *testing.T) {
+	base := InitMemoryEntityBase(entity.User{UserTelegramId: 101, UserName: "alice"})
+	if err := base.Add(entity.User{ID: 5, UserTelegramId: 105}); err != nil {
+		t.Fatal(err)
+	}
+	if err := base.Add(entity.User{ID: 5}); !errors.Is(err, entitybase.ErrExists) {
+		t.Errorf("занятый ID: %v", err)
+	}
+	next, err := entitybase.NextID[entity.User](base, func(user entity.User) int { return user.ID })
+	if err != nil || next != 6 {
+		t.Errorf("NextID = %d, %v", next, err)
+	}
+	if err := base.Add(entity.User{UserTelegramId: 106, UserName: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// нулевой ID заменяется следующим свободным, как обещает NextID
+	user, err := base.Get(entity.User{UserTelegramId: 106})
+	if err != nil || user.ID != next {
+		t.Errorf("поиск по Telegram ID: %+v, %v", user, err)
+	}
+	// из нескольких совпадений возвращается сущность с наименьшим ID
+	if user, _ := base.Get(entity.User{UserName: "alice"}); user.ID != 1 {
+		t.Errorf("поиск по имени: %+v", user)
+	}
+	if _, err := base.Get(entity.User{UserName: "alice", UserTelegramId: 105}); !errors.Is(err, entitybase.ErrNotFound) {
+		t.Errorf("должны совпадать все ненулевые поля образца: %v", err)
+	}
+
+	user.ContainsSub = true
+	if err := base.Update(user); err != nil {
+		t.Fatal(err)
+	}
+	if err := base.Update(entity.User{ID: 42}); !errors.Is(err, entitybase.ErrNotFound) {
+		t.Errorf("обновление отсутствующей сущности: %v", err)
+	}
+	if err := base.Delete(entity.User{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	all, _ := base.GetAll()
+	if len(all) != 2 || all[0].ID != 5 || all[1].ID != 6 || !all[1].ContainsSub {
+		t.Errorf("GetAll: %+v", all)
+	}
+}
+
+func TestMemoryEntityBaseWithoutID(t *testing.T) {
+	base := InitMemoryEntityBase(entity.OldUser{UserID: "300"}, entity.OldUser{UserID: "200"})
+	if err := base.Add(entity.OldUser{UserID: "300"}); err != nil {
+		t.Fatal(err)
+	}
+	all, _ := base.GetAll()
+	if len(all) != 3 || all[0].UserID != "300" || all[1].UserID != "200" {
+		t.Errorf("сущности без ID должны храниться в порядке добавления: %+v", all)
+	}
+	if err := base.Update(all[0]); !errors.Is(err, entitybase.ErrNoID) {
+		t.Errorf("обновление сущности без ID: %v", err)
+	}
+}
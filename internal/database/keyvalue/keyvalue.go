@@ -0,0 +1,15 @@
+package keyvalue
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrNotFound = errors.New("key not found")
+
+// KeyValue - хранилище значений по ключу со временем жизни. ttl = 0 - без ограничения
+type KeyValue[Anything any] interface {
+	Set(key string, value Anything, ttl time.Duration) error
+	Get(key string) (*Anything, error)
+	Delete(key string) error
+}
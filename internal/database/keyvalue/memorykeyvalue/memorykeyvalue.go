@@ -0,0 +1,69 @@
+package memorykeyvalue
+
+import (
+	"main/internal/database/keyvalue"
+	"sync"
+	"time"
+)
+
+type item[Anything any] struct {
+	value     Anything
+	expiresAt time.Time
+}
+
+// MemoryKeyValue - хранилище в памяти процесса. Истекшие значения не возвращаются
+// и удаляются CollectGarbage
+type MemoryKeyValue[Anything any] struct {
+	mutex sync.RWMutex
+	items map[string]item[Anything]
+}
+
+func (m *MemoryKeyValue[Anything]) Set(key string, value Anything, ttl time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	stored := item[Anything]{value: value}
+	if ttl > 0 {
+		stored.expiresAt = time.Now().Add(ttl)
+	}
+	m.items[key] = stored
+	return nil
+}
+
+func (m *MemoryKeyValue[Anything]) Get(key string) (*Anything, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	stored, ok := m.items[key]
+	if !ok || expired(stored.expiresAt, time.Now()) {
+		return nil, keyvalue.ErrNotFound
+	}
+	return &stored.value, nil
+}
+
+func (m *MemoryKeyValue[Anything]) Delete(key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.items, key)
+	return nil
+}
+
+// CollectGarbage - удаляет значения, истекшие к моменту now. Возвращает их количество
+func (m *MemoryKeyValue[Anything]) CollectGarbage(now time.Time) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	removed := 0
+	for key, stored := range m.items {
+		if expired(stored.expiresAt, now) {
+			delete(m.items, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+func expired(expiresAt, now time.Time) bool {
+	return !expiresAt.IsZero() && !now.Before(expiresAt)
+}
+
+func InitMemoryKeyValue[Anything any]() *MemoryKeyValue[Anything] {
+	return &MemoryKeyValue[Anything]{items: make(map[string]item[Anything])}
+}
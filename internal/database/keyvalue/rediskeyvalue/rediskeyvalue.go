@@ -0,0 +1,54 @@
+package rediskeyvalue
+
+import (
+	"errors"
+	red "github.com/go-redis/redis"
+	"main/internal/database/keyvalue"
+	"main/internal/entity/mapper"
+	"time"
+)
+
+type RedisKeyValue[Anything any] struct {
+	db     *red.Client
+	prefix string
+}
+
+func (r RedisKeyValue[Anything]) Set(key string, value Anything, ttl time.Duration) error {
+	jsoned, err := mapper.ToJson(value)
+	if err != nil {
+		return errors.New("JSON mapper error " + err.Error())
+	}
+	return r.db.Set(r.prefix+key, jsoned, ttl).Err()
+}
+
+func (r RedisKeyValue[Anything]) Get(key string) (*Anything, error) {
+	bytes, err := r.db.Get(r.prefix + key).Bytes()
+	if err == red.Nil {
+		return nil, keyvalue.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.New("GET error " + err.Error())
+	}
+	answer, err := mapper.FromJson[Anything](string(bytes))
+	return &answer, err
+}
+
+func (r RedisKeyValue[Anything]) Delete(key string) error {
+	return r.db.Del(r.prefix + key).Err()
+}
+
+// Close - закрывает соединение с Redis
+func (r RedisKeyValue[Anything]) Close() error {
+	return r.db.Close()
+}
+
+func InitRedisKeyValue[Anything any](address, password, prefix string) *RedisKeyValue[Anything] {
+	return &RedisKeyValue[Anything]{
+		db: red.NewClient(&red.Options{
+			Addr:     address,
+			Password: password,
+			DB:       0,
+		}),
+		prefix: prefix,
+	}
+}
@@ -0,0 +1,14 @@
+package lock
+
+import "time"
+
+// Locker - блокировки между репликами бота. Блокировкой владеет owner - идентификатор
+// процесса; по истечении ttl блокировка освобождается сама, например если процесс упал
+type Locker interface {
+	// Acquire - захватывает key на ttl; false - key уже у другого владельца
+	Acquire(key, owner string, ttl time.Duration) (bool, error)
+	// Refresh - продлевает блокировку на ttl; false - key больше не принадлежит owner
+	Refresh(key, owner string, ttl time.Duration) (bool, error)
+	// Release - освобождает key, если он принадлежит owner
+	Release(key, owner string) error
+}
@@ -0,0 +1,55 @@
+package memorylock
+
+import (
+	"sync"
+	"time"
+)
+
+type holder struct {
+	owner     string
+	expiresAt time.Time
+}
+
+// MemoryLock - блокировки внутри одного процесса: для запуска без Redis и для тестов
+type MemoryLock struct {
+	mutex sync.Mutex
+	keys  map[string]holder
+	now   func() time.Time
+}
+
+func InitMemoryLock() *MemoryLock {
+	return &MemoryLock{keys: make(map[string]holder), now: time.Now}
+}
+
+func (m *MemoryLock) Acquire(key, owner string, ttl time.Duration) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := m.now()
+	// как SETNX: занятый ключ не захватывается повторно даже тем же владельцем
+	if current, ok := m.keys[key]; ok && now.Before(current.expiresAt) {
+		return false, nil
+	}
+	m.keys[key] = holder{owner: owner, expiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+func (m *MemoryLock) Refresh(key, owner string, ttl time.Duration) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	now := m.now()
+	current, ok := m.keys[key]
+	if !ok || current.owner != owner || !now.Before(current.expiresAt) {
+		return false, nil
+	}
+	m.keys[key] = holder{owner: owner, expiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+func (m *MemoryLock) Release(key, owner string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if current, ok := m.keys[key]; ok && current.owner == owner {
+		delete(m.keys, key)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package redislock
+
+import (
+	"errors"
+	red "github.com/go-redis/redis"
+	"time"
+)
+
+// Проверка владельца и изменение ключа одной командой: между GET и DEL/PEXPIRE
+// блокировка может истечь и достаться другой реплике
+var (
+	refreshScript = red.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+	releaseScript = red.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// RedisLock - блокировки между репликами: ключ с владельцем и временем жизни
+type RedisLock struct {
+	db     *red.Client
+	prefix string
+}
+
+func (r RedisLock) Acquire(key, owner string, ttl time.Duration) (bool, error) {
+	ok, err := r.db.SetNX(r.prefix+key, owner, ttl).Result()
+	if err != nil {
+		return false, errors.New("SETNX error " + err.Error())
+	}
+	return ok, nil
+}
+
+func (r RedisLock) Refresh(key, owner string, ttl time.Duration) (bool, error) {
+	result, err := refreshScript.Run(r.db, []string{r.prefix + key}, owner, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return false, errors.New("lock refresh error " + err.Error())
+	}
+	return result == 1, nil
+}
+
+func (r RedisLock) Release(key, owner string) error {
+	if err := releaseScript.Run(r.db, []string{r.prefix + key}, owner).Err(); err != nil {
+		return errors.New("lock release error " + err.Error())
+	}
+	return nil
+}
+
+// Close - закрывает соединение с Redis
+func (r RedisLock) Close() error {
+	return r.db.Close()
+}
+
+func InitRedisLock(address, password, prefix string) *RedisLock {
+	return &RedisLock{
+		db: red.NewClient(&red.Options{
+			Addr:     address,
+			Password: password,
+			DB:       0,
+		}),
+		prefix: prefix,
+	}
+}
@@ -0,0 +1,47 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// Consumer - обработка элементов очереди до отмены контекста.
+// Элемент, взятый из очереди, всегда обрабатывается до конца
+type Consumer[Anything any] struct {
+	Name   string
+	Queue  Queue[Anything]
+	Handle func(value Anything) error
+	// Idle - пауза, когда очередь пуста или недоступна
+	Idle time.Duration
+}
+
+// Run - забирает элементы, пока не отменен ctx. После отмены новые элементы не берутся,
+// текущий дообрабатывается
+func (c Consumer[Anything]) Run(ctx context.Context) error {
+	idle := c.Idle
+	if idle <= 0 {
+		idle = time.Second
+	}
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+		value, err := c.Queue.LPop()
+		if err != nil {
+			if !errors.Is(err, ErrEmpty) {
+				slog.Error("queue consumer", "queue", c.Name, "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(idle):
+			}
+			continue
+		}
+		if err := c.Handle(*value); err != nil {
+			slog.Error("queue consumer handle", "queue", c.Name, "error", err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sliceQueue - очередь в памяти для тестов
+type sliceQueue struct {
+	mutex  sync.Mutex
+	values []int
+}
+
+func (q *sliceQueue) RPush(value int) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.values = append(q.values, value)
+	return nil
+}
+
+func (q *sliceQueue) LPop() (*int, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if len(q.values) == 0 {
+		return nil, ErrEmpty
+	}
+	value := q.values[0]
+	q.values = q.values[1:]
+	return &value, nil
+}
+
+func TestConsumerFinishesCurrentValue(t *testing.T) {
+	q := &sliceQueue{values: []int{1, 2, 3}}
+	ctx, cancel := context.WithCancel(context.Background())
+	var handled []int
+	consumer := Consumer[int]{Name: "test", Queue: q, Idle: time.Millisecond,
+		Handle: func(value int) error {
+			// отмена во время обработки не прерывает текущий элемент
+			cancel()
+			time.Sleep(5 * time.Millisecond)
+			handled = append(handled, value)
+			return nil
+		}}
+	if err := consumer.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(handled) != 1 || handled[0] != 1 {
+		t.Errorf("ожидали обработку только первого элемента, получили %v", handled)
+	}
+	if len(q.values) != 2 {
+		t.Errorf("после отмены элементы должны остаться в очереди, осталось %d", len(q.values))
+	}
+}
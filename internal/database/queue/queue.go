@@ -1,5 +1,10 @@
 package queue
 
+import "errors"
+
+// ErrEmpty - в очереди нет элементов
+var ErrEmpty = errors.New("queue is empty")
+
 type Queue[Anything any] interface {
 	RPush(value Anything) error
 	LPop() (*Anything, error)
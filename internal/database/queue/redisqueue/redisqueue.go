@@ -3,6 +3,7 @@ package redisqueue
 import (
 	"errors"
 	red "github.com/go-redis/redis"
+	"main/internal/database/queue"
 	"main/internal/entity/mapper"
 )
 
@@ -21,6 +22,9 @@ func (r RedisQueue[Anything]) RPush(value Anything) error {
 
 func (r RedisQueue[Anything]) LPop() (*Anything, error) {
 	bytes, err := r.db.LPop(r.queueName).Bytes()
+	if err == red.Nil {
+		return nil, queue.ErrEmpty
+	}
 	if err != nil {
 		return nil, errors.New("LPOP error " + err.Error())
 	}
@@ -28,6 +32,11 @@ func (r RedisQueue[Anything]) LPop() (*Anything, error) {
 	return &answer, err
 }
 
+// Close - закрывает соединение с Redis
+func (r RedisQueue[Anything]) Close() error {
+	return r.db.Close()
+}
+
 func InitRedisQueue[Anything any](address, password, queueName string) *RedisQueue[Anything] {
 	return &RedisQueue[Anything]{
 		db: red.NewClient(&red.Options{
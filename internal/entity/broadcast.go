@@ -0,0 +1,113 @@
+package entity
+
+import "time"
+
+// Segment - аудитория рассылки
+type Segment string
+
+const (
+	SegmentAll       Segment = "all"        // все пользователи
+	SegmentActive    Segment = "active"     // действующая подписка
+	SegmentExpired   Segment = "expired"    // подписка закончилась не раньше SegmentParam дней назад
+	SegmentTariff    Segment = "tariff"     // действующая подписка на тариф SegmentParam
+	SegmentNeverPaid Segment = "never_paid" // ни одного платежа
+	SegmentLegacy    Segment = "legacy"     // пользователи, импортированные из старого бота
+)
+
+type BroadcastStatus string
+
+const (
+	BroadcastRunning   BroadcastStatus = "running"
+	BroadcastPaused    BroadcastStatus = "paused"
+	BroadcastCancelled BroadcastStatus = "cancelled"
+	BroadcastFinished  BroadcastStatus = "finished"
+)
+
+// Broadcast - рассылка сообщения сегменту пользователей
+type Broadcast struct {
+	ID           int
+	Message      MessageFromAdminBot
+	Segment      Segment
+	SegmentParam int
+	Status       BroadcastStatus
+	CreatedBy    int64
+	// Сообщение с ходом рассылки в чате администратора
+	AdminChatID       int64
+	ProgressMessageID int
+	Total             int
+	Sent              int
+	Failed            int
+	CreatedAt         time.Time
+	FinishedAt        time.Time
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending DeliveryStatus = "pending"
+	DeliverySent    DeliveryStatus = "sent"
+	DeliveryFailed  DeliveryStatus = "failed"
+)
+
+// BroadcastDelivery - доставка рассылки одному получателю
+type BroadcastDelivery struct {
+	ID          int
+	BroadcastID int
+	TelegramID  int64
+	Status      DeliveryStatus
+	Error       string
+	Attempts    int
+	UpdatedAt   time.Time
+}
+
+// Recurrence - повтор запланированной рассылки
+type Recurrence string
+
+const (
+	RecurrenceOnce    Recurrence = "once"
+	RecurrenceDaily   Recurrence = "daily"
+	RecurrenceWeekly  Recurrence = "weekly"  // в день недели Weekday
+	RecurrenceMonthly Recurrence = "monthly" // в день месяца Day, в коротких месяцах - в последний день
+)
+
+// ScheduleRule - когда отправлять рассылку: время Hour:Minute в часовом поясе TimeZone.
+// Для однократной рассылки - момент Date
+type ScheduleRule struct {
+	Recurrence Recurrence
+	Date       time.Time
+	Weekday    time.Weekday
+	Day        int
+	Hour       int
+	Minute     int
+	TimeZone   string
+}
+
+type ScheduleStatus string
+
+const (
+	ScheduleActive    ScheduleStatus = "active"
+	ScheduleDone      ScheduleStatus = "done"
+	ScheduleCancelled ScheduleStatus = "cancelled"
+	// ScheduleFailed - однократная рассылка не запустилась; ждет переноса или отмены
+	ScheduleFailed ScheduleStatus = "failed"
+)
+
+// ScheduledBroadcast - рассылка по расписанию; сегмент вычисляется в момент запуска
+type ScheduledBroadcast struct {
+	ID           int
+	Message      MessageFromAdminBot
+	Segment      Segment
+	SegmentParam int
+	Rule         ScheduleRule
+	Status       ScheduleStatus
+	NextRun      time.Time
+	LastRun      time.Time
+	// Рассылка последнего запуска
+	LastBroadcastID int
+	Runs            int
+	// Ошибка последнего запуска, пустая - запуск удался
+	LastError   string
+	CreatedBy   int64
+	AdminChatID int64
+	CreatedAt   time.Time
+}
@@ -67,8 +67,11 @@ func ToExcel[Anything any](array []Anything) (filename string, err error) {
 			case reflect.Float32, reflect.Float64:
 				fallback = fmt.Sprintf("%.2f", field.Float())
 			case reflect.Struct:
-				if tm, ok := field.Interface().(time.Time); ok {
+				// пустая дата, например UnreachableSince доступного пользователя, - пустая ячейка
+				if tm, ok := field.Interface().(time.Time); ok && !tm.IsZero() {
 					fallback = tm.Format(time.RFC3339)
+				} else if ok {
+					fallback = ""
 				} else {
 					fallback = fmt.Sprintf("%v", field.Interface())
 				}
@@ -150,6 +153,9 @@ func FromExcel[Anything any](filename string) ([]Anything, error) {
 				}
 			case reflect.Struct:
 				if field.Type == reflect.TypeOf(time.Time{}) {
+					if len(cellValue) == 0 {
+						continue
+					}
 					if tm, err := time.Parse(time.RFC3339, cellValue); err == nil {
 						fVal.Set(reflect.ValueOf(tm))
 					} else {
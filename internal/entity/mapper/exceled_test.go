@@ -122,3 +122,38 @@ func TestToExcelNonStruct(t *testing.T) {
 	}()
 	ToExcel[string](testString)
 }
+
+func TestToExcelUnreachable(t *testing.T) {
+	since := time.Date(2026, 9, 1, 12, 0, 0, 0, time.UTC)
+	users := []entity.User{
+		{ID: 1, UserName: "alice_user", Unreachable: true, UnreachableReason: entity.UnreachableBlocked, UnreachableSince: since},
+		{ID: 2, UserName: "bob_user"},
+	}
+	filename, err := ToExcel[entity.User](users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filename)
+
+	f, err := excelize.OpenFile(filename)
+	if err != nil {
+		t.Fatalf("Не удалось открыть файл: %v", err)
+	}
+	defer f.Close()
+	rows, _ := f.GetRows(f.GetSheetName(0))
+	header := strings.Join(rows[0], ",")
+	if !strings.HasSuffix(header, "Unreachable,UnreachableReason,UnreachableSince") {
+		t.Errorf("Ожидали колонки доступности пользователя, получили %v", rows[0])
+	}
+	if len(rows) < 3 || len(rows[2]) > len(rows[0])-1 {
+		t.Errorf("Ожидали пустую дату у доступного пользователя, получили %v", rows)
+	}
+
+	imported, err := FromExcel[entity.User](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(imported, users) {
+		t.Errorf("Ожидали %+v после импорта, получили %+v", users, imported)
+	}
+}
@@ -3,8 +3,12 @@ package mapper
 import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"main/internal/entity"
+	"unicode/utf8"
 )
 
+// captionLimit - максимальная длина подписи к файлу в Telegram
+const captionLimit = 1024
+
 func FilesToInterface(files []tgbotapi.FilePath) []interface{} {
 	result := make([]interface{}, len(files))
 	for i, file := range files {
@@ -13,11 +17,25 @@ func FilesToInterface(files []tgbotapi.FilePath) []interface{} {
 	return result
 }
 
+// fileData - файл для отправки: уже загруженный по FileID или локальный по пути
+func fileData(file entity.File) tgbotapi.RequestFileData {
+	if len(file.FileID) > 0 {
+		return tgbotapi.FileID(file.FileID)
+	}
+	return tgbotapi.FilePath(file.Filename)
+}
+
 func SentMessageToSend(message entity.MessageFromAdminBot) []tgbotapi.Chattable {
+	// один файл уходит одним сообщением с подписью: альбом из одного файла Telegram не принимает
+	if len(message.Files) == 1 && utf8.RuneCountInString(message.Text) <= captionLimit {
+		if single := singleFile(message.TelegramID, message.Files[0], message.Text); single != nil {
+			return []tgbotapi.Chattable{single}
+		}
+	}
 	filesInput := make([]interface{}, 0)
 	answer := make([]tgbotapi.Chattable, 0)
 	for _, file := range message.Files {
-		filePath := tgbotapi.FilePath(file.Filename)
+		filePath := fileData(file)
 		switch file.Type {
 		case entity.Doc:
 			filesInput = append(filesInput, tgbotapi.NewInputMediaDocument(filePath))
@@ -45,3 +63,35 @@ func SentMessageToSend(message entity.MessageFromAdminBot) []tgbotapi.Chattable
 	}
 	return answer
 }
+
+// singleFile - файл с подписью одним сообщением; nil для типов, у которых нет подписи
+func singleFile(chatID int64, file entity.File, caption string) tgbotapi.Chattable {
+	data := fileData(file)
+	switch file.Type {
+	case entity.Photo:
+		photo := tgbotapi.NewPhoto(chatID, data)
+		photo.Caption = caption
+		return photo
+	case entity.Doc:
+		document := tgbotapi.NewDocument(chatID, data)
+		document.Caption = caption
+		return document
+	case entity.Video:
+		video := tgbotapi.NewVideo(chatID, data)
+		video.Caption = caption
+		return video
+	case entity.Audio:
+		audio := tgbotapi.NewAudio(chatID, data)
+		audio.Caption = caption
+		return audio
+	case entity.Animation:
+		animation := tgbotapi.NewAnimation(chatID, data)
+		animation.Caption = caption
+		return animation
+	case entity.Voice:
+		voice := tgbotapi.NewVoice(chatID, data)
+		voice.Caption = caption
+		return voice
+	}
+	return nil
+}
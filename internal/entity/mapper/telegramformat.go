@@ -9,7 +9,24 @@ import (
 	"time"
 )
 
+// Экранирование значений для разметки Telegram
+var (
+	markdownV2Escaper = strings.NewReplacer("\\", "\\\\", "_", "\\_", "*", "\\*", "[", "\\[", "]", "\\]",
+		"(", "\\(", ")", "\\)", "~", "\\~", "`", "\\`", ">", "\\>")
+	markdownEscaper = strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[")
+)
+
+// TelegramFormat - поля структур «*Поле* - значение», значения экранированы для MarkdownV2
 func TelegramFormat[Anything any](array []Anything) (string, error) {
+	return telegramFormat(array, markdownV2Escaper)
+}
+
+// TelegramFormatMarkdown - как TelegramFormat, но значения экранированы для Markdown
+func TelegramFormatMarkdown[Anything any](array []Anything) (string, error) {
+	return telegramFormat(array, markdownEscaper)
+}
+
+func telegramFormat[Anything any](array []Anything, escaper *strings.Replacer) (string, error) {
 	if len(array) == 0 {
 		return "", errors.New("Нет данных.")
 	}
@@ -50,7 +67,9 @@ func TelegramFormat[Anything any](array []Anything) (string, error) {
 			case reflect.Bool:
 				value = strings.ToLower(fmt.Sprintf("%t", field.Bool()))
 			case reflect.Struct:
-				if tm, ok := field.Interface().(time.Time); ok {
+				if tm, ok := field.Interface().(time.Time); ok && tm.IsZero() {
+					value = "—"
+				} else if ok {
 					value = tm.Format("2006-01-02 15:04")
 				} else {
 					value = fmt.Sprintf("%v", field.Interface())
@@ -58,18 +77,7 @@ func TelegramFormat[Anything any](array []Anything) (string, error) {
 			default:
 				value = fmt.Sprintf("%v", field.Interface())
 			}
-			// Экранирование для MarkdownV2
-			value = strings.ReplaceAll(value, "\\", "\\\\")
-			value = strings.ReplaceAll(value, "_", "\\_")
-			value = strings.ReplaceAll(value, "*", "\\*")
-			value = strings.ReplaceAll(value, "[", "\\[")
-			value = strings.ReplaceAll(value, "]", "\\]")
-			value = strings.ReplaceAll(value, "(", "\\(")
-			value = strings.ReplaceAll(value, ")", "\\)")
-			value = strings.ReplaceAll(value, "~", "\\~")
-			value = strings.ReplaceAll(value, "`", "\\`")
-			value = strings.ReplaceAll(value, ">", "\\>")
-			line := fmt.Sprintf("*%s* - %s", header, value)
+			line := fmt.Sprintf("*%s* - %s", header, escaper.Replace(value))
 			lines = append(lines, line)
 		}
 		if len(lines) > 0 {
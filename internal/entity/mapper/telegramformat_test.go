@@ -39,3 +39,14 @@ func TestTelegramFormatEmpty(t *testing.T) {
 		t.Errorf("Ожидали 'Нет данных.' для пустого слайса, получили %s", markdown)
 	}
 }
+
+func TestTelegramFormatMarkdown(t *testing.T) {
+	codes := []entity.PromoCode{{ID: 1, Code: "NEW_YEAR(2027)"}}
+	markdown, _ := TelegramFormatMarkdown(codes)
+	if !strings.Contains(markdown, "*Code* - NEW\\_YEAR(2027)") {
+		t.Errorf("Ожидали экранирование для Markdown без лишних символов, получили %s", markdown)
+	}
+	if !strings.Contains(markdown, "*ExpiresAt* - —") {
+		t.Errorf("Ожидали прочерк вместо пустой даты, получили %s", markdown)
+	}
+}
@@ -15,6 +15,8 @@ const (
 type File struct {
 	Filename string
 	Type     TypeFile
+	// FileID - уже загруженный в Telegram файл; если задан, Filename не используется
+	FileID string
 }
 
 type MessageFromAdminBot struct {
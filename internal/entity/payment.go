@@ -3,10 +3,13 @@ package entity
 import "time"
 
 type Payment struct {
-	ID           int
-	UserID       int
-	Amount       int
-	TimeStamp    time.Time
-	Status       string
-	ReceiptPhoto string
+	ID            int
+	UserID        int
+	Amount        int
+	TimeStamp     time.Time
+	Status        string
+	ReceiptPhoto  string
+	ReceiptHash   string
+	ReceiptPHash  uint64
+	TransactionID string
 }
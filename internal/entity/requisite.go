@@ -1,9 +1,15 @@
 package entity
 
+import "time"
+
 type Requisite struct {
-	ID        int
-	Name      string
-	Content   string
-	PhotoData string
-	Link      string
+	ID         int
+	Name       string
+	Content    string
+	PhotoData  string
+	Link       string
+	Active     bool
+	InRotation bool
+	UsedCount  int
+	ActivateAt time.Time
 }
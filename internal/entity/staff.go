@@ -0,0 +1,72 @@
+package entity
+
+import "time"
+
+// Role - роль сотрудника в админ-боте
+type Role string
+
+const (
+	RoleOwner     Role = "owner"
+	RoleAdmin     Role = "admin"
+	RoleModerator Role = "moderator"
+	RoleSupport   Role = "support"
+	RoleAnalyst   Role = "analyst"
+)
+
+// Roles - все роли от старшей к младшей
+var Roles = []Role{RoleOwner, RoleAdmin, RoleModerator, RoleSupport, RoleAnalyst}
+
+// Rank - старшинство роли, 0 - роли нет
+func (r Role) Rank() int {
+	for i, role := range Roles {
+		if role == r {
+			return len(Roles) - i
+		}
+	}
+	return 0
+}
+
+// Includes - роль дает права required: старшая роль может все, что младшая
+func (r Role) Includes(required Role) bool {
+	return r.Rank() > 0 && r.Rank() >= required.Rank()
+}
+
+// Title - название роли для сообщений
+func (r Role) Title() string {
+	switch r {
+	case RoleOwner:
+		return "владелец"
+	case RoleAdmin:
+		return "администратор"
+	case RoleModerator:
+		return "модератор"
+	case RoleSupport:
+		return "поддержка"
+	case RoleAnalyst:
+		return "аналитик"
+	}
+	return string(r)
+}
+
+// Staff - сотрудник с доступом к админ-боту. TelegramID пустой,
+// пока выданный по username сотрудник ни разу не написал боту
+type Staff struct {
+	ID         int
+	TelegramID int64
+	UserName   string
+	Role       Role
+	GrantedBy  int64
+	GrantedAt  time.Time
+}
+
+// AuditEntry - запись журнала: выдача ролей и попытки доступа без прав
+type AuditEntry struct {
+	ID         int
+	TelegramID int64
+	UserName   string
+	Command    string
+	Action     string
+	Allowed    bool
+	Details    string
+	TimeStamp  time.Time
+}
@@ -10,4 +10,17 @@ type User struct {
 	UserTelegramId int64
 	FirstTime      time.Time
 	UserName       string
+	// Unreachable - бот не может писать пользователю: заблокировал бота или удалил аккаунт.
+	// Такие пользователи не попадают в рассылки, пока снова не запустят бота.
+	// Поля входят в выгрузку пользователей в Excel и восстанавливаются из нее
+	Unreachable       bool
+	UnreachableReason string
+	UnreachableSince  time.Time
 }
+
+// Причины, по которым пользователю нельзя написать
+const (
+	UnreachableBlocked     = "blocked"     // заблокировал бота
+	UnreachableDeactivated = "deactivated" // удалил аккаунт
+	UnreachableNotStarted  = "not_started" // не запускал бота
+)
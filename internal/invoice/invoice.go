@@ -0,0 +1,118 @@
+package invoice
+
+import (
+	"fmt"
+	"main/internal/entity"
+	"main/internal/qrcode"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var monthsGenitive = [...]string{"января", "февраля", "марта", "апреля", "мая", "июня",
+	"июля", "августа", "сентября", "октября", "ноября", "декабря"}
+
+// Buyer - реквизиты покупателя (плательщика по счету)
+type Buyer struct {
+	Name    string // Наименование организации или ФИО
+	INN     string // ИНН покупателя
+	KPP     string // КПП покупателя, у ИП и физлиц пусто
+	Address string // Юридический адрес
+}
+
+// Item - строка табличной части счета
+type Item struct {
+	Name     string  // Наименование товара (работы, услуги)
+	Quantity float64 // Количество
+	Unit     string  // Единица измерения
+	Price    float64 // Цена за единицу, ₽
+}
+
+// Sum - стоимость строки, ₽
+func (i Item) Sum() float64 {
+	return math.Round(i.Quantity*i.Price*100) / 100
+}
+
+// Invoice - счет на оплату
+type Invoice struct {
+	Number string
+	Date   time.Time
+	Seller qrcode.Payment // Наши реквизиты, из них же строится QR-код
+	Buyer  Buyer
+	Items  []Item
+}
+
+// FromTariff - счет на оплату одной подписки по тарифу
+func FromTariff(number string, date time.Time, seller qrcode.Payment, tariff entity.Tariff, buyer Buyer) *Invoice {
+	return &Invoice{
+		Number: number,
+		Date:   date,
+		Seller: seller,
+		Buyer:  buyer,
+		Items: []Item{{
+			Name:     fmt.Sprintf("Подписка «%s» на %d дн.", tariff.Name, tariff.DurationDays),
+			Quantity: 1,
+			Unit:     "усл.",
+			Price:    float64(tariff.Price),
+		}},
+	}
+}
+
+// Total - итоговая сумма по счету, ₽
+func (inv *Invoice) Total() float64 {
+	var total float64
+	for _, item := range inv.Items {
+		total += item.Sum()
+	}
+	return math.Round(total*100) / 100
+}
+
+// Title - заголовок счета: "Счет на оплату № 15 от 2 октября 2026 г."
+func (inv *Invoice) Title() string {
+	return "Счёт на оплату № " + inv.Number + " от " + formatDate(inv.Date)
+}
+
+// Purpose - назначение платежа для QR-кода и платежного поручения
+func (inv *Invoice) Purpose() string {
+	return "Оплата по счёту № " + inv.Number + " от " + inv.Date.Format("02.01.2006") + ". Без НДС"
+}
+
+// Payment - реквизиты для QR-кода с суммой и назначением из счета
+func (inv *Invoice) Payment() qrcode.Payment {
+	payment := inv.Seller
+	payment.Sum = inv.Total()
+	payment.Purpose = inv.Purpose()
+	return payment
+}
+
+// FileName - имя PDF-файла счета для отправки документом
+func (inv *Invoice) FileName() string {
+	return "invoice_" + strings.ReplaceAll(inv.Number, "/", "-") + ".pdf"
+}
+
+func formatDate(date time.Time) string {
+	return strconv.Itoa(date.Day()) + " " + monthsGenitive[date.Month()-1] + " " +
+		strconv.Itoa(date.Year()) + " г."
+}
+
+// formatMoney - сумма в формате счета: "4 800,23"
+func formatMoney(sum float64) string {
+	total := int64(math.Round(math.Abs(sum) * 100))
+	rubles := strconv.FormatInt(total/100, 10)
+	var groups []string
+	for len(rubles) > 3 {
+		groups = append([]string{rubles[len(rubles)-3:]}, groups...)
+		rubles = rubles[:len(rubles)-3]
+	}
+	groups = append([]string{rubles}, groups...)
+	result := strings.Join(groups, " ") + fmt.Sprintf(",%02d", total%100)
+	if sum < 0 {
+		return "-" + result
+	}
+	return result
+}
+
+func formatQuantity(quantity float64) string {
+	return strconv.FormatFloat(quantity, 'f', -1, 64)
+}
@@ -0,0 +1,76 @@
+package invoice
+
+import (
+	"bytes"
+	"main/internal/entity"
+	"main/internal/qrcode"
+	"testing"
+	"time"
+)
+
+func TestSumInWords(t *testing.T) {
+	cases := map[float64]string{
+		0:          "Ноль рублей 00 копеек",
+		1:          "Один рубль 00 копеек",
+		2.01:       "Два рубля 01 копейка",
+		11.12:      "Одиннадцать рублей 12 копеек",
+		21.22:      "Двадцать один рубль 22 копейки",
+		1000:       "Одна тысяча рублей 00 копеек",
+		2500:       "Две тысячи пятьсот рублей 00 копеек",
+		4800.23:    "Четыре тысячи восемьсот рублей 23 копейки",
+		11000:      "Одиннадцать тысяч рублей 00 копеек",
+		1000000:    "Один миллион рублей 00 копеек",
+		2021113.45: "Два миллиона двадцать одна тысяча сто тринадцать рублей 45 копеек",
+	}
+	for sum, expected := range cases {
+		if got := SumInWords(sum); got != expected {
+			t.Errorf("SumInWords(%v): ожидали '%s', получили '%s'", sum, expected, got)
+		}
+	}
+}
+
+func TestFormatMoney(t *testing.T) {
+	cases := map[float64]string{
+		0:          "0,00",
+		999.5:      "999,50",
+		4800.23:    "4 800,23",
+		1234567.89: "1 234 567,89",
+	}
+	for sum, expected := range cases {
+		if got := formatMoney(sum); got != expected {
+			t.Errorf("formatMoney(%v): ожидали '%s', получили '%s'", sum, expected, got)
+		}
+	}
+}
+
+func TestInvoicePdf(t *testing.T) {
+	seller := qrcode.Payment{
+		Name:        "ИП Иванов Иван Иванович",
+		PersonalAcc: "40802816900000000001",
+		BankName:    "ПАО Сбербанк",
+		BIC:         "044525225",
+		CorrespAcc:  "30101810400000000225",
+		PayeeINN:    "500100732259",
+	}
+	tariff := entity.Tariff{ID: 1, Name: "Месяц", Price: 4800, DurationDays: 30}
+	buyer := Buyer{Name: "ООО «Ромашка»", INN: "7707083893", KPP: "773601001"}
+	inv := FromTariff("15", time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC), seller, tariff, buyer)
+
+	if inv.Total() != 4800 {
+		t.Errorf("Ожидали итог 4800, получили %v", inv.Total())
+	}
+	if inv.Title() != "Счёт на оплату № 15 от 2 октября 2026 г." {
+		t.Errorf("Неверный заголовок: %s", inv.Title())
+	}
+	payment := inv.Payment()
+	if payment.Sum != 4800 || payment.Purpose != inv.Purpose() {
+		t.Errorf("В QR-код не попали сумма и назначение счета: %+v", payment)
+	}
+	data, err := inv.Pdf()
+	if err != nil {
+		t.Fatalf("Ошибка формирования PDF: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		t.Error("Результат не является PDF-документом")
+	}
+}
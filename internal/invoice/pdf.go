@@ -0,0 +1,210 @@
+package invoice
+
+import (
+	"bytes"
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"main/internal/qrcode"
+	"os"
+	"strconv"
+)
+
+const (
+	fontFamily = "Go"
+	pageWidth  = 180.0 // ширина области печати A4 с полями 15 мм
+	lineHeight = 5.0
+	qrSize     = 40.0
+)
+
+// Ширины колонок табличной части: №, наименование, кол-во, ед., цена, сумма
+var itemColumns = [...]float64{8, 92, 15, 15, 25, 25}
+
+// Pdf - формирует счет на оплату в формате PDF с QR-кодом ST00012 для оплаты
+func (inv *Invoice) Pdf() ([]byte, error) {
+	payment := inv.Payment()
+	qrPng, err := payment.Png(qrcode.UTF8, 512)
+	if err != nil {
+		return nil, err
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.AddUTF8FontFromBytes(fontFamily, "", goregular.TTF)
+	pdf.AddUTF8FontFromBytes(fontFamily, "B", gobold.TTF)
+	pdf.AddPage()
+
+	inv.writeBankTable(pdf)
+	inv.writeHeader(pdf)
+	inv.writeItems(pdf)
+	inv.writeTotals(pdf)
+	writeQRCode(pdf, qrPng)
+
+	if err := pdf.Error(); err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	if err := pdf.Output(&buffer); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// PdfFile - сохраняет счет на оплату в PDF-файл
+func (inv *Invoice) PdfFile(filename string) error {
+	data, err := inv.Pdf()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// writeBankTable - таблица банковских реквизитов получателя в шапке счета
+func (inv *Invoice) writeBankTable(pdf *fpdf.Fpdf) {
+	left, label, right := 105.0, 15.0, 60.0
+	seller := inv.Seller
+	pdf.SetFont(fontFamily, "", 9)
+
+	pdf.CellFormat(left, lineHeight*2, seller.BankName, "LTR", 0, "L", false, 0, "")
+	pdf.CellFormat(label, lineHeight*2, "БИК", "1", 0, "L", false, 0, "")
+	pdf.CellFormat(right, lineHeight*2, seller.BIC, "LTR", 1, "L", false, 0, "")
+
+	pdf.SetFont(fontFamily, "", 7)
+	pdf.CellFormat(left, lineHeight, "Банк получателя", "LBR", 0, "L", false, 0, "")
+	pdf.SetFont(fontFamily, "", 9)
+	pdf.CellFormat(label, lineHeight, "Сч. №", "LBR", 0, "L", false, 0, "")
+	pdf.CellFormat(right, lineHeight, seller.CorrespAcc, "LBR", 1, "L", false, 0, "")
+
+	pdf.CellFormat(left/2, lineHeight, "ИНН "+seller.PayeeINN, "1", 0, "L", false, 0, "")
+	pdf.CellFormat(left/2, lineHeight, "КПП "+seller.KPP, "1", 0, "L", false, 0, "")
+	pdf.CellFormat(label, lineHeight, "Сч. №", "LTR", 0, "L", false, 0, "")
+	pdf.CellFormat(right, lineHeight, seller.PersonalAcc, "LTR", 1, "L", false, 0, "")
+
+	pdf.CellFormat(left, lineHeight*2, seller.Name, "LR", 0, "L", false, 0, "")
+	pdf.CellFormat(label, lineHeight*2, "", "LR", 0, "L", false, 0, "")
+	pdf.CellFormat(right, lineHeight*2, "", "LR", 1, "L", false, 0, "")
+
+	pdf.SetFont(fontFamily, "", 7)
+	pdf.CellFormat(left, lineHeight, "Получатель", "LBR", 0, "L", false, 0, "")
+	pdf.CellFormat(label, lineHeight, "", "LBR", 0, "L", false, 0, "")
+	pdf.CellFormat(right, lineHeight, "", "LBR", 1, "L", false, 0, "")
+	pdf.Ln(lineHeight)
+}
+
+// writeHeader - заголовок счета, поставщик и покупатель
+func (inv *Invoice) writeHeader(pdf *fpdf.Fpdf) {
+	pdf.SetFont(fontFamily, "B", 14)
+	pdf.CellFormat(pageWidth, lineHeight*2, inv.Title(), "B", 1, "L", false, 0, "")
+	pdf.Ln(lineHeight)
+
+	seller := inv.Seller
+	writeParty(pdf, "Поставщик:", joinNonEmpty(seller.Name, innText(seller.PayeeINN), kppText(seller.KPP)))
+	writeParty(pdf, "Покупатель:", joinNonEmpty(inv.Buyer.Name, innText(inv.Buyer.INN),
+		kppText(inv.Buyer.KPP), inv.Buyer.Address))
+	pdf.Ln(lineHeight)
+}
+
+func writeParty(pdf *fpdf.Fpdf, label, text string) {
+	labelWidth := 25.0
+	pdf.SetFont(fontFamily, "", 9)
+	pdf.CellFormat(labelWidth, lineHeight, label, "", 0, "L", false, 0, "")
+	pdf.SetFont(fontFamily, "B", 9)
+	pdf.MultiCell(pageWidth-labelWidth, lineHeight, text, "", "L", false)
+	pdf.Ln(1)
+}
+
+// writeItems - табличная часть счета
+func (inv *Invoice) writeItems(pdf *fpdf.Fpdf) {
+	headers := [...]string{"№", "Товары (работы, услуги)", "Кол-во", "Ед.", "Цена", "Сумма"}
+	pdf.SetFont(fontFamily, "B", 9)
+	for i, header := range headers {
+		pdf.CellFormat(itemColumns[i], lineHeight+1, header, "1", 0, "C", false, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont(fontFamily, "", 9)
+	for i, item := range inv.Items {
+		lines := pdf.SplitText(item.Name, itemColumns[1]-2)
+		height := float64(len(lines)) * lineHeight
+		x, y := pdf.GetXY()
+		pdf.CellFormat(itemColumns[0], height, strconv.Itoa(i+1), "1", 0, "C", false, 0, "")
+		pdf.MultiCell(itemColumns[1], lineHeight, item.Name, "1", "L", false)
+		pdf.SetXY(x+itemColumns[0]+itemColumns[1], y)
+		pdf.CellFormat(itemColumns[2], height, formatQuantity(item.Quantity), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(itemColumns[3], height, item.Unit, "1", 0, "C", false, 0, "")
+		pdf.CellFormat(itemColumns[4], height, formatMoney(item.Price), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(itemColumns[5], height, formatMoney(item.Sum()), "1", 1, "R", false, 0, "")
+	}
+	pdf.Ln(2)
+}
+
+// writeTotals - итоги и сумма прописью
+func (inv *Invoice) writeTotals(pdf *fpdf.Fpdf) {
+	labelWidth := pageWidth - itemColumns[5]
+	total := formatMoney(inv.Total())
+	rows := [][2]string{
+		{"Итого:", total},
+		{"Без налога (НДС)", "-"},
+		{"Всего к оплате:", total},
+	}
+	pdf.SetFont(fontFamily, "B", 9)
+	for _, row := range rows {
+		pdf.CellFormat(labelWidth, lineHeight, row[0], "", 0, "R", false, 0, "")
+		pdf.CellFormat(itemColumns[5], lineHeight, row[1], "", 1, "R", false, 0, "")
+	}
+	pdf.Ln(2)
+
+	pdf.SetFont(fontFamily, "", 9)
+	pdf.CellFormat(pageWidth, lineHeight, "Всего наименований "+strconv.Itoa(len(inv.Items))+
+		", на сумму "+total+" руб.", "", 1, "L", false, 0, "")
+	pdf.SetFont(fontFamily, "B", 9)
+	pdf.MultiCell(pageWidth, lineHeight, SumInWords(inv.Total()), "B", "L", false)
+	pdf.Ln(lineHeight)
+}
+
+// writeQRCode - QR-код для оплаты с подписью
+func writeQRCode(pdf *fpdf.Fpdf, qrPng []byte) {
+	options := fpdf.ImageOptions{ImageType: "PNG"}
+	pdf.RegisterImageOptionsReader("payment_qr", options, bytes.NewReader(qrPng))
+	x, y := pdf.GetXY()
+	if y+qrSize > 297-15 {
+		pdf.AddPage()
+		x, y = pdf.GetXY()
+	}
+	pdf.ImageOptions("payment_qr", x, y, qrSize, qrSize, false, options, 0, "")
+	pdf.SetXY(x+qrSize+5, y+qrSize/2-lineHeight)
+	pdf.SetFont(fontFamily, "", 9)
+	pdf.MultiCell(pageWidth-qrSize-5, lineHeight,
+		"Для оплаты отсканируйте QR-код в мобильном приложении банка. "+
+			"Реквизиты, сумма и назначение платежа заполнятся автоматически.", "", "L", false)
+	pdf.SetXY(x, y+qrSize)
+}
+
+func joinNonEmpty(parts ...string) string {
+	result := ""
+	for _, part := range parts {
+		if len(part) == 0 {
+			continue
+		}
+		if len(result) > 0 {
+			result += ", "
+		}
+		result += part
+	}
+	return result
+}
+
+func innText(inn string) string {
+	if len(inn) == 0 {
+		return ""
+	}
+	return "ИНН " + inn
+}
+
+func kppText(kpp string) string {
+	if len(kpp) == 0 || kpp == "0" {
+		return ""
+	}
+	return "КПП " + kpp
+}
@@ -0,0 +1,125 @@
+package invoice
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	unitsMale = [...]string{"", "один", "два", "три", "четыре", "пять", "шесть", "семь", "восемь", "девять",
+		"десять", "одиннадцать", "двенадцать", "тринадцать", "четырнадцать", "пятнадцать",
+		"шестнадцать", "семнадцать", "восемнадцать", "девятнадцать"}
+	unitsFemale = [...]string{"", "одна", "две", "три", "четыре", "пять", "шесть", "семь", "восемь", "девять",
+		"десять", "одиннадцать", "двенадцать", "тринадцать", "четырнадцать", "пятнадцать",
+		"шестнадцать", "семнадцать", "восемнадцать", "девятнадцать"}
+	tens = [...]string{"", "", "двадцать", "тридцать", "сорок", "пятьдесят",
+		"шестьдесят", "семьдесят", "восемьдесят", "девяносто"}
+	hundreds = [...]string{"", "сто", "двести", "триста", "четыреста", "пятьсот",
+		"шестьсот", "семьсот", "восемьсот", "девятьсот"}
+)
+
+type numberScale struct {
+	forms  [3]string
+	female bool
+}
+
+// Разряды числа начиная с тысяч
+var scales = []numberScale{
+	{forms: [3]string{"тысяча", "тысячи", "тысяч"}, female: true},
+	{forms: [3]string{"миллион", "миллиона", "миллионов"}},
+	{forms: [3]string{"миллиард", "миллиарда", "миллиардов"}},
+}
+
+var (
+	rubleForms  = [3]string{"рубль", "рубля", "рублей"}
+	kopeckForms = [3]string{"копейка", "копейки", "копеек"}
+)
+
+const maxInWords = 999_999_999_999
+
+// Plural - выбирает форму слова для числа n: (1) рубль, (2) рубля, (5) рублей
+func Plural(n int64, forms [3]string) string {
+	n %= 100
+	if n >= 11 && n <= 14 {
+		return forms[2]
+	}
+	switch n % 10 {
+	case 1:
+		return forms[0]
+	case 2, 3, 4:
+		return forms[1]
+	default:
+		return forms[2]
+	}
+}
+
+func tripletInWords(n int64, female bool) []string {
+	var words []string
+	units := unitsMale
+	if female {
+		units = unitsFemale
+	}
+	if n/100 > 0 {
+		words = append(words, hundreds[n/100])
+	}
+	n %= 100
+	if n >= 20 {
+		words = append(words, tens[n/10])
+		n %= 10
+	}
+	if n > 0 {
+		words = append(words, units[n])
+	}
+	return words
+}
+
+// NumberInWords - записывает целое неотрицательное число прописью.
+// female задает род единиц: "одна", "две" вместо "один", "два"
+func NumberInWords(n int64, female bool) string {
+	if n == 0 {
+		return "ноль"
+	}
+	if n < 0 || n > maxInWords {
+		return "сумма вне допустимого диапазона"
+	}
+	var words []string
+	triplets := make([]int64, 0, len(scales)+1)
+	for rest := n; rest > 0; rest /= 1000 {
+		triplets = append(triplets, rest%1000)
+	}
+	for i := len(triplets) - 1; i >= 1; i-- {
+		if triplets[i] == 0 {
+			continue
+		}
+		scale := scales[i-1]
+		words = append(words, tripletInWords(triplets[i], scale.female)...)
+		words = append(words, Plural(triplets[i], scale.forms))
+	}
+	words = append(words, tripletInWords(triplets[0], female)...)
+	return strings.Join(words, " ")
+}
+
+// SumInWords - сумма в рублях прописью для счета:
+// "Четыре тысячи восемьсот рублей 23 копейки"
+func SumInWords(sum float64) string {
+	total := int64(math.Round(sum * 100))
+	if total < 0 {
+		total = -total
+	}
+	rubles, kopecks := total/100, total%100
+	result := fmt.Sprintf("%s %s %02d %s",
+		NumberInWords(rubles, false), Plural(rubles, rubleForms),
+		kopecks, Plural(kopecks, kopeckForms))
+	return capitalize(result)
+}
+
+func capitalize(text string) string {
+	r, size := utf8.DecodeRuneInString(text)
+	if r == utf8.RuneError {
+		return text
+	}
+	return string(unicode.ToUpper(r)) + text[size:]
+}
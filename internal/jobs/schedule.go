@@ -0,0 +1,205 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronHorizon - дальше этого срока следующий запуск не ищется: выражение вроде
+// «30 февраля» не выполняется никогда
+const cronHorizon = 5 * 366 * 24 * time.Hour
+
+// Schedule - когда запускать задачу
+type Schedule interface {
+	// Next - ближайший запуск строго после after; нулевое время - запусков больше не будет
+	Next(after time.Time) time.Time
+	String() string
+}
+
+// interval - запуск раз в period; моменты запуска кратны period от начала эпохи,
+// поэтому у всех реплик они совпадают
+type interval time.Duration
+
+// Every - запуск раз в period
+func Every(period time.Duration) Schedule {
+	if period <= 0 {
+		panic("jobs: period must be positive")
+	}
+	return interval(period)
+}
+
+func (i interval) Next(after time.Time) time.Time {
+	period := time.Duration(i)
+	return after.Truncate(period).Add(period)
+}
+
+func (i interval) String() string {
+	return "@every " + time.Duration(i).String()
+}
+
+// cron - расписание в формате crontab: минута, час, день месяца, месяц, день недели
+type cron struct {
+	spec                            string
+	minute, hour, day, month, wdays uint64
+	anyDay, anyWeekday              bool
+	location                        *time.Location
+}
+
+type cronField struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = cronField{min: 0, max: 59}
+	hourField   = cronField{min: 0, max: 23}
+	dayField    = cronField{min: 1, max: 31}
+	monthField  = cronField{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}}
+	// 7 - тоже воскресенье
+	weekdayField = cronField{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}}
+)
+
+var descriptors = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+}
+
+// Parse - расписание из строки: crontab из пяти полей («*/5 * * * *», «0 10 * * mon-fri»),
+// @hourly, @daily, @weekly, @monthly, @yearly или @every <интервал> («@every 90s»).
+// Время crontab считается в location
+func Parse(spec string, location *time.Location) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if period, ok := strings.CutPrefix(spec, "@every "); ok {
+		duration, err := time.ParseDuration(strings.TrimSpace(period))
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid interval %q", period)
+		}
+		return Every(duration), nil
+	}
+	expression := spec
+	if strings.HasPrefix(spec, "@") {
+		var ok bool
+		if expression, ok = descriptors[spec]; !ok {
+			return nil, fmt.Errorf("unknown schedule %q", spec)
+		}
+	}
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, errors.New("cron expression must have 5 fields: minute hour day month weekday")
+	}
+	schedule := cron{spec: spec, location: location}
+	var err error
+	for i, target := range []*uint64{&schedule.minute, &schedule.hour, &schedule.day, &schedule.month, &schedule.wdays} {
+		field := []cronField{minuteField, hourField, dayField, monthField, weekdayField}[i]
+		if *target, err = field.parse(strings.ToLower(fields[i])); err != nil {
+			return nil, fmt.Errorf("cron field %d %q: %w", i+1, fields[i], err)
+		}
+	}
+	// воскресенье 7 приводится к 0
+	if schedule.wdays&(1<<7) != 0 {
+		schedule.wdays = schedule.wdays&^(1<<7) | 1
+	}
+	schedule.anyDay = fields[2] == "*"
+	schedule.anyWeekday = fields[4] == "*"
+	return schedule, nil
+}
+
+// parse - список через запятую из *, чисел, диапазонов a-b и шагов */n, a-b/n
+func (f cronField) parse(text string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(text, ",") {
+		rangeText, stepText, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepText)
+			}
+		}
+		low, high := f.min, f.max
+		if rangeText != "*" {
+			lowText, highText, isRange := strings.Cut(rangeText, "-")
+			var err error
+			if low, err = f.value(lowText); err != nil {
+				return 0, err
+			}
+			high = low
+			if isRange {
+				if high, err = f.value(highText); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				high = f.max
+			}
+			if high < low {
+				return 0, fmt.Errorf("invalid range %q", rangeText)
+			}
+		}
+		for value := low; value <= high; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+func (f cronField) value(text string) (int, error) {
+	if value, ok := f.names[text]; ok {
+		return value, nil
+	}
+	value, err := strconv.Atoi(text)
+	if err != nil || value < f.min || value > f.max {
+		return 0, fmt.Errorf("value %q out of range %d-%d", text, f.min, f.max)
+	}
+	return value, nil
+}
+
+// Next - подбирает месяц, день, час и минуту по очереди, перескакивая неподходящие целиком
+func (c cron) Next(after time.Time) time.Time {
+	location := c.location
+	if location == nil {
+		location = time.Local
+	}
+	t := after.In(location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronHorizon)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, location)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, location)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			// сложение, а не time.Date: при переводе часов назад час повторяется
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches - как в crontab: если ограничены и день месяца, и день недели,
+// достаточно совпадения одного из них
+func (c cron) dayMatches(t time.Time) bool {
+	day := c.day&(1<<uint(t.Day())) != 0
+	weekday := c.wdays&(1<<uint(t.Weekday())) != 0
+	if c.anyDay || c.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+func (c cron) String() string {
+	return c.spec
+}
@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseNext(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := time.Date(2026, 3, 10, 10, 7, 30, 0, moscow) // вторник
+	cases := []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/5 * * * *", time.Date(2026, 3, 10, 10, 10, 0, 0, moscow)},
+		{"0 10 * * mon-fri", time.Date(2026, 3, 11, 10, 0, 0, 0, moscow)},
+		{"30 9,18 * * *", time.Date(2026, 3, 10, 18, 30, 0, 0, moscow)},
+		{"0 0 1 * *", time.Date(2026, 4, 1, 0, 0, 0, 0, moscow)},
+		{"0 12 * * 7", time.Date(2026, 3, 15, 12, 0, 0, 0, moscow)},
+		{"0 0 29 feb *", time.Date(2028, 2, 29, 0, 0, 0, 0, moscow)},
+		// день месяца или день недели: 13-е число или пятница
+		{"0 0 13 * fri", time.Date(2026, 3, 13, 0, 0, 0, 0, moscow)},
+		{"0 8 1-20/10 * *", time.Date(2026, 3, 11, 8, 0, 0, 0, moscow)},
+		{"@daily", time.Date(2026, 3, 11, 0, 0, 0, 0, moscow)},
+		{"@hourly", time.Date(2026, 3, 10, 11, 0, 0, 0, moscow)},
+	}
+	for _, c := range cases {
+		schedule, err := Parse(c.spec, moscow)
+		if err != nil {
+			t.Errorf("%q: ошибка разбора: %v", c.spec, err)
+			continue
+		}
+		if got := schedule.Next(after); !got.Equal(c.want) {
+			t.Errorf("%q: следующий запуск %v, ожидался %v", c.spec, got, c.want)
+		}
+		if schedule.String() != c.spec {
+			t.Errorf("%q: String() = %q", c.spec, schedule.String())
+		}
+	}
+}
+
+func TestParseNeverRuns(t *testing.T) {
+	schedule, err := Parse("0 0 30 feb *", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next := schedule.Next(time.Now()); !next.IsZero() {
+		t.Errorf("30 февраля не бывает, а следующий запуск %v", next)
+	}
+}
+
+func TestParseDaylightSaving(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedule, err := Parse("30 2 * * *", berlin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 29.03.2026 в 02:00 часы переводятся на 03:00, 02:30 не наступает
+	next := schedule.Next(time.Date(2026, 3, 29, 0, 0, 0, 0, berlin))
+	if want := time.Date(2026, 3, 30, 2, 30, 0, 0, berlin); !next.Equal(want) {
+		t.Errorf("следующий запуск %v, ожидался %v", next, want)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *",
+		"* * * * 8", "5-1 * * * *", "*/0 * * * *", "* * * foo *", "@sometimes", "@every -1m", "@every soon",
+	} {
+		if _, err := Parse(spec, time.UTC); err == nil {
+			t.Errorf("%q: ожидалась ошибка разбора", spec)
+		}
+	}
+}
+
+func TestEvery(t *testing.T) {
+	schedule, err := Parse("@every 15m", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := time.Date(2026, 1, 1, 10, 7, 0, 0, time.UTC)
+	// запуски кратны периоду, поэтому у всех реплик совпадают
+	if next := schedule.Next(after); !next.Equal(time.Date(2026, 1, 1, 10, 15, 0, 0, time.UTC)) {
+		t.Errorf("следующий запуск %v", next)
+	}
+	if next := schedule.Next(time.Date(2026, 1, 1, 10, 15, 0, 0, time.UTC)); !next.Equal(time.Date(2026, 1, 1, 10, 30, 0, 0, time.UTC)) {
+		t.Errorf("запуск должен быть строго позже: %v", next)
+	}
+	if schedule.String() != "@every 15m0s" {
+		t.Errorf("String() = %q", schedule.String())
+	}
+}
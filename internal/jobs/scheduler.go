@@ -0,0 +1,343 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"main/internal/database/lock"
+	"math/rand/v2"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// slotLockTTL - сколько живет блокировка запуска эксклюзивной задачи. Ключ блокировки
+// включает плановое время запуска, поэтому другие реплики пропускают этот запуск,
+// даже если он уже закончился
+const slotLockTTL = time.Hour
+
+var (
+	ErrJobExists      = errors.New("задача с таким именем уже есть")
+	ErrJobNotFound    = errors.New("задача не найдена")
+	ErrJobRunning     = errors.New("задача уже выполняется")
+	ErrSchedulerStart = errors.New("планировщик уже запущен")
+	// errSkipped - запуск выполнила другая реплика
+	errSkipped = errors.New("skipped")
+)
+
+// Job - фоновая задача
+type Job struct {
+	Name     string
+	Schedule Schedule
+	Run      func(ctx context.Context) error
+	// Jitter - случайная задержка до Jitter к каждому запуску, чтобы задачи
+	// с одинаковым расписанием не нагружали базу и Telegram одновременно
+	Jitter time.Duration
+	// Exclusive - при нескольких репликах запуск выполняет только одна из них.
+	// Задачи над памятью процесса, например очистка кнопок, эксклюзивными быть не должны
+	Exclusive bool
+}
+
+// Status - состояние задачи в этом процессе
+type Status struct {
+	Name         string
+	Schedule     string
+	Exclusive    bool
+	Paused       bool
+	Running      bool
+	NextRun      time.Time
+	LastRun      time.Time
+	LastDuration time.Duration
+	LastError    string
+	Runs         int
+	Failures     int
+	// Skipped - запусков, выполненных другой репликой
+	Skipped int
+}
+
+type entry struct {
+	job Job
+	// slot - плановое время запуска, next - с учетом jitter
+	slot    time.Time
+	next    time.Time
+	running bool
+	status  Status
+}
+
+// Scheduler - запуск фоновых задач по расписанию. Задача не запускается повторно,
+// пока не закончилось предыдущее выполнение; ошибки и паники записываются в статус
+type Scheduler struct {
+	locker lock.Locker
+	owner  string
+
+	mutex   sync.Mutex
+	jobs    map[string]*entry
+	wake    chan struct{}
+	ctx     context.Context
+	started bool
+	done    chan struct{}
+	wait    sync.WaitGroup
+	// now и jitter подменяются в тестах
+	now    func() time.Time
+	jitter func(max time.Duration) time.Duration
+}
+
+// NewScheduler - locker нужен для эксклюзивных задач при нескольких репликах;
+// nil - эксклюзивность только внутри процесса
+func NewScheduler(locker lock.Locker) *Scheduler {
+	hostname, _ := os.Hostname()
+	return &Scheduler{
+		locker: locker,
+		owner:  hostname + "-" + strconv.Itoa(os.Getpid()) + "-" + strconv.FormatUint(rand.Uint64(), 36),
+		jobs:   make(map[string]*entry),
+		wake:   make(chan struct{}, 1),
+		ctx:    context.Background(),
+		now:    time.Now,
+		jitter: func(max time.Duration) time.Duration {
+			return time.Duration(rand.Int64N(int64(max)))
+		},
+	}
+}
+
+// Add - добавляет задачу; можно и до, и после Run
+func (s *Scheduler) Add(job Job) error {
+	if len(job.Name) == 0 || job.Schedule == nil || job.Run == nil {
+		return errors.New("jobs: name, schedule and run are required")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.jobs[job.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrJobExists, job.Name)
+	}
+	e := &entry{job: job, status: Status{Name: job.Name, Schedule: job.Schedule.String(), Exclusive: job.Exclusive}}
+	s.plan(e)
+	s.jobs[job.Name] = e
+	s.notify()
+	return nil
+}
+
+// Jobs - добавленные задачи
+func (s *Scheduler) Jobs() []Job {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	jobs := make([]Job, 0, len(s.jobs))
+	for _, e := range s.jobs {
+		jobs = append(jobs, e.job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Name < jobs[j].Name })
+	return jobs
+}
+
+// Status - состояние задач по имени
+func (s *Scheduler) Status() []Status {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	statuses := make([]Status, 0, len(s.jobs))
+	for _, e := range s.jobs {
+		status := e.status
+		status.Running = e.running
+		status.NextRun = e.next
+		if status.Paused {
+			status.NextRun = time.Time{}
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// Pause - задача перестает запускаться по расписанию; Trigger продолжает работать
+func (s *Scheduler) Pause(name string) error {
+	return s.change(name, func(e *entry) {
+		e.status.Paused = true
+	})
+}
+
+// Resume - возобновляет запуски по расписанию со следующего планового времени
+func (s *Scheduler) Resume(name string) error {
+	return s.change(name, func(e *entry) {
+		e.status.Paused = false
+		s.plan(e)
+	})
+}
+
+// Trigger - запускает задачу сейчас, вне расписания и без блокировки между репликами
+func (s *Scheduler) Trigger(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	e, ok := s.jobs[name]
+	if !ok {
+		return ErrJobNotFound
+	}
+	if e.running {
+		return ErrJobRunning
+	}
+	e.running = true
+	s.launch(s.ctx, e, time.Time{})
+	return nil
+}
+
+func (s *Scheduler) change(name string, change func(e *entry)) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	e, ok := s.jobs[name]
+	if !ok {
+		return ErrJobNotFound
+	}
+	change(e)
+	s.notify()
+	return nil
+}
+
+// Run - запускает задачи до отмены ctx, затем ждет завершения выполняющихся.
+// Задачи получают ctx и должны прерываться по его отмене
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mutex.Lock()
+	if s.started {
+		s.mutex.Unlock()
+		return ErrSchedulerStart
+	}
+	s.started = true
+	s.ctx = ctx
+	s.done = make(chan struct{})
+	s.mutex.Unlock()
+	defer func() {
+		s.wait.Wait()
+		s.mutex.Lock()
+		s.started = false
+		s.ctx = context.Background()
+		close(s.done)
+		s.mutex.Unlock()
+	}()
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		s.mutex.Lock()
+		now := s.now()
+		var next time.Time
+		for _, e := range s.jobs {
+			if e.status.Paused || e.running || e.next.IsZero() {
+				continue
+			}
+			if !e.next.After(now) {
+				e.running = true
+				s.launch(ctx, e, e.slot)
+				continue
+			}
+			if next.IsZero() || e.next.Before(next) {
+				next = e.next
+			}
+		}
+		s.mutex.Unlock()
+
+		wait := time.Hour
+		if !next.IsZero() {
+			wait = next.Sub(now)
+		}
+		timer.Reset(wait)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+		case <-s.wake:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// Wait - ждет остановки Run, но не дольше ctx. Если Run не запускался, возвращается сразу
+func (s *Scheduler) Wait(ctx context.Context) error {
+	s.mutex.Lock()
+	done := s.done
+	s.mutex.Unlock()
+	if done == nil {
+		return nil
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// launch - выполняет задачу в горутине; вызывается под s.mutex. slot - плановое время,
+// нулевое для запуска вручную
+func (s *Scheduler) launch(ctx context.Context, e *entry, slot time.Time) {
+	s.wait.Add(1)
+	go func() {
+		defer s.wait.Done()
+		started := s.now()
+		err := s.execute(ctx, e.job, slot)
+		finished := s.now()
+
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		e.running = false
+		switch {
+		case errors.Is(err, errSkipped):
+			e.status.Skipped++
+		default:
+			e.status.Runs++
+			e.status.LastRun = started
+			e.status.LastDuration = finished.Sub(started)
+			e.status.LastError = ""
+			if err != nil {
+				e.status.Failures++
+				e.status.LastError = err.Error()
+				slog.Error("job failed", "job", e.job.Name, "error", err)
+			}
+		}
+		if !slot.IsZero() {
+			s.plan(e)
+		}
+		s.notify()
+	}()
+}
+
+// execute - эксклюзивная задача сначала захватывает плановый запуск
+func (s *Scheduler) execute(ctx context.Context, job Job, slot time.Time) (err error) {
+	if job.Exclusive && s.locker != nil && !slot.IsZero() {
+		key := "jobs:" + job.Name + ":" + strconv.FormatInt(slot.Unix(), 10)
+		acquired, err := s.locker.Acquire(key, s.owner, slotLockTTL)
+		if err != nil {
+			return fmt.Errorf("lock: %w", err)
+		}
+		if !acquired {
+			return errSkipped
+		}
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("job panic", "job", job.Name, "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+// plan - следующий запуск после текущего момента; вызывается под s.mutex
+func (s *Scheduler) plan(e *entry) {
+	e.slot = e.job.Schedule.Next(s.now())
+	e.next = e.slot
+	if !e.slot.IsZero() && e.job.Jitter > 0 {
+		e.next = e.slot.Add(s.jitter(e.job.Jitter))
+	}
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,164 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"main/internal/database/lock/memorylock"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startScheduler - запускает планировщик до конца теста
+func startScheduler(t *testing.T, scheduler *Scheduler) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- scheduler.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	})
+}
+
+func waitStatus(t *testing.T, scheduler *Scheduler, name string, ok func(status Status) bool) Status {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, status := range scheduler.Status() {
+			if status.Name == name && ok(status) {
+				return status
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("задача %s не дошла до нужного состояния: %+v", name, scheduler.Status())
+	return Status{}
+}
+
+func TestSchedulerRunsJobs(t *testing.T) {
+	scheduler := NewScheduler(nil)
+	var runs atomic.Int32
+	err := scheduler.Add(Job{Name: "tick", Schedule: Every(20 * time.Millisecond), Run: func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := scheduler.Add(Job{Name: "tick", Schedule: Every(time.Minute), Run: func(ctx context.Context) error { return nil }}); !errors.Is(err, ErrJobExists) {
+		t.Errorf("повторное имя задачи: %v", err)
+	}
+	startScheduler(t, scheduler)
+	status := waitStatus(t, scheduler, "tick", func(status Status) bool { return status.Runs >= 3 })
+	if status.LastRun.IsZero() || status.NextRun.IsZero() || status.Failures != 0 {
+		t.Errorf("неверный статус: %+v", status)
+	}
+	if err := scheduler.Run(context.Background()); !errors.Is(err, ErrSchedulerStart) {
+		t.Errorf("второй Run: %v", err)
+	}
+}
+
+func TestSchedulerRecordsErrors(t *testing.T) {
+	scheduler := NewScheduler(nil)
+	_ = scheduler.Add(Job{Name: "fail", Schedule: Every(20 * time.Millisecond), Run: func(ctx context.Context) error {
+		return errors.New("база недоступна")
+	}})
+	_ = scheduler.Add(Job{Name: "panic", Schedule: Every(20 * time.Millisecond), Run: func(ctx context.Context) error {
+		panic("boom")
+	}})
+	startScheduler(t, scheduler)
+	status := waitStatus(t, scheduler, "fail", func(status Status) bool { return status.Failures >= 2 })
+	if status.LastError != "база недоступна" {
+		t.Errorf("последняя ошибка %q", status.LastError)
+	}
+	// паника не останавливает планировщик, задача запускается снова
+	status = waitStatus(t, scheduler, "panic", func(status Status) bool { return status.Failures >= 2 })
+	if !strings.Contains(status.LastError, "boom") {
+		t.Errorf("последняя ошибка %q", status.LastError)
+	}
+}
+
+func TestSchedulerPauseResumeTrigger(t *testing.T) {
+	scheduler := NewScheduler(nil)
+	var runs atomic.Int32
+	_ = scheduler.Add(Job{Name: "report", Schedule: Every(time.Hour), Run: func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}})
+	startScheduler(t, scheduler)
+	if err := scheduler.Pause("report"); err != nil {
+		t.Fatal(err)
+	}
+	status := waitStatus(t, scheduler, "report", func(status Status) bool { return status.Paused })
+	if !status.NextRun.IsZero() {
+		t.Errorf("у задачи на паузе есть следующий запуск %v", status.NextRun)
+	}
+	// запуск вручную работает и на паузе
+	if err := scheduler.Trigger("report"); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, scheduler, "report", func(status Status) bool { return status.Runs == 1 && !status.Running })
+	if err := scheduler.Resume("report"); err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, scheduler, "report", func(status Status) bool { return !status.Paused && !status.NextRun.IsZero() })
+	if err := scheduler.Trigger("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("запуск несуществующей задачи: %v", err)
+	}
+}
+
+func TestSchedulerTriggerRunning(t *testing.T) {
+	scheduler := NewScheduler(nil)
+	release := make(chan struct{})
+	_ = scheduler.Add(Job{Name: "slow", Schedule: Every(time.Hour), Run: func(ctx context.Context) error {
+		<-release
+		return nil
+	}})
+	if err := scheduler.Trigger("slow"); err != nil {
+		t.Fatal(err)
+	}
+	if err := scheduler.Trigger("slow"); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("повторный запуск выполняющейся задачи: %v", err)
+	}
+	close(release)
+	waitStatus(t, scheduler, "slow", func(status Status) bool { return status.Runs == 1 && !status.Running })
+}
+
+func TestSchedulerExclusive(t *testing.T) {
+	locker := memorylock.InitMemoryLock()
+	var runs atomic.Int32
+	replicas := []*Scheduler{NewScheduler(locker), NewScheduler(locker)}
+	for _, scheduler := range replicas {
+		_ = scheduler.Add(Job{Name: "cleanup", Schedule: Every(50 * time.Millisecond), Exclusive: true,
+			Run: func(ctx context.Context) error {
+				runs.Add(1)
+				return nil
+			}})
+		startScheduler(t, scheduler)
+	}
+	waitStatus(t, replicas[0], "cleanup", func(status Status) bool { return status.Runs+status.Skipped >= 4 })
+	waitStatus(t, replicas[1], "cleanup", func(status Status) bool { return status.Runs+status.Skipped >= 4 })
+	first, second := replicas[0].Status()[0], replicas[1].Status()[0]
+	if first.Skipped+second.Skipped == 0 {
+		t.Errorf("ни один запуск не пропущен: %+v %+v", first, second)
+	}
+	if total := int(runs.Load()); total > first.Runs+second.Runs {
+		t.Errorf("выполнено %d запусков, в статусах %d", total, first.Runs+second.Runs)
+	}
+}
+
+func TestSchedulerJitter(t *testing.T) {
+	scheduler := NewScheduler(nil)
+	scheduler.now = func() time.Time { return time.Date(2026, 1, 1, 10, 0, 30, 0, time.UTC) }
+	scheduler.jitter = func(max time.Duration) time.Duration { return max / 2 }
+	_ = scheduler.Add(Job{Name: "gc", Schedule: Every(time.Minute), Jitter: 20 * time.Second,
+		Run: func(ctx context.Context) error { return nil }})
+	status := scheduler.Status()[0]
+	if want := time.Date(2026, 1, 1, 10, 1, 10, 0, time.UTC); !status.NextRun.Equal(want) {
+		t.Errorf("следующий запуск %v, ожидался %v", status.NextRun, want)
+	}
+}
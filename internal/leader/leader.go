@@ -0,0 +1,186 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"main/internal/database/lock"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultTTL - срок аренды по умолчанию: столько самое большее реплики остаются без ведущего,
+// если ведущий упал, не освободив аренду
+const DefaultTTL = 15 * time.Second
+
+// Elector - выбор ведущей реплики через аренду ключа в Locker. Ведущий продлевает аренду
+// каждую треть ttl; если продлить не удалось, он перестает быть ведущим раньше, чем
+// аренда истечет, и ее захватывает другая реплика
+type Elector struct {
+	locker lock.Locker
+	key    string
+	owner  string
+	ttl    time.Duration
+	// OnChange - вызывается при получении и потере лидерства
+	OnChange func(leader bool)
+
+	mutex sync.Mutex
+	// term - отменяется при потере лидерства; nil - реплика не ведущая
+	term    context.Context
+	stop    context.CancelFunc
+	changed chan struct{}
+	now     func() time.Time
+}
+
+// NewElector - ttl <= 0 - DefaultTTL
+func NewElector(locker lock.Locker, key string, ttl time.Duration) *Elector {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	hostname, _ := os.Hostname()
+	return &Elector{
+		locker:  locker,
+		key:     key,
+		owner:   hostname + "-" + strconv.Itoa(os.Getpid()) + "-" + strconv.FormatUint(rand.Uint64(), 36),
+		ttl:     ttl,
+		changed: make(chan struct{}),
+		now:     time.Now,
+	}
+}
+
+// Owner - идентификатор реплики в аренде
+func (e *Elector) Owner() string {
+	return e.owner
+}
+
+// IsLeader - ведущая ли реплика сейчас
+func (e *Elector) IsLeader() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.term != nil
+}
+
+// Run - борется за аренду до отмены ctx, затем освобождает ее, чтобы другая реплика
+// стала ведущей сразу, не дожидаясь истечения ttl
+func (e *Elector) Run(ctx context.Context) error {
+	period := e.ttl / 3
+	var renewed time.Time
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if e.IsLeader() {
+				e.resign()
+				if err := e.locker.Release(e.key, e.owner); err != nil {
+					slog.Warn("leader: release", "key", e.key, "error", err)
+				}
+			}
+			return nil
+		case <-timer.C:
+		}
+		if e.IsLeader() {
+			ok, err := e.locker.Refresh(e.key, e.owner, e.ttl)
+			switch {
+			case err == nil && ok:
+				renewed = e.now()
+			case err == nil:
+				slog.Warn("leader: lease lost", "key", e.key, "owner", e.owner)
+				e.resign()
+			case e.now().Sub(renewed) >= e.ttl-period:
+				// следующая попытка может опоздать: аренда истечет раньше
+				slog.Error("leader: lease not renewed", "key", e.key, "error", err)
+				e.resign()
+			default:
+				slog.Warn("leader: renew", "key", e.key, "error", err)
+			}
+		} else {
+			ok, err := e.locker.Acquire(e.key, e.owner, e.ttl)
+			if err != nil {
+				slog.Warn("leader: acquire", "key", e.key, "error", err)
+			} else if ok {
+				renewed = e.now()
+				e.elect(ctx)
+			}
+		}
+		timer.Reset(period)
+	}
+}
+
+// Lead - выполняет runner, пока реплика ведущая: runner получает контекст, который
+// отменяется при потере лидерства или отмене ctx, и запускается снова при следующем
+// избрании. Ошибка runner во время лидерства возвращается сразу
+func (e *Elector) Lead(ctx context.Context, runner func(ctx context.Context) error) error {
+	for {
+		term, ok := e.wait(ctx)
+		if !ok {
+			return nil
+		}
+		runCtx, cancel := context.WithCancel(ctx)
+		stop := context.AfterFunc(term, cancel)
+		err := runner(runCtx)
+		stop()
+		cancel()
+		if ctx.Err() != nil {
+			return err
+		}
+		if term.Err() == nil {
+			if err == nil {
+				err = errors.New("leader: process stopped while leading")
+			}
+			return err
+		}
+		if err != nil {
+			slog.Warn("leader: process stopped with error after losing lease", "error", err)
+		}
+	}
+}
+
+// wait - ждет лидерства; false - ctx отменен раньше
+func (e *Elector) wait(ctx context.Context) (context.Context, bool) {
+	for {
+		e.mutex.Lock()
+		term, changed := e.term, e.changed
+		e.mutex.Unlock()
+		if term != nil {
+			return term, true
+		}
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-changed:
+		}
+	}
+}
+
+func (e *Elector) elect(ctx context.Context) {
+	e.mutex.Lock()
+	e.term, e.stop = context.WithCancel(ctx)
+	e.notify()
+	e.mutex.Unlock()
+	slog.Info("leader: elected", "key", e.key, "owner", e.owner)
+	if e.OnChange != nil {
+		e.OnChange(true)
+	}
+}
+
+func (e *Elector) resign() {
+	e.mutex.Lock()
+	e.stop()
+	e.term, e.stop = nil, nil
+	e.notify()
+	e.mutex.Unlock()
+	slog.Info("leader: resigned", "key", e.key, "owner", e.owner)
+	if e.OnChange != nil {
+		e.OnChange(false)
+	}
+}
+
+// notify - будит ожидающих в wait; вызывается под e.mutex
+func (e *Elector) notify() {
+	close(e.changed)
+	e.changed = make(chan struct{})
+}
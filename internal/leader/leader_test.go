@@ -0,0 +1,163 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"main/internal/database/lock"
+	"main/internal/database/lock/memorylock"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testTTL = 90 * time.Millisecond
+
+// flakyLocker - Redis, до которого реплика может потерять связь
+type flakyLocker struct {
+	lock.Locker
+	broken atomic.Bool
+}
+
+var errConnection = errors.New("connection refused")
+
+func (f *flakyLocker) Acquire(key, owner string, ttl time.Duration) (bool, error) {
+	if f.broken.Load() {
+		return false, errConnection
+	}
+	return f.Locker.Acquire(key, owner, ttl)
+}
+
+func (f *flakyLocker) Refresh(key, owner string, ttl time.Duration) (bool, error) {
+	if f.broken.Load() {
+		return false, errConnection
+	}
+	return f.Locker.Refresh(key, owner, ttl)
+}
+
+func startElector(t *testing.T, elector *Elector) context.CancelFunc {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = elector.Run(ctx)
+		close(done)
+	}()
+	stop := func() {
+		cancel()
+		<-done
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+func waitLeader(t *testing.T, elector *Elector, leader bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for elector.IsLeader() != leader {
+		if time.Now().After(deadline) {
+			t.Fatalf("реплика %s: ожидалось лидерство %v", elector.Owner(), leader)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSingleLeader(t *testing.T) {
+	locker := memorylock.InitMemoryLock()
+	first := NewElector(locker, "leader", testTTL)
+	second := NewElector(locker, "leader", testTTL)
+	startElector(t, first)
+	waitLeader(t, first, true)
+	startElector(t, second)
+	// несколько продлений аренды: ведущий не меняется
+	time.Sleep(3 * testTTL)
+	if !first.IsLeader() || second.IsLeader() {
+		t.Errorf("ведущая должна быть одна: первая %v, вторая %v", first.IsLeader(), second.IsLeader())
+	}
+}
+
+func TestFailoverOnRelease(t *testing.T) {
+	locker := memorylock.InitMemoryLock()
+	first := NewElector(locker, "leader", time.Minute)
+	second := NewElector(locker, "leader", 30*time.Millisecond)
+	stopFirst := startElector(t, first)
+	waitLeader(t, first, true)
+	startElector(t, second)
+	// остановленная реплика освобождает аренду, не дожидаясь ее истечения
+	stopFirst()
+	if first.IsLeader() {
+		t.Error("остановленная реплика осталась ведущей")
+	}
+	waitLeader(t, second, true)
+}
+
+func TestFailoverOnLeaseExpiry(t *testing.T) {
+	memory := memorylock.InitMemoryLock()
+	isolated := &flakyLocker{Locker: memory}
+	first := NewElector(isolated, "leader", testTTL)
+	second := NewElector(memory, "leader", testTTL)
+	changes := make(chan bool, 4)
+	first.OnChange = func(leader bool) { changes <- leader }
+	startElector(t, first)
+	waitLeader(t, first, true)
+	startElector(t, second)
+
+	isolated.broken.Store(true)
+	waitLeader(t, first, false)
+	// бывший ведущий перестает им быть до истечения аренды, поэтому ведущих не бывает двое
+	if second.IsLeader() {
+		t.Error("вторая реплика стала ведущей раньше, чем первая сложила полномочия")
+	}
+	waitLeader(t, second, true)
+	if first.IsLeader() {
+		t.Error("ведущих две")
+	}
+	if <-changes != true || <-changes != false {
+		t.Error("OnChange: ожидались избрание и потеря лидерства")
+	}
+}
+
+func TestLeadRestartsOnReelection(t *testing.T) {
+	locker := &flakyLocker{Locker: memorylock.InitMemoryLock()}
+	elector := NewElector(locker, "leader", testTTL)
+	var terms atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- elector.Lead(ctx, func(ctx context.Context) error {
+			terms.Add(1)
+			<-ctx.Done()
+			return nil
+		})
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if terms.Load() != 0 {
+		t.Fatal("процесс запущен до избрания")
+	}
+	startElector(t, elector)
+	waitLeader(t, elector, true)
+	locker.broken.Store(true)
+	waitLeader(t, elector, false)
+	locker.broken.Store(false)
+	waitLeader(t, elector, true)
+	deadline := time.Now().Add(time.Second)
+	for terms.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if terms.Load() != 2 {
+		t.Errorf("процесс запущен %d раз, ожидалось 2", terms.Load())
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Lead: %v", err)
+	}
+}
+
+func TestLeadReturnsProcessError(t *testing.T) {
+	elector := NewElector(memorylock.InitMemoryLock(), "leader", testTTL)
+	startElector(t, elector)
+	boom := errors.New("boom")
+	err := elector.Lead(context.Background(), func(ctx context.Context) error { return boom })
+	if !errors.Is(err, boom) {
+		t.Errorf("ожидалась ошибка процесса, получили %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrShutdownTimeout - часть процессов не остановилась до дедлайна
+var ErrShutdownTimeout = errors.New("shutdown timeout")
+
+// Runner - процесс, работающий до отмены ctx: бот, сервер webhook, обработчик очереди.
+// После отмены должен дообработать начатое и вернуться
+type Runner func(ctx context.Context) error
+
+// Stopper - освобождение ресурса после остановки процессов; ctx - дедлайн остановки
+type Stopper func(ctx context.Context) error
+
+type task[F any] struct {
+	name string
+	f    F
+}
+
+// Lifecycle - запуск процессов приложения и их остановка по сигналу.
+// Остановка: отмена контекста процессов, ожидание их завершения, затем Stopper
+// в порядке регистрации. На всю остановку отводится timeout
+type Lifecycle struct {
+	timeout  time.Duration
+	signals  []os.Signal
+	runners  []task[Runner]
+	stoppers []task[Stopper]
+}
+
+func NewLifecycle(timeout time.Duration) *Lifecycle {
+	return &Lifecycle{timeout: timeout, signals: []os.Signal{os.Interrupt, syscall.SIGTERM}}
+}
+
+// Go - процесс, запускаемый в Run
+func (l *Lifecycle) Go(name string, runner Runner) *Lifecycle {
+	l.runners = append(l.runners, task[Runner]{name: name, f: runner})
+	return l
+}
+
+// OnStop - действие после остановки всех процессов. Действия выполняются
+// в порядке регистрации: сначала то, что пользуется соединениями, затем сами соединения
+func (l *Lifecycle) OnStop(name string, stopper Stopper) *Lifecycle {
+	l.stoppers = append(l.stoppers, task[Stopper]{name: name, f: stopper})
+	return l
+}
+
+// Close - закрытие соединения (Redis, база данных) после остановки процессов
+func (l *Lifecycle) Close(name string, closer io.Closer) *Lifecycle {
+	return l.OnStop(name, func(ctx context.Context) error {
+		return closer.Close()
+	})
+}
+
+// Run - запускает процессы и ждет SIGINT/SIGTERM, отмены ctx или ошибки любого процесса,
+// после чего останавливает приложение. Возвращает ошибки процессов и остановки
+func (l *Lifecycle) Run(ctx context.Context) error {
+	ctx, stopSignals := signal.NotifyContext(ctx, l.signals...)
+	defer stopSignals()
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mutex sync.Mutex
+		errs  []error
+		wait  sync.WaitGroup
+	)
+	fail := func(err error) {
+		mutex.Lock()
+		errs = append(errs, err)
+		mutex.Unlock()
+	}
+	for _, runner := range l.runners {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					slog.Error("lifecycle: panic", "process", runner.name, "panic", fmt.Sprint(r),
+						"stack", string(debug.Stack()))
+					fail(fmt.Errorf("%s: panic: %v", runner.name, r))
+					cancel()
+				}
+			}()
+			if err := runner.f(runCtx); err != nil {
+				slog.Error("lifecycle: process failed", "process", runner.name, "error", err)
+				fail(fmt.Errorf("%s: %w", runner.name, err))
+				cancel()
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wait.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-runCtx.Done():
+	case <-done:
+	}
+	slog.Info("lifecycle: stopping")
+	cancel()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), l.timeout)
+	defer cancelShutdown()
+	select {
+	case <-done:
+	case <-shutdownCtx.Done():
+		slog.Error("lifecycle: processes did not stop in time", "timeout", l.timeout)
+		fail(ErrShutdownTimeout)
+	}
+	for _, stopper := range l.stoppers {
+		if err := stopper.f(shutdownCtx); err != nil {
+			slog.Error("lifecycle: stop failed", "resource", stopper.name, "error", err)
+			fail(fmt.Errorf("%s: %w", stopper.name, err))
+		}
+	}
+	slog.Info("lifecycle: stopped")
+	mutex.Lock()
+	defer mutex.Unlock()
+	return errors.Join(errs...)
+}
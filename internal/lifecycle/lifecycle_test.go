@@ -0,0 +1,97 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunStopsInOrder(t *testing.T) {
+	var (
+		mutex sync.Mutex
+		calls []string
+	)
+	record := func(name string) {
+		mutex.Lock()
+		calls = append(calls, name)
+		mutex.Unlock()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	life := NewLifecycle(time.Second).
+		Go("bot", func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			// дообработка начатого после отмены
+			time.Sleep(10 * time.Millisecond)
+			record("bot")
+			return nil
+		}).
+		OnStop("goroutines", func(ctx context.Context) error {
+			record("goroutines")
+			return nil
+		}).
+		OnStop("redis", func(ctx context.Context) error {
+			record("redis")
+			return nil
+		})
+	go func() {
+		<-started
+		cancel()
+	}()
+	if err := life.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"bot", "goroutines", "redis"}
+	if strings.Join(calls, ",") != strings.Join(expected, ",") {
+		t.Errorf("ожидали порядок %v, получили %v", expected, calls)
+	}
+}
+
+func TestRunStopsOnFailure(t *testing.T) {
+	stopped := false
+	boom := errors.New("boom")
+	life := NewLifecycle(time.Second).
+		Go("failing", func(ctx context.Context) error { return boom }).
+		Go("worker", func(ctx context.Context) error {
+			<-ctx.Done()
+			stopped = true
+			return nil
+		})
+	err := life.Run(context.Background())
+	if !errors.Is(err, boom) {
+		t.Errorf("ожидали ошибку процесса, получили %v", err)
+	}
+	if !stopped {
+		t.Errorf("ошибка одного процесса должна останавливать остальные")
+	}
+}
+
+func TestRunShutdownTimeout(t *testing.T) {
+	closed := false
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	life := NewLifecycle(20*time.Millisecond).
+		Go("stuck", func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		}).
+		OnStop("redis", func(ctx context.Context) error {
+			closed = true
+			return nil
+		})
+	start := time.Now()
+	err := life.Run(ctx)
+	if !errors.Is(err, ErrShutdownTimeout) {
+		t.Errorf("ожидали ErrShutdownTimeout, получили %v", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("остановка не должна ждать дольше дедлайна")
+	}
+	if !closed {
+		t.Errorf("соединения закрываются и после дедлайна")
+	}
+}
@@ -0,0 +1,92 @@
+package qrcode
+
+import (
+	"errors"
+	"strconv"
+	"unicode/utf8"
+)
+
+const (
+	formatID      = "ST"   // Идентификатор формата ГОСТ Р 56042-2014
+	formatVersion = "0001" // Версия стандарта
+	headerLength  = len(formatID) + len(formatVersion) + 1
+)
+
+// payloadHeader - служебный блок QR-кода: ST0001, кодировка и символ-разделитель
+type payloadHeader struct {
+	version   string
+	codePage  codePage
+	separator byte
+}
+
+// parseHeader - разбирает служебный блок вида "ST00012|"
+func parseHeader(payload []byte) (payloadHeader, error) {
+	if len(payload) <= headerLength {
+		return payloadHeader{}, errors.New("QR payload is too short for ST0001x header")
+	}
+	if string(payload[:len(formatID)]) != formatID {
+		return payloadHeader{}, errors.New("Is not a QR code requisite text")
+	}
+	header := payloadHeader{
+		version:   string(payload[len(formatID) : headerLength-1]),
+		separator: payload[headerLength],
+	}
+	if header.version != formatVersion {
+		return payloadHeader{}, errors.New("unsupported format version " + strconv.Quote(header.version))
+	}
+	switch c := codePage(payload[headerLength-1] - '0'); c {
+	case Windows1251, UTF8, KOI8R:
+		header.codePage = c
+	default:
+		return payloadHeader{}, errors.New("unknown codepage " + strconv.Quote(string(payload[headerLength-1])))
+	}
+	if !validSeparator(header.separator) {
+		return payloadHeader{}, errors.New("invalid separator " + strconv.Quote(string(header.separator)))
+	}
+	return header, nil
+}
+
+// validSeparator - разделитель должен быть печатным ASCII-символом,
+// который не может встретиться в имени реквизита
+func validSeparator(separator byte) bool {
+	if separator <= ' ' || separator >= 0x7f || separator == '=' {
+		return false
+	}
+	isLetter := (separator|0x20) >= 'a' && (separator|0x20) <= 'z'
+	isDigit := separator >= '0' && separator <= '9'
+	return !isLetter && !isDigit
+}
+
+// decode - переводит тело QR-кода из заявленной в заголовке кодировки в UTF-8
+func (h payloadHeader) decode(body []byte) (string, error) {
+	switch h.codePage {
+	case UTF8:
+		if !utf8.Valid(body) {
+			return "", errors.New("QR payload declared as UTF-8 is not valid UTF-8")
+		}
+		return string(body), nil
+	case Windows1251, KOI8R:
+		// Текст, уже переведенный в UTF-8 (например, набранный вручную),
+		// повторно не перекодируем: однобайтовая кириллица не бывает корректным UTF-8
+		if utf8.Valid(body) && !isASCII(body) {
+			return string(body), nil
+		}
+		decoder := decodeWindows1251
+		if h.codePage == KOI8R {
+			decoder = decodeKOI8R
+		}
+		decoded, err := decoder.Bytes(body)
+		return string(decoded), err
+	default:
+		return "", errors.New("unknown codepage")
+	}
+}
+
+func isASCII(data []byte) bool {
+	for _, b := range data {
+		if b >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
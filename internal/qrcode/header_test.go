@@ -0,0 +1,76 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+)
+
+func TestAnalyseCodePages(t *testing.T) {
+	for _, c := range []codePage{Windows1251, UTF8, KOI8R} {
+		payment := validPayment()
+		payment.Name = "ООО Три кита" // в KOI8-R нет кавычек-елочек
+		encoded, err := payment.String(c)
+		if err != nil {
+			t.Fatalf("Кодировка %d: ошибка сериализации %v", c, err)
+		}
+		qr := QRCode{}
+		if err := qr.AnalyseText(encoded); err != nil {
+			t.Fatalf("Кодировка %d: ошибка разбора %v", c, err)
+		}
+		if qr.Name != payment.Name || qr.Purpose != payment.Purpose {
+			t.Errorf("Кодировка %d: ожидали '%s'/'%s', получили '%s'/'%s'",
+				c, payment.Name, payment.Purpose, qr.Name, qr.Purpose)
+		}
+	}
+}
+
+func TestAnalyseImageWindows1251(t *testing.T) {
+	payment := validPayment()
+	data, err := payment.Png(Windows1251, 512)
+	if err != nil {
+		t.Fatalf("Ошибка создания QR-кода: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Ошибка чтения png: %v", err)
+	}
+	qr := QRCode{}
+	if err := qr.AnalyseImage(img); err != nil {
+		t.Fatalf("Ошибка распознавания: %v", err)
+	}
+	if qr.Name != payment.Name || qr.Purpose != payment.Purpose {
+		t.Errorf("Ожидали '%s'/'%s', получили '%s'/'%s'", payment.Name, payment.Purpose, qr.Name, qr.Purpose)
+	}
+}
+
+func TestAnalyseCustomSeparator(t *testing.T) {
+	qr := QRCode{}
+	if err := qr.AnalyseText("ST00012#Name=ООО «Ромашка»#BIC=044525225#Sum=100"); err != nil {
+		t.Fatalf("Ошибка разбора: %v", err)
+	}
+	if qr.Name != "ООО «Ромашка»" || qr.BIC != "044525225" || qr.Sum != 1 {
+		t.Errorf("Неверно разобраны поля: %+v", qr.Payment)
+	}
+}
+
+func TestAnalyseMalformedHeader(t *testing.T) {
+	payloads := []string{
+		"",
+		"ST0001",
+		"XX00012|Name=Test",
+		"ST00022|Name=Test",
+		"ST00019|Name=Test",
+		"ST00012AName=Test",
+		"ST00012=Name=Test",
+		"ST00012 Name=Test",
+		"ST00012|Name=\xff\xfe",
+		"Name=Test|BIC=044525225",
+	}
+	for _, payload := range payloads {
+		qr := QRCode{}
+		if err := qr.AnalyseText(payload); err == nil {
+			t.Errorf("Ожидали ошибку для заголовка %q", payload)
+		}
+	}
+}
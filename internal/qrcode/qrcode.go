@@ -1,57 +1,53 @@
 package qrcode
 
 import (
-	"errors"
-	goqr "github.com/liyue201/goqr"
 	"image"
 	"main/internal/entity"
 	"os"
-	"strconv"
 	"strings"
 )
 
 type QRCode struct {
 	qrcodeBytes []byte
 	Payment
+	SBP *SBP // Ссылка СБП, если QR-код не по ГОСТ, а от НСПК
 }
 
 func (qr *QRCode) analyseTextPayment(text string) error {
-	var err error
-	err = nil
-	if !strings.Contains(text, "|") && !strings.HasPrefix(text, "ST000") {
-		return errors.New("Is not a QR code requisite text")
+	return qr.analysePayload([]byte(text))
+}
+
+// analysePayload - разбирает содержимое QR-кода в кодировке, указанной в заголовке
+func (qr *QRCode) analysePayload(payload []byte) error {
+	qr.SBP = nil
+	qr.Payment = Payment{}
+	if IsSBPLink(string(payload)) {
+		sbp, err := ParseSBP(string(payload))
+		if err != nil {
+			return err
+		}
+		qr.SBP = sbp
+		return nil
+	}
+	header, err := parseHeader(payload)
+	if err != nil {
+		return err
+	}
+	text, err := header.decode(payload[headerLength:])
+	if err != nil {
+		return err
 	}
-	splittedText := strings.Split(text, "|")
+	splittedText := strings.Split(text, string(header.separator))
 	for _, partSplittedText := range splittedText {
-		if strings.HasPrefix(partSplittedText, "Name=") {
-			qr.Name = strings.Replace(partSplittedText, "Name=", "", 1)
+		key, value, ok := strings.Cut(partSplittedText, "=")
+		if !ok {
+			continue
 		}
-		if strings.HasPrefix(partSplittedText, "PersonalAcc=") {
-			qr.PersonalAcc = strings.Replace(partSplittedText, "PersonalAcc=", "", 1)
-		}
-		if strings.HasPrefix(partSplittedText, "BankName=") {
-			qr.BankName = strings.Replace(partSplittedText, "BankName=", "", 1)
-		}
-		if strings.HasPrefix(partSplittedText, "BIC=") {
-			qr.BIC = strings.Replace(partSplittedText, "BIC=", "", 1)
-		}
-		if strings.HasPrefix(partSplittedText, "CorrespAcc=") {
-			qr.CorrespAcc = strings.Replace(partSplittedText, "CorrespAcc=", "", 1)
-		}
-		if strings.HasPrefix(partSplittedText, "KPP=") {
-			qr.KPP = strings.Replace(partSplittedText, "KPP=", "", 1)
-		}
-		if strings.HasPrefix(partSplittedText, "PayeeINN=") {
-			qr.PayeeINN = strings.Replace(partSplittedText, "PayeeINN=", "", 1)
-		}
-		if strings.HasPrefix(partSplittedText, "Purpose=") {
-			qr.Purpose = strings.Replace(partSplittedText, "Purpose=", "", 1)
-		}
-		if strings.HasPrefix(partSplittedText, "Sum=") {
-			qr.Sum, err = strconv.ParseFloat(strings.Replace(partSplittedText, "Sum=", "", 1), 10)
+		if err := qr.SetField(key, value); err != nil {
+			return err
 		}
 	}
-	return err
+	return nil
 }
 
 func (qr *QRCode) AnalyseFile(filename string) error {
@@ -59,6 +55,7 @@ func (qr *QRCode) AnalyseFile(filename string) error {
 	if err != nil {
 		return err
 	}
+	defer file.Close()
 	img, _, err := image.Decode(file)
 	if err != nil {
 		return err
@@ -66,12 +63,20 @@ func (qr *QRCode) AnalyseFile(filename string) error {
 	return qr.AnalyseImage(img)
 }
 
+// AnalyseImage - разбирает первый QR-код с реквизитами на изображении.
+// Все найденные коды с их положением возвращает Recognize
 func (qr *QRCode) AnalyseImage(image image.Image) error {
-	qrcodeRecognize, _ := goqr.Recognize(image)
-	for _, qrcodeRecognizePart := range qrcodeRecognize {
-		return qr.analyseTextPayment(string(qrcodeRecognizePart.Payload))
+	recognized, err := Recognize(image)
+	if err != nil {
+		return err
 	}
-	return errors.New("Don't be a qrcode")
+	for _, code := range recognized {
+		err = qr.analysePayload(code.Payload)
+		if err == nil {
+			return nil
+		}
+	}
+	return err
 }
 
 func (qr *QRCode) AnalyseText(text string) error {
@@ -83,11 +88,45 @@ func (qr *QRCode) SaveImage(filename string) error {
 	return qr.PngFile(filename, Windows1251, 512)
 }
 
-func (qr *QRCode) ToEntity(name string) entity.Requisite {
-	str, _ := qr.String(Windows1251)
-	return entity.Requisite{Name: name, PhotoData: string(qr.qrcodeBytes), Content: str}
+func (qr *QRCode) ToEntity(name string) (entity.Requisite, error) {
+	if qr.SBP != nil {
+		return qr.SBP.ToEntity(name)
+	}
+	if err := qr.Validate(); err != nil {
+		return entity.Requisite{}, err
+	}
+	str, err := qr.String(Windows1251)
+	if err != nil {
+		return entity.Requisite{}, err
+	}
+	if len(qr.qrcodeBytes) == 0 {
+		if qr.qrcodeBytes, err = qr.Png(Windows1251, 512); err != nil {
+			return entity.Requisite{}, err
+		}
+	}
+	return entity.Requisite{Name: name, PhotoData: string(qr.qrcodeBytes), Content: str}, nil
 }
 
+// FromEntity - разбирает реквизит: ссылку СБП из Link, иначе QR-код по ГОСТ из Content
 func (qr *QRCode) FromEntity(req *entity.Requisite) error {
+	if IsSBPLink(req.Link) || len(req.Content) == 0 {
+		return qr.analyseTextPayment(req.Link)
+	}
 	return qr.analyseTextPayment(req.Content)
 }
+
+// IsSBP - QR-код оплаты через СБП, а не по реквизитам
+func (qr *QRCode) IsSBP() bool {
+	return qr.SBP != nil
+}
+
+// Image - QR-код для оплаты суммы sum: ссылка СБП либо реквизиты по ГОСТ
+func (qr *QRCode) Image(sum float64, size int) ([]byte, error) {
+	if qr.SBP != nil {
+		sbp := qr.SBP.WithSum(sum)
+		return sbp.Png(size)
+	}
+	payment := qr.Payment
+	payment.Sum = sum
+	return payment.Png(Windows1251, size)
+}
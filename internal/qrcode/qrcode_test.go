@@ -22,3 +22,30 @@ func Test1(t *testing.T) {
 func Test2(t *testing.T) {
 
 }
+
+func TestPaymentRoundTrip(t *testing.T) {
+	text := "ST00012|Name=ООО «Три кита»|PersonalAcc=40702810138250123017|BankName=ОАО \"БАНК\"" +
+		"|BIC=044525225|CorrespAcc=30101810400000000225|KPP=773601001|PayeeINN=7707083893" +
+		"|Purpose=Оплата за май|Sum=480023|DocNo=15|DocDate=02.10.2026|LastName=Иванов" +
+		"|PayerAddress=г. Москва|PersAcc=123456|UIN=0|TechCode=02|CustomField=значение"
+	qr := QRCode{}
+	if err := qr.AnalyseText(text); err != nil {
+		t.Fatalf("Ошибка разбора: %v", err)
+	}
+	if qr.Sum != 4800.23 {
+		t.Errorf("Sum: ожидали 4800.23, получили %v", qr.Sum)
+	}
+	if qr.LastName != "Иванов" || qr.PersAcc != "123456" || qr.DocDate != "02.10.2026" || qr.TechCode != "02" {
+		t.Errorf("Дополнительные поля не разобраны: %+v", qr.Payment)
+	}
+	if len(qr.Extra) != 1 || qr.Extra[0] != (Field{Key: "CustomField", Value: "значение"}) {
+		t.Errorf("Неизвестное поле не сохранено: %v", qr.Extra)
+	}
+	serialized, err := qr.String(UTF8)
+	if err != nil {
+		t.Fatalf("Ошибка сериализации: %v", err)
+	}
+	if serialized != text {
+		t.Errorf("Ожидали\n%s\nполучили\n%s", text, serialized)
+	}
+}
@@ -19,9 +19,11 @@ const (
 var (
 	encodeWindows1251 = charmap.Windows1251.NewEncoder()
 	encodeKOI8R       = charmap.KOI8R.NewEncoder()
+	decodeWindows1251 = charmap.Windows1251.NewDecoder()
+	decodeKOI8R       = charmap.KOI8R.NewDecoder()
 )
 
-// Payment - платеж по банковским реквизитам
+// Payment - платеж по банковским реквизитам (ГОСТ Р 56042-2014)
 type Payment struct {
 	Name        string  // Наименование получателя платежа
 	PersonalAcc string  // Счет получателя платежа
@@ -32,23 +34,179 @@ type Payment struct {
 	PayeeINN    string  // ИНН получателя платежа
 	Purpose     string  // Назначение платежа
 	Sum         float64 // Сумма платежа, ₽
+
+	// Дополнительные реквизиты для налоговых и бюджетных платежей
+	PayerINN     string // ИНН плательщика
+	DrawerStatus string // Статус составителя платежного документа
+	CBC          string // КБК
+	OKTMO        string // Код ОКТМО
+	PaytReason   string // Основание налогового платежа
+	TaxPeriod    string // Налоговый период
+	DocNo        string // Номер документа
+	DocDate      string // Дата документа, ДД.ММ.ГГГГ
+	TaxPaytKind  string // Тип платежа
+
+	// Прочие дополнительные реквизиты
+	LastName        string // Фамилия плательщика
+	FirstName       string // Имя плательщика
+	MiddleName      string // Отчество плательщика
+	PayerAddress    string // Адрес плательщика
+	PersonalAccount string // Лицевой счет бюджетного получателя
+	DocIdx          string // Индекс платежного документа
+	PensAcc         string // № лицевого счета в системе персонифицированного учета в ПФР (СНИЛС)
+	Contract        string // Номер договора
+	PersAcc         string // Номер лицевого счета плательщика в организации
+	Flat            string // Номер квартиры
+	Phone           string // Номер телефона
+	PayerIdType     string // Вид документа, удостоверяющего личность
+	PayerIdNum      string // Номер документа, удостоверяющего личность
+	ChildFio        string // ФИО ребенка/учащегося
+	BirthDate       string // Дата рождения, ДД.ММ.ГГГГ
+	PaymTerm        string // Срок платежа/дата выставления счета
+	PaymPeriod      string // Период оплаты
+	Category        string // Вид платежа
+	ServiceName     string // Код услуги/название прибора учета
+	CounterId       string // Номер прибора учета
+	CounterVal      string // Показание прибора учета
+	QuittId         string // Номер извещения, начисления, счета
+	QuittDate       string // Дата извещения/начисления/счета/постановления (для ГИБДД), ДД.ММ.ГГГГ
+	InstNum         string // Номер учреждения (образовательного, медицинского)
+	ClassNum        string // Номер группы детсада/класса школы
+	SpecFio         string // ФИО преподавателя, специалиста, оказывающего услугу
+	AddAmount       string // Сумма страховки/дополнительной услуги/пени, в копейках
+	RuleId          string // Номер постановления (для ГИБДД)
+	ExecId          string // Номер исполнительного производства
+	RegType         string // Код вида платежа (например, для платежей в Росреестр)
+	UIN             string // Уникальный идентификатор начисления
+	TechCode        string // Технический код, рекомендуемый для заполнения поставщиком услуг
+
+	Extra []Field // Реквизиты, не описанные в ГОСТ, в порядке следования в QR-коде
 }
 
-// String - сериализует платежные данные в строку в указанной кодировке
-func (p *Payment) String(c codePage) (string, error) {
+// Field - реквизит платежа вида Key=Value
+type Field struct {
+	Key   string
+	Value string
+}
+
+type paymentField struct {
+	key   string
+	value func(p *Payment) *string
+}
+
+const sumKey = "Sum"
+
+var (
 	// ВАЖНО!
 	// Для мобильного приложения Сбербанка необходимо,
-	// чтобы поля в QR-коде шли строго в таком порядке
-	var result = "ST0001" + strconv.Itoa(int(c)) +
-		"|" + "Name=" + p.Name +
-		"|" + "PersonalAcc=" + p.PersonalAcc +
-		"|" + "BankName=" + p.BankName +
-		"|" + "BIC=" + p.BIC +
-		"|" + "CorrespAcc=" + p.CorrespAcc +
-		"|" + "KPP=" + p.KPP +
-		"|" + "PayeeINN=" + p.PayeeINN +
-		"|" + "Purpose=" + p.Purpose +
-		"|" + "Sum=" + strconv.Itoa(int(math.Round(p.Sum*100)))
+	// чтобы поля в QR-коде шли строго в таком порядке,
+	// сумма идет сразу за назначением платежа
+	requiredFields = []paymentField{
+		{"Name", func(p *Payment) *string { return &p.Name }},
+		{"PersonalAcc", func(p *Payment) *string { return &p.PersonalAcc }},
+		{"BankName", func(p *Payment) *string { return &p.BankName }},
+		{"BIC", func(p *Payment) *string { return &p.BIC }},
+		{"CorrespAcc", func(p *Payment) *string { return &p.CorrespAcc }},
+		{"KPP", func(p *Payment) *string { return &p.KPP }},
+		{"PayeeINN", func(p *Payment) *string { return &p.PayeeINN }},
+		{"Purpose", func(p *Payment) *string { return &p.Purpose }},
+	}
+	// Дополнительные поля пишутся только непустыми в порядке ГОСТ
+	additionalFields = []paymentField{
+		{"PayerINN", func(p *Payment) *string { return &p.PayerINN }},
+		{"DrawerStatus", func(p *Payment) *string { return &p.DrawerStatus }},
+		{"CBC", func(p *Payment) *string { return &p.CBC }},
+		{"OKTMO", func(p *Payment) *string { return &p.OKTMO }},
+		{"PaytReason", func(p *Payment) *string { return &p.PaytReason }},
+		{"TaxPeriod", func(p *Payment) *string { return &p.TaxPeriod }},
+		{"DocNo", func(p *Payment) *string { return &p.DocNo }},
+		{"DocDate", func(p *Payment) *string { return &p.DocDate }},
+		{"TaxPaytKind", func(p *Payment) *string { return &p.TaxPaytKind }},
+		{"LastName", func(p *Payment) *string { return &p.LastName }},
+		{"FirstName", func(p *Payment) *string { return &p.FirstName }},
+		{"MiddleName", func(p *Payment) *string { return &p.MiddleName }},
+		{"PayerAddress", func(p *Payment) *string { return &p.PayerAddress }},
+		{"PersonalAccount", func(p *Payment) *string { return &p.PersonalAccount }},
+		{"DocIdx", func(p *Payment) *string { return &p.DocIdx }},
+		{"PensAcc", func(p *Payment) *string { return &p.PensAcc }},
+		{"Contract", func(p *Payment) *string { return &p.Contract }},
+		{"PersAcc", func(p *Payment) *string { return &p.PersAcc }},
+		{"Flat", func(p *Payment) *string { return &p.Flat }},
+		{"Phone", func(p *Payment) *string { return &p.Phone }},
+		{"PayerIdType", func(p *Payment) *string { return &p.PayerIdType }},
+		{"PayerIdNum", func(p *Payment) *string { return &p.PayerIdNum }},
+		{"ChildFio", func(p *Payment) *string { return &p.ChildFio }},
+		{"BirthDate", func(p *Payment) *string { return &p.BirthDate }},
+		{"PaymTerm", func(p *Payment) *string { return &p.PaymTerm }},
+		{"PaymPeriod", func(p *Payment) *string { return &p.PaymPeriod }},
+		{"Category", func(p *Payment) *string { return &p.Category }},
+		{"ServiceName", func(p *Payment) *string { return &p.ServiceName }},
+		{"CounterId", func(p *Payment) *string { return &p.CounterId }},
+		{"CounterVal", func(p *Payment) *string { return &p.CounterVal }},
+		{"QuittId", func(p *Payment) *string { return &p.QuittId }},
+		{"QuittDate", func(p *Payment) *string { return &p.QuittDate }},
+		{"InstNum", func(p *Payment) *string { return &p.InstNum }},
+		{"ClassNum", func(p *Payment) *string { return &p.ClassNum }},
+		{"SpecFio", func(p *Payment) *string { return &p.SpecFio }},
+		{"AddAmount", func(p *Payment) *string { return &p.AddAmount }},
+		{"RuleId", func(p *Payment) *string { return &p.RuleId }},
+		{"ExecId", func(p *Payment) *string { return &p.ExecId }},
+		{"RegType", func(p *Payment) *string { return &p.RegType }},
+		{"UIN", func(p *Payment) *string { return &p.UIN }},
+		{"TechCode", func(p *Payment) *string { return &p.TechCode }},
+	}
+	fieldsByKey = make(map[string]paymentField)
+)
+
+func init() {
+	for _, field := range requiredFields {
+		fieldsByKey[field.key] = field
+	}
+	for _, field := range additionalFields {
+		fieldsByKey[field.key] = field
+	}
+}
+
+// SetField - заполняет реквизит по его имени из QR-кода.
+// Неизвестные реквизиты сохраняются в Extra
+func (p *Payment) SetField(key, value string) error {
+	if key == sumKey {
+		kopecks, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.New("Sum is not a number: " + value)
+		}
+		p.Sum = kopecks / 100
+		return nil
+	}
+	if field, ok := fieldsByKey[key]; ok {
+		*field.value(p) = value
+		return nil
+	}
+	p.Extra = append(p.Extra, Field{Key: key, Value: value})
+	return nil
+}
+
+// Fields - реквизиты платежа в порядке сериализации
+func (p *Payment) Fields() []Field {
+	result := make([]Field, 0, len(requiredFields)+1+len(p.Extra))
+	for _, field := range requiredFields {
+		result = append(result, Field{Key: field.key, Value: *field.value(p)})
+	}
+	result = append(result, Field{Key: sumKey, Value: strconv.Itoa(int(math.Round(p.Sum * 100)))})
+	for _, field := range additionalFields {
+		if value := *field.value(p); len(value) > 0 {
+			result = append(result, Field{Key: field.key, Value: value})
+		}
+	}
+	return append(result, p.Extra...)
+}
+
+// String - сериализует платежные данные в строку в указанной кодировке
+func (p *Payment) String(c codePage) (string, error) {
+	var result = formatID + formatVersion + strconv.Itoa(int(c))
+	for _, field := range p.Fields() {
+		result += "|" + field.Key + "=" + field.Value
+	}
 
 	switch c {
 	case Windows1251:
@@ -64,6 +222,9 @@ func (p *Payment) String(c codePage) (string, error) {
 
 // Png - создает QR-код в формате png размера size пикселей
 func (p *Payment) Png(c codePage, size int) ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
 	s, err := p.String(c)
 	if err != nil {
 		return nil, err
@@ -73,6 +234,9 @@ func (p *Payment) Png(c codePage, size int) ([]byte, error) {
 
 // PngFile - сохраняет файл с QR-кодом
 func (p *Payment) PngFile(filename string, c codePage, size int) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
 	s, err := p.String(c)
 	if err != nil {
 		return err
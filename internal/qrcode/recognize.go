@@ -0,0 +1,322 @@
+package qrcode
+
+import (
+	"errors"
+	"github.com/makiuchi-d/gozxing"
+	multiqr "github.com/makiuchi-d/gozxing/multi/qrcode"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+	"golang.org/x/text/encoding/charmap"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+	"sort"
+)
+
+const (
+	minRecognizeSide = 600  // Меньшие изображения увеличиваются перед распознаванием
+	maxRecognizeSide = 1600 // Большие скриншоты уменьшаются, чтобы не терять время
+	thresholdOffset  = 7    // Насколько пиксель должен быть темнее локального среднего
+)
+
+// Наклоны, которые пробуются, если код не нашелся на ровном изображении.
+// Повороты на 90° декодер находит сам
+var rotationAttempts = []float64{15, -15, 30, -30, 45}
+
+// Recognized - QR-код, найденный на изображении
+type Recognized struct {
+	Payload []byte          // Содержимое QR-кода без перекодирования
+	Points  []image.Point   // Центры поисковых узоров в координатах исходного изображения
+	Bounds  image.Rectangle // Прямоугольник, охватывающий Points
+}
+
+// transform - перевод координат варианта изображения в координаты исходного
+type transform func(x, y float64) (float64, float64)
+
+// candidate - вариант подготовленного изображения для декодера
+type candidate struct {
+	img      *image.Gray
+	toSource transform
+}
+
+var decodeHints = map[gozxing.DecodeHintType]interface{}{
+	gozxing.DecodeHintType_TRY_HARDER: true,
+	// Байты отдаются как есть, кодировку определяет заголовок ST0001x
+	gozxing.DecodeHintType_CHARACTER_SET: charmap.ISO8859_1,
+}
+
+// RecognizeFile - ищет все QR-коды в файле изображения
+func RecognizeFile(filename string) ([]Recognized, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+	return Recognize(img)
+}
+
+// Recognize - ищет все QR-коды на изображении, в том числе на фотографиях
+// и скриншотах. Изображение переводится в оттенки серого и масштабируется,
+// затем пробуются: исходная яркость, растянутый контраст и адаптивная бинаризация.
+// Если ничего не нашлось - участки изображения по отдельности, затем наклоны
+func Recognize(img image.Image) ([]Recognized, error) {
+	gray := toGray(img)
+	if gray.Bounds().Empty() {
+		return nil, errors.New("empty image")
+	}
+	base, toSource := fitScale(gray)
+
+	stages := []func() []candidate{
+		func() []candidate { return variants(base, toSource) },
+		func() []candidate { return crops(base, toSource) },
+		func() []candidate { return rotations(base, toSource) },
+	}
+	found := make(map[string]Recognized)
+	var order []string
+	for _, stage := range stages {
+		for _, c := range stage() {
+			for _, recognized := range decodeAll(c) {
+				key := string(recognized.Payload)
+				if _, ok := found[key]; !ok {
+					order = append(order, key)
+				}
+				found[key] = recognized
+			}
+		}
+		if len(found) > 0 {
+			break
+		}
+	}
+	if len(found) == 0 {
+		return nil, errors.New("Don't be a qrcode")
+	}
+	result := make([]Recognized, len(order))
+	for i, key := range order {
+		result[i] = found[key]
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Bounds.Min.Y != result[j].Bounds.Min.Y {
+			return result[i].Bounds.Min.Y < result[j].Bounds.Min.Y
+		}
+		return result[i].Bounds.Min.X < result[j].Bounds.Min.X
+	})
+	return result, nil
+}
+
+func decodeAll(c candidate) []Recognized {
+	bitmap, err := gozxing.NewBinaryBitmap(gozxing.NewHybridBinarizer(gozxing.NewLuminanceSourceFromImage(c.img)))
+	if err != nil {
+		return nil
+	}
+	results, _ := multiqr.NewQRCodeMultiReader().DecodeMultiple(bitmap, decodeHints)
+	recognized := make([]Recognized, 0, len(results))
+	for _, result := range results {
+		item := Recognized{Payload: latin1Bytes(result.GetText())}
+		for i, point := range result.GetResultPoints() {
+			x, y := c.toSource(point.GetX(), point.GetY())
+			p := image.Pt(int(math.Round(x)), int(math.Round(y)))
+			item.Points = append(item.Points, p)
+			if i == 0 {
+				item.Bounds = image.Rectangle{Min: p, Max: p}
+			}
+			item.Bounds = item.Bounds.Union(image.Rectangle{Min: p, Max: p.Add(image.Pt(1, 1))})
+		}
+		recognized = append(recognized, item)
+	}
+	return recognized
+}
+
+// latin1Bytes - восстанавливает исходные байты из текста, раскодированного как ISO-8859-1.
+// Если в QR-коде была указана кодировка (ECI), текст уже в Unicode и возвращается в UTF-8
+func latin1Bytes(text string) []byte {
+	payload := make([]byte, 0, len(text))
+	for _, r := range text {
+		if r > 0xFF {
+			return []byte(text)
+		}
+		payload = append(payload, byte(r))
+	}
+	return payload
+}
+
+func toGray(img image.Image) *image.Gray {
+	if gray, ok := img.(*image.Gray); ok && gray.Rect.Min == (image.Point{}) && gray.Stride == gray.Rect.Dx() {
+		return gray
+	}
+	bounds := img.Bounds()
+	gray := image.NewGray(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(gray, gray.Bounds(), img, bounds.Min, draw.Src)
+	return gray
+}
+
+// fitScale - приводит изображение к размеру, удобному декодеру
+func fitScale(gray *image.Gray) (*image.Gray, transform) {
+	bounds := gray.Bounds()
+	side := math.Max(float64(bounds.Dx()), float64(bounds.Dy()))
+	factor := 1.0
+	switch {
+	case side < minRecognizeSide:
+		factor = math.Ceil(minRecognizeSide / side)
+	case side > maxRecognizeSide:
+		factor = maxRecognizeSide / side
+	}
+	identity := func(x, y float64) (float64, float64) { return x, y }
+	if factor == 1 {
+		return gray, identity
+	}
+	return scale(gray, factor), func(x, y float64) (float64, float64) {
+		return x / factor, y / factor
+	}
+}
+
+func scale(gray *image.Gray, factor float64) *image.Gray {
+	bounds := gray.Bounds()
+	scaled := image.NewGray(image.Rect(0, 0,
+		int(math.Round(float64(bounds.Dx())*factor)), int(math.Round(float64(bounds.Dy())*factor))))
+	scaler := draw.Interpolator(draw.ApproxBiLinear)
+	if factor > 1 {
+		// Модули QR-кода должны остаться резкими
+		scaler = draw.NearestNeighbor
+	}
+	scaler.Scale(scaled, scaled.Bounds(), gray, bounds, draw.Src, nil)
+	return scaled
+}
+
+// variants - исходная яркость, растянутый контраст и адаптивная бинаризация
+func variants(gray *image.Gray, toSource transform) []candidate {
+	return []candidate{
+		{img: gray, toSource: toSource},
+		{img: stretchContrast(gray), toSource: toSource},
+		{img: adaptiveThreshold(gray), toSource: toSource},
+	}
+}
+
+// crops - перекрывающиеся участки изображения 2x2 и 3x3, каждый увеличенный.
+// Помогает, когда QR-код занимает малую часть большого скриншота
+func crops(gray *image.Gray, toSource transform) []candidate {
+	bounds := gray.Bounds()
+	var result []candidate
+	for _, grid := range []int{2, 3} {
+		width, height := bounds.Dx()*2/(grid+1), bounds.Dy()*2/(grid+1)
+		for row := 0; row < grid; row++ {
+			for col := 0; col < grid; col++ {
+				offset := image.Pt(col*width/2, row*height/2)
+				rect := image.Rect(0, 0, width, height).Add(offset).Intersect(bounds)
+				if rect.Empty() {
+					continue
+				}
+				crop := image.NewGray(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+				draw.Draw(crop, crop.Bounds(), gray, rect.Min, draw.Src)
+				factor := math.Min(float64(grid), maxRecognizeSide/float64(max(rect.Dx(), rect.Dy())))
+				factor = math.Max(factor, 1)
+				cropToSource := func(x, y float64) (float64, float64) {
+					return toSource(x/factor+float64(offset.X), y/factor+float64(offset.Y))
+				}
+				scaled := scale(crop, factor)
+				result = append(result,
+					candidate{img: scaled, toSource: cropToSource},
+					candidate{img: adaptiveThreshold(scaled), toSource: cropToSource})
+			}
+		}
+	}
+	return result
+}
+
+// rotations - изображение, повернутое на углы из rotationAttempts вокруг центра
+func rotations(gray *image.Gray, toSource transform) []candidate {
+	bounds := gray.Bounds()
+	width, height := float64(bounds.Dx()), float64(bounds.Dy())
+	var result []candidate
+	for _, degrees := range rotationAttempts {
+		angle := degrees * math.Pi / 180
+		sin, cos := math.Sincos(angle)
+		newWidth := math.Abs(width*cos) + math.Abs(height*sin)
+		newHeight := math.Abs(width*sin) + math.Abs(height*cos)
+		rotated := image.NewGray(image.Rect(0, 0, int(math.Ceil(newWidth)), int(math.Ceil(newHeight))))
+		draw.Draw(rotated, rotated.Bounds(), image.White, image.Point{}, draw.Src)
+
+		// Поворот вокруг центра исходного изображения с переносом в центр нового
+		cx, cy, ncx, ncy := width/2, height/2, newWidth/2, newHeight/2
+		matrix := f64.Aff3{
+			cos, -sin, ncx - cos*cx + sin*cy,
+			sin, cos, ncy - sin*cx - cos*cy,
+		}
+		draw.ApproxBiLinear.Transform(rotated, matrix, gray, bounds, draw.Over, nil)
+		result = append(result, candidate{img: rotated, toSource: func(x, y float64) (float64, float64) {
+			dx, dy := x-ncx, y-ncy
+			return toSource(cos*dx+sin*dy+cx, -sin*dx+cos*dy+cy)
+		}})
+	}
+	return result
+}
+
+// stretchContrast - растягивает яркость между 1-м и 99-м процентилями на весь диапазон
+func stretchContrast(gray *image.Gray) *image.Gray {
+	var histogram [256]int
+	for _, value := range gray.Pix {
+		histogram[value]++
+	}
+	low, high := percentile(histogram, len(gray.Pix)/100), percentile(histogram, len(gray.Pix)*99/100)
+	if high <= low {
+		return gray
+	}
+	stretched := image.NewGray(gray.Rect)
+	for i, value := range gray.Pix {
+		v := (int(value) - low) * 255 / (high - low)
+		stretched.Pix[i] = uint8(min(max(v, 0), 255))
+	}
+	return stretched
+}
+
+func percentile(histogram [256]int, count int) int {
+	sum := 0
+	for value, n := range histogram {
+		sum += n
+		if sum > count {
+			return value
+		}
+	}
+	return 255
+}
+
+// adaptiveThreshold - бинаризация относительно среднего в окне вокруг пикселя.
+// Справляется с тенями и неравномерным освещением на фотографиях
+func adaptiveThreshold(gray *image.Gray) *image.Gray {
+	bounds := gray.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	radius := max(width, height) / 16
+	if radius < 4 {
+		radius = 4
+	}
+	// Интегральное изображение для подсчета суммы в окне за O(1)
+	integral := make([]int64, (width+1)*(height+1))
+	for y := 0; y < height; y++ {
+		var rowSum int64
+		for x := 0; x < width; x++ {
+			rowSum += int64(gray.Pix[y*gray.Stride+x])
+			integral[(y+1)*(width+1)+x+1] = integral[y*(width+1)+x+1] + rowSum
+		}
+	}
+	result := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := max(y-radius, 0), min(y+radius+1, height)
+		for x := 0; x < width; x++ {
+			x0, x1 := max(x-radius, 0), min(x+radius+1, width)
+			sum := integral[y1*(width+1)+x1] - integral[y0*(width+1)+x1] -
+				integral[y1*(width+1)+x0] + integral[y0*(width+1)+x0]
+			mean := sum / int64((x1-x0)*(y1-y0))
+			if int64(gray.Pix[y*gray.Stride+x])+thresholdOffset < mean {
+				result.Pix[y*result.Stride+x] = 0
+			} else {
+				result.Pix[y*result.Stride+x] = 255
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,108 @@
+package qrcode
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"testing"
+
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/math/f64"
+)
+
+func qrImage(t *testing.T, text string, size int) image.Image {
+	data, err := qrcode.Encode(text, qrcode.Medium, size)
+	if err != nil {
+		t.Fatalf("Ошибка создания QR-кода: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Ошибка чтения png: %v", err)
+	}
+	return img
+}
+
+// lowContrast - переводит черный/белый в темно-серый/светло-серый, как на фото экрана
+func lowContrast(img image.Image) *image.Gray {
+	gray := toGray(img)
+	for i, value := range gray.Pix {
+		gray.Pix[i] = uint8(90 + int(value)*80/255)
+	}
+	return gray
+}
+
+func TestRecognizeMultipleInScreenshot(t *testing.T) {
+	screenshot := image.NewGray(image.Rect(0, 0, 2400, 1800))
+	draw.Draw(screenshot, screenshot.Bounds(), image.NewUniform(color.Gray{Y: 230}), image.Point{}, draw.Src)
+	first := validPayment()
+	firstText, _ := first.String(UTF8)
+	sbp := SBP{ID: "AS1000670LSS7DN18SJQDNP4B05KLJL2", Type: SBPStatic, Bank: "100000000111", Sum: 250}
+	places := []image.Point{{100, 150}, {1700, 1100}}
+	draw.Draw(screenshot, image.Rect(0, 0, 400, 400).Add(places[0]), qrImage(t, firstText, 400), image.Point{}, draw.Src)
+	draw.Draw(screenshot, image.Rect(0, 0, 400, 400).Add(places[1]), qrImage(t, sbp.String(), 400), image.Point{}, draw.Src)
+
+	recognized, err := Recognize(screenshot)
+	if err != nil {
+		t.Fatalf("Ошибка распознавания: %v", err)
+	}
+	if len(recognized) != 2 {
+		t.Fatalf("Ожидали 2 QR-кода, получили %d", len(recognized))
+	}
+	if string(recognized[0].Payload) != firstText || string(recognized[1].Payload) != sbp.String() {
+		t.Errorf("Неверное содержимое: %q, %q", recognized[0].Payload, recognized[1].Payload)
+	}
+	for i, code := range recognized {
+		area := image.Rect(0, 0, 400, 400).Add(places[i])
+		if !code.Bounds.In(area) || code.Bounds.Dx() < 100 {
+			t.Errorf("QR-код %d: границы %v вне ожидаемой области %v", i, code.Bounds, area)
+		}
+	}
+}
+
+func TestRecognizeRotatedPhoto(t *testing.T) {
+	payment := validPayment()
+	data, _ := payment.Png(Windows1251, 300)
+	img, _ := png.Decode(bytes.NewReader(data))
+
+	// Наклон 20°, низкий контраст и JPEG-сжатие
+	photo := image.NewGray(image.Rect(0, 0, 600, 600))
+	draw.Draw(photo, photo.Bounds(), image.White, image.Point{}, draw.Src)
+	sin, cos := math.Sincos(20 * math.Pi / 180)
+	matrix := f64.Aff3{cos, -sin, 300 - cos*150 + sin*150, sin, cos, 300 - sin*150 - cos*150}
+	draw.BiLinear.Transform(photo, matrix, img, img.Bounds(), draw.Over, nil)
+	var compressed bytes.Buffer
+	if err := jpeg.Encode(&compressed, lowContrast(photo), &jpeg.Options{Quality: 40}); err != nil {
+		t.Fatalf("Ошибка сжатия: %v", err)
+	}
+	decoded, _ := jpeg.Decode(&compressed)
+
+	qr := QRCode{}
+	if err := qr.AnalyseImage(decoded); err != nil {
+		t.Fatalf("Ошибка распознавания: %v", err)
+	}
+	if qr.Name != payment.Name || qr.PersonalAcc != payment.PersonalAcc {
+		t.Errorf("Неверно распознаны реквизиты: %+v", qr.Payment)
+	}
+}
+
+func TestRecognizeSmallCode(t *testing.T) {
+	sbp := SBP{ID: "AS1000670LSS7DN18SJQDNP4B05KLJL2", Type: SBPStatic}
+	qr := QRCode{}
+	if err := qr.AnalyseImage(qrImage(t, sbp.String(), 120)); err != nil {
+		t.Fatalf("Ошибка распознавания маленького QR-кода: %v", err)
+	}
+	if !qr.IsSBP() || qr.SBP.ID != sbp.ID {
+		t.Errorf("Неверно распознана ссылка СБП: %+v", qr.SBP)
+	}
+}
+
+func TestRecognizeNothing(t *testing.T) {
+	blank := image.NewGray(image.Rect(0, 0, 300, 300))
+	if _, err := Recognize(blank); err == nil {
+		t.Error("Ожидали ошибку для изображения без QR-кода")
+	}
+}
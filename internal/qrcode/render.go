@@ -0,0 +1,367 @@
+package qrcode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultModuleSize = 8
+	defaultQuietZone  = 4
+	defaultLogoRatio  = 0.22
+	maxLogoRatio      = 0.3 // Больший логотип не восстановит даже уровень коррекции H
+)
+
+var (
+	fontRegular, _ = opentype.Parse(goregular.TTF)
+	fontBold, _    = opentype.Parse(gobold.TTF)
+)
+
+// Caption - подпись под QR-кодом
+type Caption struct {
+	Recipient string  // Наименование получателя
+	Amount    float64 // Сумма, ₽. 0 - баннер с суммой не выводится
+	Tariff    string  // Название тарифа
+}
+
+// Renderer - оформление QR-кода: цвета, поле вокруг кода, логотип и подпись.
+// Незаданные поля берутся из DefaultRenderer.
+// Цвет модулей должен быть темнее фона, иначе камеры банков код не прочитают
+type Renderer struct {
+	Foreground color.Color // Цвет модулей
+	Background color.Color // Цвет фона
+	Accent     color.Color // Цвет баннера с суммой
+	AccentText color.Color // Цвет текста на баннере
+	ModuleSize int         // Размер модуля, px
+	QuietZone  int         // Поле вокруг кода, модулей. Стандарт требует не меньше 4, меньшее заменяется на 4
+	Logo       image.Image // Логотип по центру кода, может отсутствовать
+	LogoRatio  float64     // Доля стороны кода под логотип
+}
+
+// DefaultRenderer - черный код на белом фоне без логотипа
+func DefaultRenderer() *Renderer {
+	return &Renderer{
+		Foreground: color.Black,
+		Background: color.White,
+		Accent:     color.RGBA{R: 0x21, G: 0xa0, B: 0x38, A: 0xff},
+		AccentText: color.White,
+		ModuleSize: defaultModuleSize,
+		QuietZone:  defaultQuietZone,
+		LogoRatio:  defaultLogoRatio,
+	}
+}
+
+// CaptionFor - подпись с получателем и суммой платежа
+func (p *Payment) CaptionFor(tariff string) Caption {
+	return Caption{Recipient: p.Name, Amount: p.Sum, Tariff: tariff}
+}
+
+// BrandedPng - QR-код платежа, оформленный renderer, с подписью под кодом
+func (p *Payment) BrandedPng(c codePage, renderer *Renderer, tariff string) ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	s, err := p.String(c)
+	if err != nil {
+		return nil, err
+	}
+	return renderer.Png(s, p.CaptionFor(tariff))
+}
+
+// BrandedSVG - QR-код платежа в SVG для печати
+func (p *Payment) BrandedSVG(c codePage, renderer *Renderer, tariff string) ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	s, err := p.String(c)
+	if err != nil {
+		return nil, err
+	}
+	return renderer.SVG(s, p.CaptionFor(tariff))
+}
+
+// layout - размеры изображения, общие для PNG и SVG
+type layout struct {
+	modules    [][]bool
+	moduleSize int
+	quietZone  int
+	side       int // Сторона кода с полем, px
+	width      int
+	height     int
+	fontSize   float64
+	lineStep   int
+	banner     int // Высота баннера с суммой, px
+	logoRect   image.Rectangle
+	textLines  []string
+}
+
+// normalized - копия настроек, где незаданные поля заменены значениями по умолчанию
+func (r *Renderer) normalized() *Renderer {
+	result := *r
+	defaults := DefaultRenderer()
+	if result.Foreground == nil {
+		result.Foreground = defaults.Foreground
+	}
+	if result.Background == nil {
+		result.Background = defaults.Background
+	}
+	if result.Accent == nil {
+		result.Accent = defaults.Accent
+	}
+	if result.AccentText == nil {
+		result.AccentText = defaults.AccentText
+	}
+	if result.ModuleSize <= 0 {
+		result.ModuleSize = defaults.ModuleSize
+	}
+	// поле уже 4 модулей стандарт не допускает
+	if result.QuietZone < defaultQuietZone {
+		result.QuietZone = defaults.QuietZone
+	}
+	if result.LogoRatio <= 0 {
+		result.LogoRatio = defaults.LogoRatio
+	}
+	if result.LogoRatio > maxLogoRatio {
+		result.LogoRatio = maxLogoRatio
+	}
+	return &result
+}
+
+func (r *Renderer) recoveryLevel() qrcode.RecoveryLevel {
+	if r.Logo != nil {
+		// Логотип закрывает часть модулей, их восстанавливает коррекция ошибок
+		return qrcode.Highest
+	}
+	return qrcode.Medium
+}
+
+func (r *Renderer) layout(content string, caption Caption) (*layout, error) {
+	code, err := qrcode.New(content, r.recoveryLevel())
+	if err != nil {
+		return nil, err
+	}
+	code.DisableBorder = true
+	l := &layout{modules: code.Bitmap(), moduleSize: r.ModuleSize, quietZone: r.QuietZone}
+	count := len(l.modules)
+	l.side = (count + 2*l.quietZone) * l.moduleSize
+	l.width = l.side
+	l.height = l.side
+
+	if r.Logo != nil {
+		logoSide := int(float64(count*l.moduleSize) * r.LogoRatio)
+		offset := (l.side - logoSide) / 2
+		l.logoRect = image.Rect(offset, offset, offset+logoSide, offset+logoSide)
+	}
+
+	l.fontSize = float64(l.side) / 22
+	if l.fontSize < 12 {
+		l.fontSize = 12
+	}
+	l.lineStep = int(l.fontSize * 1.5)
+	for _, line := range []string{caption.Recipient, caption.Tariff} {
+		if len(line) > 0 {
+			l.textLines = append(l.textLines, line)
+		}
+	}
+	l.height += len(l.textLines) * l.lineStep
+	if caption.Amount > 0 {
+		l.banner = l.lineStep * 2
+		l.height += l.banner
+	}
+	if len(l.textLines) > 0 || l.banner > 0 {
+		l.height += l.lineStep / 2
+	}
+	return l, nil
+}
+
+func (l *layout) moduleRect(x, y int) image.Rectangle {
+	left, top := (x+l.quietZone)*l.moduleSize, (y+l.quietZone)*l.moduleSize
+	return image.Rect(left, top, left+l.moduleSize, top+l.moduleSize)
+}
+
+// Image - оформленный QR-код с подписью
+func (r *Renderer) Image(content string, caption Caption) (image.Image, error) {
+	r = r.normalized()
+	l, err := r.layout(content, caption)
+	if err != nil {
+		return nil, err
+	}
+	canvas := image.NewRGBA(image.Rect(0, 0, l.width, l.height))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(r.Background), image.Point{}, draw.Src)
+	foreground := image.NewUniform(r.Foreground)
+	for y, row := range l.modules {
+		for x, dark := range row {
+			if dark {
+				draw.Draw(canvas, l.moduleRect(x, y), foreground, image.Point{}, draw.Src)
+			}
+		}
+	}
+	if r.Logo != nil {
+		// Подложка под логотип в цвет фона, чтобы он не сливался с модулями
+		padded := l.logoRect.Inset(-l.moduleSize)
+		draw.Draw(canvas, padded, image.NewUniform(r.Background), image.Point{}, draw.Src)
+		draw.CatmullRom.Scale(canvas, l.logoRect, r.Logo, r.Logo.Bounds(), draw.Over, nil)
+	}
+
+	regular, err := opentype.NewFace(fontRegular, &opentype.FaceOptions{Size: l.fontSize, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, err
+	}
+	defer regular.Close()
+	y := l.side
+	for _, line := range l.textLines {
+		y += l.lineStep
+		drawCentered(canvas, regular, fitText(regular, line, l.width-l.lineStep), r.Foreground, l.width, y)
+	}
+	if l.banner > 0 {
+		y += l.lineStep / 2
+		bannerRect := image.Rect(0, y, l.width, y+l.banner)
+		draw.Draw(canvas, bannerRect, image.NewUniform(r.Accent), image.Point{}, draw.Src)
+		bold, err := opentype.NewFace(fontBold, &opentype.FaceOptions{Size: l.fontSize * 1.3, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			return nil, err
+		}
+		defer bold.Close()
+		baseline := y + l.banner/2 + bold.Metrics().Ascent.Ceil()/2 - 1
+		drawCentered(canvas, bold, amountText(caption.Amount), r.AccentText, l.width, baseline)
+	}
+	return canvas, nil
+}
+
+// Png - оформленный QR-код в формате png
+func (r *Renderer) Png(content string, caption Caption) ([]byte, error) {
+	img, err := r.Image(content, caption)
+	if err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, img); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// SVG - оформленный QR-код в векторном формате для печати
+func (r *Renderer) SVG(content string, caption Caption) ([]byte, error) {
+	r = r.normalized()
+	l, err := r.layout(content, caption)
+	if err != nil {
+		return nil, err
+	}
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		l.width, l.height, l.width, l.height)
+	fmt.Fprintf(&svg, `<rect width="100%%" height="100%%" fill="%s"/>`, hexColor(r.Background))
+
+	// Соседние модули в строке объединяются в один прямоугольник
+	fmt.Fprintf(&svg, `<g fill="%s">`, hexColor(r.Foreground))
+	for y, row := range l.modules {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			rect := l.moduleRect(start, y)
+			fmt.Fprintf(&svg, `<rect x="%d" y="%d" width="%d" height="%d"/>`,
+				rect.Min.X, rect.Min.Y, (x-start)*l.moduleSize, l.moduleSize)
+		}
+	}
+	svg.WriteString(`</g>`)
+
+	if r.Logo != nil {
+		var logo bytes.Buffer
+		if err := png.Encode(&logo, r.Logo); err != nil {
+			return nil, err
+		}
+		padded := l.logoRect.Inset(-l.moduleSize)
+		fmt.Fprintf(&svg, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`,
+			padded.Min.X, padded.Min.Y, padded.Dx(), padded.Dy(), hexColor(r.Background))
+		fmt.Fprintf(&svg, `<image x="%d" y="%d" width="%d" height="%d" href="data:image/png;base64,%s"/>`,
+			l.logoRect.Min.X, l.logoRect.Min.Y, l.logoRect.Dx(), l.logoRect.Dy(),
+			base64.StdEncoding.EncodeToString(logo.Bytes()))
+	}
+
+	textStyle := `font-family="Go, Arial, sans-serif" text-anchor="middle"`
+	y := l.side
+	for _, line := range l.textLines {
+		y += l.lineStep
+		fmt.Fprintf(&svg, `<text x="%d" y="%d" font-size="%.1f" fill="%s" %s>%s</text>`,
+			l.width/2, y, l.fontSize, hexColor(r.Foreground), textStyle, html.EscapeString(line))
+	}
+	if l.banner > 0 {
+		y += l.lineStep / 2
+		fmt.Fprintf(&svg, `<rect x="0" y="%d" width="%d" height="%d" fill="%s"/>`, y, l.width, l.banner, hexColor(r.Accent))
+		fmt.Fprintf(&svg, `<text x="%d" y="%d" font-size="%.1f" font-weight="bold" fill="%s" dominant-baseline="central" %s>%s</text>`,
+			l.width/2, y+l.banner/2, l.fontSize*1.3, hexColor(r.AccentText), textStyle, html.EscapeString(amountText(caption.Amount)))
+	}
+	svg.WriteString(`</svg>`)
+	return []byte(svg.String()), nil
+}
+
+func drawCentered(dst draw.Image, face font.Face, text string, c color.Color, width, baseline int) {
+	drawer := font.Drawer{Dst: dst, Src: image.NewUniform(c), Face: face}
+	x := (fixed.I(width) - drawer.MeasureString(text)) / 2
+	drawer.Dot = fixed.Point26_6{X: x, Y: fixed.I(baseline)}
+	drawer.DrawString(text)
+}
+
+// fitText - обрезает строку с многоточием, чтобы она поместилась в width пикселей
+func fitText(face font.Face, text string, width int) string {
+	limit := fixed.I(width)
+	if font.MeasureString(face, text) <= limit {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		candidate := strings.TrimSpace(string(runes)) + "…"
+		if font.MeasureString(face, candidate) <= limit {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// amountText - сумма для баннера. В шрифте нет знака ₽, поэтому "руб."
+func amountText(amount float64) string {
+	return strings.Replace(strconv.FormatFloat(amount, 'f', 2, 64), ".", ",", 1) + " руб."
+}
+
+func hexColor(c color.Color) string {
+	if c == nil {
+		return "none"
+	}
+	red, green, blue, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", red>>8, green>>8, blue>>8)
+}
+
+// ParseHexColor - цвет из строки вида "#1a2b3c" или "1a2b3c", например из конфига
+func ParseHexColor(text string) (color.RGBA, error) {
+	text = strings.TrimPrefix(text, "#")
+	if len(text) != 6 {
+		return color.RGBA{}, errors.New("color must be in #rrggbb format: " + text)
+	}
+	value, err := strconv.ParseUint(text, 16, 32)
+	if err != nil {
+		return color.RGBA{}, err
+	}
+	return color.RGBA{R: uint8(value >> 16), G: uint8(value >> 8), B: uint8(value), A: 0xff}, nil
+}
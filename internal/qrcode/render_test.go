@@ -0,0 +1,99 @@
+package qrcode
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/draw"
+)
+
+func testLogo() image.Image {
+	logo := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	draw.Draw(logo, logo.Bounds(), image.NewUniform(color.RGBA{R: 0xe3, G: 0x1e, B: 0x24, A: 0xff}), image.Point{}, draw.Src)
+	draw.Draw(logo, image.Rect(16, 16, 48, 48), image.White, image.Point{}, draw.Src)
+	return logo
+}
+
+func TestBrandedPngDecodes(t *testing.T) {
+	payment := validPayment()
+	renderer := DefaultRenderer()
+	renderer.Logo = testLogo()
+	renderer.Foreground = color.RGBA{R: 0x1a, G: 0x23, B: 0x7e, A: 0xff}
+	renderer.Background = color.RGBA{R: 0xff, G: 0xfd, B: 0xe7, A: 0xff}
+	data, err := payment.BrandedPng(Windows1251, renderer, "Месяц")
+	if err != nil {
+		t.Fatalf("Ошибка оформления QR-кода: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Ошибка чтения png: %v", err)
+	}
+	if img.Bounds().Dy() <= img.Bounds().Dx() {
+		t.Errorf("Ожидали подпись под кодом, размер %v", img.Bounds())
+	}
+	qr := QRCode{}
+	if err := qr.AnalyseImage(img); err != nil {
+		t.Fatalf("Оформленный QR-код не распознается: %v", err)
+	}
+	if qr.Name != payment.Name || qr.Sum != payment.Sum {
+		t.Errorf("Неверно распознаны реквизиты: %+v", qr.Payment)
+	}
+}
+
+func TestRendererZeroValue(t *testing.T) {
+	renderer := &Renderer{}
+	img, err := renderer.Image("https://qr.nspk.ru/AS1000670LSS7DN18SJQDNP4B05KLJL2?type=01", Caption{})
+	if err != nil {
+		t.Fatalf("Ошибка оформления QR-кода: %v", err)
+	}
+	if img.Bounds().Dx() != img.Bounds().Dy() {
+		t.Errorf("Без подписи изображение должно быть квадратным, размер %v", img.Bounds())
+	}
+	// поле по умолчанию - 4 модуля фона от края
+	edge := defaultQuietZone * defaultModuleSize
+	for _, point := range []image.Point{{edge - 1, edge - 1}, {img.Bounds().Dx() - edge, edge - 1}} {
+		if r, _, _, _ := img.At(point.X, point.Y).RGBA(); r != 0xffff {
+			t.Errorf("Точка %v должна быть в поле вокруг кода", point)
+		}
+	}
+	if r, _, _, _ := img.At(edge, edge).RGBA(); r != 0 {
+		t.Errorf("Угол кода должен начинаться после поля в %d px", edge)
+	}
+}
+
+func TestRendererNarrowQuietZone(t *testing.T) {
+	renderer := &Renderer{QuietZone: 1}
+	if zone := renderer.normalized().QuietZone; zone != defaultQuietZone {
+		t.Errorf("Поле %d модулей, стандарт требует не меньше %d", zone, defaultQuietZone)
+	}
+}
+
+func TestBrandedSVG(t *testing.T) {
+	payment := validPayment()
+	renderer := DefaultRenderer()
+	renderer.Logo = testLogo()
+	data, err := payment.BrandedSVG(UTF8, renderer, "Тариф <Год>")
+	if err != nil {
+		t.Fatalf("Ошибка оформления SVG: %v", err)
+	}
+	svg := string(data)
+	for _, part := range []string{"<svg", "</svg>", "ООО «Три кита»", "Тариф &lt;Год&gt;", "4800,00 руб.", "data:image/png;base64,"} {
+		if !strings.Contains(svg, part) {
+			t.Errorf("Ожидали '%s' в SVG", part)
+		}
+	}
+}
+
+func TestParseHexColor(t *testing.T) {
+	c, err := ParseHexColor("#1a237e")
+	if err != nil || c != (color.RGBA{R: 0x1a, G: 0x23, B: 0x7e, A: 0xff}) {
+		t.Errorf("Неверно разобран цвет: %v, %v", c, err)
+	}
+	if _, err := ParseHexColor("red"); err == nil {
+		t.Error("Ожидали ошибку для цвета не в формате #rrggbb")
+	}
+}
@@ -0,0 +1,200 @@
+package qrcode
+
+import (
+	"errors"
+	"fmt"
+	"github.com/skip2/go-qrcode"
+	"main/internal/entity"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	SBPStatic  = "01" // Многоразовый QR-код, сумму может ввести плательщик
+	SBPDynamic = "02" // Одноразовый QR-код под конкретный платеж
+)
+
+const (
+	sbpHost      = "qr.nspk.ru"
+	sbpPrefix    = "https://" + sbpHost + "/"
+	sbpCurrency  = "RUB"
+	sbpCRCParam  = "crc"
+	sbpSumParam  = "sum"
+	sbpTypeParam = "type"
+	sbpBankParam = "bank"
+	sbpCurParam  = "cur"
+)
+
+var (
+	sbpIDFormat   = regexp.MustCompile(`^[A-Z0-9]{1,32}$`)
+	sbpBankFormat = regexp.MustCompile(`^[0-9]{12}$`)
+)
+
+// SBP - платежная ссылка Системы быстрых платежей НСПК
+// вида https://qr.nspk.ru/AS1000...?type=01&bank=100000000111&sum=10000&cur=RUB&crc=AB75
+type SBP struct {
+	ID       string  // Идентификатор QR-кода в НСПК
+	Type     string  // SBPStatic либо SBPDynamic
+	Bank     string  // Идентификатор банка получателя в СБП
+	Sum      float64 // Сумма платежа, ₽. 0 - сумму вводит плательщик
+	Currency string  // Валюта, всегда RUB
+	Extra    []Field // Прочие параметры ТСП в порядке следования в ссылке
+}
+
+// IsSBPLink - является ли текст ссылкой СБП
+func IsSBPLink(text string) bool {
+	return strings.HasPrefix(strings.ToLower(text), sbpPrefix) ||
+		strings.HasPrefix(strings.ToLower(text), "http://"+sbpHost+"/")
+}
+
+// ParseSBP - разбирает платежную ссылку СБП и сверяет контрольную сумму, если она есть
+func ParseSBP(link string) (*SBP, error) {
+	if !IsSBPLink(link) {
+		return nil, errors.New("Is not a SBP payment link")
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+	sbp := &SBP{ID: strings.Trim(parsed.Path, "/")}
+	// Контрольная сумма считается по всей ссылке до разделителя перед параметром crc
+	offset := strings.Index(link, "?") + 1
+	for _, param := range strings.Split(parsed.RawQuery, "&") {
+		paramStart := offset
+		offset += len(param) + 1
+		rawKey, rawValue, _ := strings.Cut(param, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			return nil, err
+		}
+		value, err := url.QueryUnescape(rawValue)
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "":
+		case sbpCRCParam:
+			if actual := crc16(link[:paramStart-1]); !strings.EqualFold(value, actual) {
+				return nil, fmt.Errorf("SBP link checksum mismatch: %s != %s", value, actual)
+			}
+		case sbpTypeParam:
+			sbp.Type = value
+		case sbpBankParam:
+			sbp.Bank = value
+		case sbpCurParam:
+			sbp.Currency = value
+		case sbpSumParam:
+			kopecks, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, errors.New("sum is not a number: " + value)
+			}
+			sbp.Sum = float64(kopecks) / 100
+		default:
+			sbp.Extra = append(sbp.Extra, Field{Key: key, Value: value})
+		}
+	}
+	return sbp, sbp.Validate()
+}
+
+// Validate - проверяет параметры ссылки СБП
+func (s *SBP) Validate() error {
+	var errs ValidationError
+	if !sbpIDFormat.MatchString(s.ID) {
+		errs = append(errs, FieldError{Field: "ID", Message: "идентификатор QR-кода НСПК должен содержать до 32 латинских букв и цифр"})
+	}
+	if s.Type != SBPStatic && s.Type != SBPDynamic {
+		errs = append(errs, FieldError{Field: sbpTypeParam, Message: "тип QR-кода должен быть 01 или 02"})
+	}
+	if len(s.Bank) > 0 && !sbpBankFormat.MatchString(s.Bank) {
+		errs = append(errs, FieldError{Field: sbpBankParam, Message: "идентификатор банка должен состоять из 12 цифр"})
+	}
+	if len(s.Currency) > 0 && s.Currency != sbpCurrency {
+		errs = append(errs, FieldError{Field: sbpCurParam, Message: "СБП принимает только RUB"})
+	}
+	if s.Sum < 0 {
+		errs = append(errs, FieldError{Field: sbpSumParam, Message: "сумма не может быть отрицательной"})
+	}
+	if s.Type == SBPDynamic && s.Sum == 0 {
+		errs = append(errs, FieldError{Field: sbpSumParam, Message: "в одноразовом QR-коде сумма обязательна"})
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// WithSum - копия ссылки с суммой sum.
+// В одноразовом QR-коде сумма уже задана банком и не меняется
+func (s *SBP) WithSum(sum float64) SBP {
+	result := *s
+	if result.Type == SBPStatic {
+		result.Sum = sum
+	}
+	return result
+}
+
+// String - собирает платежную ссылку с контрольной суммой
+func (s *SBP) String() string {
+	params := []Field{{Key: sbpTypeParam, Value: s.Type}}
+	if len(s.Bank) > 0 {
+		params = append(params, Field{Key: sbpBankParam, Value: s.Bank})
+	}
+	if s.Sum > 0 {
+		params = append(params, Field{Key: sbpSumParam, Value: strconv.Itoa(int(math.Round(s.Sum * 100)))})
+	}
+	if len(s.Currency) > 0 || s.Sum > 0 {
+		params = append(params, Field{Key: sbpCurParam, Value: sbpCurrency})
+	}
+	params = append(params, s.Extra...)
+
+	query := make([]string, len(params))
+	for i, param := range params {
+		query[i] = url.QueryEscape(param.Key) + "=" + url.QueryEscape(param.Value)
+	}
+	link := sbpPrefix + s.ID + "?" + strings.Join(query, "&")
+	return link + "&" + sbpCRCParam + "=" + crc16(link)
+}
+
+// Png - создает QR-код СБП в формате png размера size пикселей
+func (s *SBP) Png(size int) ([]byte, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return qrcode.Encode(s.String(), qrcode.Medium, size)
+}
+
+// PngFile - сохраняет файл с QR-кодом СБП
+func (s *SBP) PngFile(filename string, size int) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	return qrcode.WriteFile(s.String(), qrcode.Medium, size, filename)
+}
+
+// ToEntity - реквизит, оплачиваемый через СБП: ссылка хранится в Link
+func (s *SBP) ToEntity(name string) (entity.Requisite, error) {
+	photo, err := s.Png(512)
+	if err != nil {
+		return entity.Requisite{}, err
+	}
+	return entity.Requisite{Name: name, PhotoData: string(photo), Link: s.String()}, nil
+}
+
+// crc16 - контрольная сумма CRC-16/CCITT-FALSE в виде 4 hex-символов
+func crc16(text string) string {
+	crc := uint16(0xFFFF)
+	for i := 0; i < len(text); i++ {
+		crc ^= uint16(text[i]) << 8
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return fmt.Sprintf("%04X", crc)
+}
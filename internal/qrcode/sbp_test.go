@@ -0,0 +1,107 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"main/internal/entity"
+	"strings"
+	"testing"
+)
+
+func TestSBPRoundTrip(t *testing.T) {
+	sbp := SBP{ID: "AS1000670LSS7DN18SJQDNP4B05KLJL2", Type: SBPStatic, Bank: "100000000111",
+		Sum: 100.5, Extra: []Field{{Key: "payment_purpose", Value: "Оплата подписки"}}}
+	link := sbp.String()
+	if !strings.HasPrefix(link, "https://qr.nspk.ru/AS1000670LSS7DN18SJQDNP4B05KLJL2?type=01&bank=100000000111&sum=10050&cur=RUB") {
+		t.Errorf("Неверная ссылка: %s", link)
+	}
+	parsed, err := ParseSBP(link)
+	if err != nil {
+		t.Fatalf("Ошибка разбора: %v", err)
+	}
+	if parsed.ID != sbp.ID || parsed.Sum != sbp.Sum || parsed.Bank != sbp.Bank || parsed.Currency != "RUB" {
+		t.Errorf("Неверно разобрана ссылка: %+v", parsed)
+	}
+	if len(parsed.Extra) != 1 || parsed.Extra[0].Value != "Оплата подписки" {
+		t.Errorf("Не сохранены параметры ТСП: %v", parsed.Extra)
+	}
+	if parsed.String() != link {
+		t.Errorf("Ожидали %s, получили %s", link, parsed.String())
+	}
+}
+
+func TestSBPChecksum(t *testing.T) {
+	sbp := SBP{ID: "AS1000670LSS7DN18SJQDNP4B05KLJL2", Type: SBPStatic}
+	link := sbp.String()
+	if _, err := ParseSBP(strings.Replace(link, "type=01", "type=02", 1)); err == nil {
+		t.Error("Ожидали ошибку контрольной суммы")
+	}
+	if _, err := ParseSBP("https://qr.nspk.ru/AS1000670LSS7DN18SJQDNP4B05KLJL2?type=01&bank=100000000111"); err != nil {
+		t.Errorf("Ссылка без crc должна приниматься: %v", err)
+	}
+	base := "https://qr.nspk.ru/AS1000670LSS7DN18SJQDNP4B05KLJL2"
+	if _, err := ParseSBP(base + "?crc=" + crc16(base) + "&type=01"); err != nil {
+		t.Errorf("Ссылка с crc первым параметром должна приниматься: %v", err)
+	}
+	if _, err := ParseSBP(base + "?crc=0000&type=01"); err == nil {
+		t.Error("Ожидали ошибку контрольной суммы в первом параметре")
+	}
+}
+
+func TestSBPResetsPayment(t *testing.T) {
+	qr := QRCode{Payment: validPayment()}
+	sbp := SBP{ID: "AS1000670LSS7DN18SJQDNP4B05KLJL2", Type: SBPStatic}
+	if err := qr.AnalyseText(sbp.String()); err != nil || !qr.IsSBP() {
+		t.Fatalf("Ссылка СБП не распознана: %v", err)
+	}
+	if qr.Payment.Name != "" || qr.Payment.Sum != 0 {
+		t.Errorf("Реквизиты прошлого QR-кода не сброшены: %+v", qr.Payment)
+	}
+}
+
+func TestFromEntityNonSBPLink(t *testing.T) {
+	payment := validPayment()
+	content, err := payment.String(Windows1251)
+	if err != nil {
+		t.Fatalf("Ошибка сериализации: %v", err)
+	}
+	requisite := entity.Requisite{Link: "https://example.com/pay", Content: content}
+	qr := QRCode{}
+	if err := qr.FromEntity(&requisite); err != nil || qr.IsSBP() {
+		t.Fatalf("Ожидали реквизиты по ГОСТ из Content, получили %v", err)
+	}
+	if qr.Payment.PersonalAcc != payment.PersonalAcc {
+		t.Errorf("Неверно разобраны реквизиты: %+v", qr.Payment)
+	}
+}
+
+func TestSBPImage(t *testing.T) {
+	sbp := SBP{ID: "AS1000670LSS7DN18SJQDNP4B05KLJL2", Type: SBPStatic, Bank: "100000000111"}
+	requisite, err := sbp.ToEntity("СБП")
+	if err != nil {
+		t.Fatalf("Ошибка создания реквизита: %v", err)
+	}
+	qr := QRCode{}
+	if err := qr.FromEntity(&requisite); err != nil || !qr.IsSBP() {
+		t.Fatalf("Реквизит не распознан как СБП: %v", err)
+	}
+	data, err := qr.Image(250, 512)
+	if err != nil {
+		t.Fatalf("Ошибка создания QR-кода: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Ошибка чтения png: %v", err)
+	}
+	decoded := QRCode{}
+	if err := decoded.AnalyseImage(img); err != nil || !decoded.IsSBP() {
+		t.Fatalf("Ошибка распознавания QR-кода СБП: %v", err)
+	}
+	if decoded.SBP.Sum != 250 {
+		t.Errorf("Ожидали сумму 250 в статическом QR-коде, получили %v", decoded.SBP.Sum)
+	}
+	dynamic := SBP{ID: "AD1000670LSS7DN18SJQDNP4B05KLJL2", Type: SBPDynamic, Sum: 100}
+	if link := dynamic.WithSum(250); link.Sum != 100 {
+		t.Errorf("Сумма одноразового QR-кода не должна меняться, получили %v", link.Sum)
+	}
+}
@@ -0,0 +1,192 @@
+package qrcode
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError - ошибка проверки одного реквизита платежа
+type FieldError struct {
+	Field   string // Имя реквизита, как в QR-коде
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError - все найденные ошибки реквизитов платежа
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	messages := make([]string, len(e))
+	for i, fieldError := range e {
+		messages[i] = fieldError.Error()
+	}
+	return "invalid payment requisites: " + strings.Join(messages, "; ")
+}
+
+// Fields - имена реквизитов с ошибками
+func (e ValidationError) Fields() []string {
+	fields := make([]string, len(e))
+	for i, fieldError := range e {
+		fields[i] = fieldError.Field
+	}
+	return fields
+}
+
+// Максимальные длины значений по ГОСТ Р 56042-2014
+var fieldMaxLength = map[string]int{
+	"Name":         160,
+	"PersonalAcc":  20,
+	"BankName":     45,
+	"BIC":          9,
+	"CorrespAcc":   20,
+	"Purpose":      210,
+	"PayeeINN":     12,
+	"PayerINN":     12,
+	"DrawerStatus": 2,
+	"KPP":          9,
+	"CBC":          20,
+	"OKTMO":        11,
+	"PaytReason":   2,
+	"TaxPeriod":    10,
+	"DocNo":        15,
+	"DocDate":      10,
+	"TaxPaytKind":  2,
+	"UIN":          25,
+}
+
+// Обязательные реквизиты по ГОСТ Р 56042-2014
+var mandatoryKeys = []string{"Name", "PersonalAcc", "BankName", "BIC", "CorrespAcc"}
+
+var (
+	digitsOnly = regexp.MustCompile(`^[0-9]+$`)
+	kppFormat  = regexp.MustCompile(`^[0-9]{4}[0-9A-Z]{2}[0-9]{3}$`)
+)
+
+// Validate - проверяет реквизиты платежа: форматы и контрольные числа
+// БИК, счетов, ИНН, КПП, длины полей и отсутствие разделителя "|" в значениях.
+// Возвращает ValidationError со всеми найденными ошибками либо nil
+func (p *Payment) Validate() error {
+	var errs ValidationError
+	add := func(field, message string) {
+		errs = append(errs, FieldError{Field: field, Message: message})
+	}
+
+	for _, field := range p.Fields() {
+		if strings.Contains(field.Key, "|") || strings.Contains(field.Key, "=") || len(field.Key) == 0 {
+			add(field.Key, "недопустимое имя реквизита")
+		}
+		if strings.Contains(field.Value, "|") {
+			add(field.Key, "значение содержит запрещенный символ '|'")
+		}
+		if limit, ok := fieldMaxLength[field.Key]; ok && utf8.RuneCountInString(field.Value) > limit {
+			add(field.Key, "длина превышает "+strconv.Itoa(limit)+" символов")
+		}
+	}
+	for _, key := range mandatoryKeys {
+		if len(*fieldsByKey[key].value(p)) == 0 {
+			add(key, "обязательный реквизит не заполнен")
+		}
+	}
+	if p.Sum < 0 {
+		add(sumKey, "сумма не может быть отрицательной")
+	}
+
+	bicValid := validBIC(p.BIC)
+	if len(p.BIC) > 0 && !bicValid {
+		add("BIC", "БИК должен состоять из 9 цифр и начинаться с 04")
+	}
+	if len(p.CorrespAcc) > 0 && p.CorrespAcc != "0" {
+		if !validAccountFormat(p.CorrespAcc) {
+			add("CorrespAcc", "счет должен состоять из 20 цифр")
+		} else if bicValid && strings.HasPrefix(p.CorrespAcc, "30101") {
+			if !isSettlementCenter(p.BIC) && p.CorrespAcc[17:] != p.BIC[6:] {
+				add("CorrespAcc", "последние 3 цифры корр. счета не совпадают с БИК")
+			} else if !accountControlValid("0"+p.BIC[4:6], p.CorrespAcc) {
+				add("CorrespAcc", "неверный контрольный ключ корр. счета для БИК "+p.BIC)
+			}
+		}
+	}
+	if len(p.PersonalAcc) > 0 {
+		if !validAccountFormat(p.PersonalAcc) {
+			add("PersonalAcc", "счет должен состоять из 20 цифр")
+		} else if bicValid && !accountControlValid(personalAccPrefix(p.BIC), p.PersonalAcc) {
+			add("PersonalAcc", "неверный контрольный ключ счета для БИК "+p.BIC)
+		}
+	}
+	if len(p.PayeeINN) > 0 && !ValidINN(p.PayeeINN) {
+		add("PayeeINN", "неверный ИНН")
+	}
+	if len(p.PayerINN) > 0 && !ValidINN(p.PayerINN) {
+		add("PayerINN", "неверный ИНН")
+	}
+	if len(p.KPP) > 0 && p.KPP != "0" && !kppFormat.MatchString(p.KPP) {
+		add("KPP", "КПП должен состоять из 9 символов: NNNNPPNNN")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ValidINN - проверяет контрольные цифры ИНН юрлица (10 цифр) или физлица/ИП (12 цифр)
+func ValidINN(inn string) bool {
+	if !digitsOnly.MatchString(inn) {
+		return false
+	}
+	switch len(inn) {
+	case 10:
+		return innControlDigit(inn[:9], []int{2, 4, 10, 3, 5, 9, 4, 6, 8}) == inn[9]
+	case 12:
+		return innControlDigit(inn[:10], []int{7, 2, 4, 10, 3, 5, 9, 4, 6, 8}) == inn[10] &&
+			innControlDigit(inn[:11], []int{3, 7, 2, 4, 10, 3, 5, 9, 4, 6, 8}) == inn[11]
+	default:
+		return false
+	}
+}
+
+func innControlDigit(digits string, weights []int) byte {
+	sum := 0
+	for i, weight := range weights {
+		sum += int(digits[i]-'0') * weight
+	}
+	return byte('0' + sum%11%10)
+}
+
+func validBIC(bic string) bool {
+	return len(bic) == 9 && digitsOnly.MatchString(bic) && strings.HasPrefix(bic, "04")
+}
+
+// isSettlementCenter - БИК расчетно-кассового центра или территориального органа казначейства
+func isSettlementCenter(bic string) bool {
+	suffix := bic[6:]
+	return suffix == "000" || suffix == "001" || suffix == "002"
+}
+
+func personalAccPrefix(bic string) string {
+	if isSettlementCenter(bic) {
+		return "0" + bic[4:6]
+	}
+	return bic[6:]
+}
+
+func validAccountFormat(account string) bool {
+	return len(account) == 20 && digitsOnly.MatchString(account)
+}
+
+// accountControlValid - проверка контрольного ключа счета по методике Банка России:
+// 3 цифры от БИК + 20 цифр счета с весами 7, 1, 3
+func accountControlValid(prefix, account string) bool {
+	weights := [3]int{7, 1, 3}
+	digits := prefix + account
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		sum += int(digits[i]-'0') * weights[i%3] % 10
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,73 @@
+package qrcode
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func validPayment() Payment {
+	return Payment{
+		Name:        "ООО «Три кита»",
+		PersonalAcc: "40702810138250123017",
+		BankName:    "ПАО Сбербанк",
+		BIC:         "044525225",
+		CorrespAcc:  "30101810400000000225",
+		KPP:         "773601001",
+		PayeeINN:    "7707083893",
+		Purpose:     "Оплата подписки",
+		Sum:         4800,
+	}
+}
+
+func TestValidateCorrect(t *testing.T) {
+	payment := validPayment()
+	if err := payment.Validate(); err != nil {
+		t.Errorf("Ожидали корректные реквизиты, получили %v", err)
+	}
+	payment.PayeeINN = "500100732259"
+	payment.KPP = "0"
+	if err := payment.Validate(); err != nil {
+		t.Errorf("Ожидали корректные реквизиты ИП, получили %v", err)
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(p *Payment)
+		fields []string
+	}{
+		{"БИК", func(p *Payment) { p.BIC = "04452522" }, []string{"BIC"}},
+		{"корр. счет другого банка", func(p *Payment) { p.CorrespAcc = "30101810145250000974" }, []string{"CorrespAcc"}},
+		{"опечатка в счете", func(p *Payment) { p.PersonalAcc = "40702810138250123018" }, []string{"PersonalAcc"}},
+		{"ИНН 10", func(p *Payment) { p.PayeeINN = "7707083894" }, []string{"PayeeINN"}},
+		{"ИНН 12", func(p *Payment) { p.PayeeINN = "500100732258" }, []string{"PayeeINN"}},
+		{"КПП", func(p *Payment) { p.KPP = "77360100" }, []string{"KPP"}},
+		{"разделитель", func(p *Payment) { p.Purpose = "Оплата|подписки" }, []string{"Purpose"}},
+		{"длина", func(p *Payment) { p.BankName = string(make([]rune, 46)) }, []string{"BankName"}},
+		{"обязательное", func(p *Payment) { p.Name = "" }, []string{"Name"}},
+		{"обязательный корр. счет", func(p *Payment) { p.CorrespAcc = "" }, []string{"CorrespAcc"}},
+	}
+	for _, c := range cases {
+		payment := validPayment()
+		c.modify(&payment)
+		err := payment.Validate()
+		var validationError ValidationError
+		if !errors.As(err, &validationError) {
+			t.Errorf("%s: ожидали ValidationError, получили %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(validationError.Fields(), c.fields) {
+			t.Errorf("%s: ожидали ошибки в %v, получили %v", c.name, c.fields, validationError)
+		}
+	}
+}
+
+func TestPngValidates(t *testing.T) {
+	payment := validPayment()
+	payment.BIC = "123"
+	if _, err := payment.Png(UTF8, 256); err == nil {
+		t.Error("Ожидали ошибку проверки реквизитов перед созданием QR-кода")
+	}
+}
@@ -0,0 +1,161 @@
+package reachability
+
+import (
+	"errors"
+	"fmt"
+	"github.com/and3rson/telemux/v2"
+	"log/slog"
+	"main/internal/database/entitybase"
+	"main/internal/entity"
+	"main/internal/telegram"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Статусы бота в личном чате из my_chat_member
+const (
+	memberStatusKicked = "kicked"
+	memberStatusMember = "member"
+)
+
+var reasonTitles = map[string]string{
+	entity.UnreachableBlocked:     "заблокировали бота",
+	entity.UnreachableDeactivated: "удалили аккаунт",
+	entity.UnreachableNotStarted:  "не запускали бота",
+}
+
+// Tracker - отмечает пользователей, которым бот не может писать: по ошибкам 403
+// при отправке и по обновлениям my_chat_member, когда пользователь блокирует
+// или снова запускает бота
+type Tracker struct {
+	users entitybase.EntityBase[entity.User]
+	now   func() time.Time
+}
+
+func NewTracker(users entitybase.EntityBase[entity.User]) *Tracker {
+	return &Tracker{users: users, now: time.Now}
+}
+
+// MarkUnreachable - пользователю больше нельзя писать; сигнатура подходит для telegram.Outbox.Unreachable
+func (t *Tracker) MarkUnreachable(telegramID int64, reason string) {
+	t.set(telegramID, true, reason)
+}
+
+// MarkReachable - пользователь снова запустил бота
+func (t *Tracker) MarkReachable(telegramID int64) {
+	t.set(telegramID, false, "")
+}
+
+func (t *Tracker) set(telegramID int64, unreachable bool, reason string) {
+	user, err := t.users.Get(entity.User{UserTelegramId: telegramID})
+	// пользователя, которого нет в базе, отмечать негде
+	if errors.Is(err, entitybase.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		slog.Warn("reachability: load user", "user_id", telegramID, "error", err)
+		return
+	}
+	if user.Unreachable == unreachable && user.UnreachableReason == reason {
+		return
+	}
+	user.Unreachable = unreachable
+	user.UnreachableReason = reason
+	user.UnreachableSince = time.Time{}
+	if unreachable {
+		user.UnreachableSince = t.now()
+	}
+	if err := t.users.Update(user); err != nil {
+		slog.Error("reachability: save user", "user_id", telegramID, "error", err)
+		return
+	}
+	slog.Info("reachability changed", "user_id", telegramID, "unreachable", unreachable, "reason", reason)
+}
+
+// Reachable - можно ли писать пользователю; для напоминаний и других рассылок вне broadcast
+func Reachable(user entity.User) bool {
+	return !user.Unreachable
+}
+
+// MakeChatMemberCommand - обработка my_chat_member в личных чатах: блокировка бота
+// и повторный запуск. Регистрировать в боте, который пишет пользователям
+func MakeChatMemberCommand(tracker *Tracker) telegram.TelegramCommand {
+	return telegram.MakeFullCommand("my_chat_member", "",
+		func(u *telemux.Update) bool {
+			return u.MyChatMember != nil && u.MyChatMember.Chat.IsPrivate()
+		},
+		telegram.SimpleActionStruct{
+			SimpleAction: func(u *telemux.Update) {
+				member := u.MyChatMember
+				switch member.NewChatMember.Status {
+				case memberStatusKicked:
+					tracker.MarkUnreachable(member.From.ID, entity.UnreachableBlocked)
+				case memberStatusMember:
+					tracker.MarkReachable(member.From.ID)
+				}
+			},
+		})
+}
+
+// Stats - сколько пользователей доступны для сообщений
+type Stats struct {
+	Reachable   int
+	Unreachable int
+	// Reasons - недоступные по причинам
+	Reasons map[string]int
+}
+
+// Stats - подсчет по всем пользователям
+func (t *Tracker) Stats() (Stats, error) {
+	users, err := t.users.GetAll()
+	if err != nil {
+		return Stats{}, err
+	}
+	return Count(users), nil
+}
+
+// Count - подсчет доступных и недоступных пользователей
+func Count(users []entity.User) Stats {
+	stats := Stats{Reasons: make(map[string]int)}
+	for _, user := range users {
+		if user.UserTelegramId == 0 {
+			continue
+		}
+		if Reachable(user) {
+			stats.Reachable++
+			continue
+		}
+		stats.Unreachable++
+		stats.Reasons[user.UnreachableReason]++
+	}
+	return stats
+}
+
+// Text - отчет для администраторов
+func (s Stats) Text() string {
+	total := s.Reachable + s.Unreachable
+	percent := 0
+	if total > 0 {
+		percent = s.Reachable * 100 / total
+	}
+	text := fmt.Sprintf("Пользователей: %d\nДоступны для сообщений: %d (%d%%)\nНедоступны: %d",
+		total, s.Reachable, percent, s.Unreachable)
+	reasons := make([]string, 0, len(s.Reasons))
+	for reason := range s.Reasons {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	var lines []string
+	for _, reason := range reasons {
+		title, ok := reasonTitles[reason]
+		if !ok {
+			title = reason
+		}
+		lines = append(lines, fmt.Sprintf("• %s: %d", title, s.Reasons[reason]))
+	}
+	if len(lines) > 0 {
+		text += "\n" + strings.Join(lines, "\n")
+	}
+	return text
+}
@@ -0,0 +1,73 @@
+package reachability
+
+import (
+	"github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"main/internal/database/entitybase/memoryentitybase"
+	"main/internal/entity"
+	"strings"
+	"testing"
+	"time"
+)
+
+func chatMemberUpdate(userID int64, status string) *telemux.Update {
+	return &telemux.Update{Update: tgbotapi.Update{MyChatMember: &tgbotapi.ChatMemberUpdated{
+		Chat:          tgbotapi.Chat{ID: userID, Type: "private"},
+		From:          tgbotapi.User{ID: userID},
+		NewChatMember: tgbotapi.ChatMember{Status: status},
+	}}}
+}
+
+func TestChatMemberCommand(t *testing.T) {
+	users := memoryentitybase.InitMemoryEntityBase(entity.User{ID: 1, UserTelegramId: 10})
+	tracker := NewTracker(users)
+	since := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return since }
+	command := MakeChatMemberCommand(tracker)
+
+	blocked := chatMemberUpdate(10, memberStatusKicked)
+	if !command.Filter(blocked) {
+		t.Fatalf("my_chat_member в личном чате должен обрабатываться")
+	}
+	command.Action.Action(blocked)
+	if user, _ := users.Get(entity.User{UserTelegramId: 10}); !user.Unreachable || user.UnreachableReason != entity.UnreachableBlocked || !user.UnreachableSince.Equal(since) {
+		t.Errorf("после блокировки пользователь должен быть недоступен: %+v", user)
+	}
+	command.Action.Action(chatMemberUpdate(10, memberStatusMember))
+	if user, _ := users.Get(entity.User{UserTelegramId: 10}); user.Unreachable || len(user.UnreachableReason) > 0 || !user.UnreachableSince.IsZero() {
+		t.Errorf("после повторного запуска пользователь должен быть доступен: %+v", user)
+	}
+
+	group := chatMemberUpdate(-100, memberStatusKicked)
+	group.MyChatMember.Chat.Type = "supergroup"
+	if command.Filter(group) {
+		t.Errorf("удаление бота из группы не относится к пользователю")
+	}
+	// неизвестный пользователь не создается
+	command.Action.Action(chatMemberUpdate(20, memberStatusKicked))
+	if all, _ := users.GetAll(); len(all) != 1 {
+		t.Errorf("неизвестный пользователь не должен сохраняться: %+v", all)
+	}
+}
+
+func TestStats(t *testing.T) {
+	users := memoryentitybase.InitMemoryEntityBase(
+		entity.User{UserTelegramId: 1},
+		entity.User{UserTelegramId: 2},
+		entity.User{UserTelegramId: 3, Unreachable: true, UnreachableReason: entity.UnreachableBlocked},
+		entity.User{UserTelegramId: 4, Unreachable: true, UnreachableReason: entity.UnreachableDeactivated},
+	)
+	stats, err := NewTracker(users).Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Reachable != 2 || stats.Unreachable != 2 || stats.Reasons[entity.UnreachableBlocked] != 1 {
+		t.Errorf("подсчет: %+v", stats)
+	}
+	text := stats.Text()
+	for _, part := range []string{"Доступны для сообщений: 2 (50%)", "заблокировали бота: 1", "удалили аккаунт: 1"} {
+		if !strings.Contains(text, part) {
+			t.Errorf("в отчете нет %q:\n%s", part, text)
+		}
+	}
+}
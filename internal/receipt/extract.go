@@ -0,0 +1,161 @@
+package receipt
+
+import (
+	"bytes"
+	"errors"
+	"github.com/ledongthuc/pdf"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Location - часовой пояс, в котором банки печатают время операции
+var Location = time.FixedZone("MSK", 3*60*60)
+
+// Extracted - данные, найденные в тексте чека
+type Extracted struct {
+	Amount        float64   // Сумма операции, ₽. 0 - не найдена
+	Date          time.Time // Дата и время операции
+	Account       string    // Счет получателя, может быть частично скрыт: **3017
+	Purpose       string    // Назначение платежа или сообщение получателю
+	TransactionID string    // Номер операции в банке или СБП, уникален для каждого перевода
+	Text          string    // Весь текст чека
+}
+
+var (
+	amountPattern      = regexp.MustCompile(`(?m)(сумма(?: перевода| платежа| операции| списания| зачисления)?|итого|всего|amount|total)([^0-9\n]{0,25})([0-9]{1,3}(?: [0-9]{3})+|[0-9]+)(?:[.,]([0-9]{1,2}))?`)
+	accountPattern     = regexp.MustCompile(`(?m)(?:сч[её]т(?:а)? (?:получателя|зачисления)|номер сч[её]та получателя|recipient account|beneficiary account|account)[^0-9*•\n]{0,25}([0-9*•]{4,24})`)
+	anyAccount         = regexp.MustCompile(`(?:^|[^0-9])([0-9]{20})(?:[^0-9]|$)`)
+	purposePattern     = regexp.MustCompile(`(?m)(?:назначение(?: платежа)?|сообщение(?: получателю)?|purpose|comment)[ \t]*:?[ \t]*(.+)$`)
+	numericDate        = regexp.MustCompile(`([0-9]{2})\.([0-9]{2})\.([0-9]{4})(?:[^0-9\n]{1,4}([0-9]{2}):([0-9]{2})(?::([0-9]{2}))?)?`)
+	transactionPattern = regexp.MustCompile(`(?m)(?:номер операции|номер документа|номер транзакции|номер квитанции|идентификатор (?:операции|платежа)(?: в сбп)?|id операции|№ операции|transaction id|operation id)[ \t]*[:№]?[ \t]*([0-9a-z][0-9a-z-]{5,})`)
+	wordDate           = regexp.MustCompile(`([0-9]{1,2}) ([а-я]+) ([0-9]{4})(?:[^0-9\n]{1,4}([0-9]{2}):([0-9]{2})(?::([0-9]{2}))?)?`)
+)
+
+var months = map[string]time.Month{
+	"января": time.January, "февраля": time.February, "марта": time.March,
+	"апреля": time.April, "мая": time.May, "июня": time.June,
+	"июля": time.July, "августа": time.August, "сентября": time.September,
+	"октября": time.October, "ноября": time.November, "декабря": time.December,
+}
+
+// ExtractText - текст PDF-чека построчно
+func ExtractText(data []byte) (string, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF")) {
+		return "", errors.New("file is not a PDF document")
+	}
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+	var text strings.Builder
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+		rows, err := page.GetTextByRow()
+		if err != nil {
+			return "", err
+		}
+		for _, row := range rows {
+			for _, word := range row.Content {
+				text.WriteString(word.S)
+			}
+			text.WriteString("\n")
+		}
+	}
+	return text.String(), nil
+}
+
+// Parse - ищет в тексте чека сумму, дату, счет получателя и назначение платежа
+func Parse(text string) Extracted {
+	normalized := normalize(text)
+	extracted := Extracted{Text: text}
+	for _, match := range amountPattern.FindAllStringSubmatch(normalized, -1) {
+		if strings.Contains(match[2], "комис") || strings.Contains(match[2], "fee") {
+			continue
+		}
+		amount, err := strconv.ParseFloat(strings.ReplaceAll(match[3], " ", "")+"."+match[4]+"0", 64)
+		if err == nil && amount > 0 {
+			extracted.Amount = amount
+			break
+		}
+	}
+	if match := accountPattern.FindStringSubmatch(normalized); match != nil {
+		extracted.Account = strings.ReplaceAll(match[1], "•", "*")
+	} else if accounts := distinct(anyAccount.FindAllStringSubmatch(normalized, -1)); len(accounts) == 1 {
+		extracted.Account = accounts[0]
+	}
+	if match := purposePattern.FindStringSubmatchIndex(normalized); match != nil {
+		// значение берется из исходного текста, чтобы сохранить регистр
+		extracted.Purpose = strings.TrimSpace(originalSlice(text, normalized, match[2], match[3]))
+	}
+	if match := transactionPattern.FindStringSubmatch(normalized); match != nil {
+		extracted.TransactionID = strings.ToUpper(match[1])
+	}
+	extracted.Date = parseDate(normalized)
+	return extracted
+}
+
+// spaces - неразрывные и узкие пробелы, которыми банки разделяют разряды суммы
+var spaces = strings.NewReplacer("\u00a0", " ", "\u202f", " ", "\u2009", " ", "\r", "")
+
+// normalize - нижний регистр и обычные пробелы вместо неразрывных
+func normalize(text string) string {
+	return strings.ToLower(spaces.Replace(text))
+}
+
+// originalSlice - фрагмент исходного текста, соответствующий байтам [start, end) нормализованного
+func originalSlice(original, normalized string, start, end int) string {
+	originalRunes := []rune(spaces.Replace(original))
+	runeStart := len([]rune(normalized[:start]))
+	runeEnd := len([]rune(normalized[:end]))
+	if runeEnd > len(originalRunes) {
+		return normalized[start:end]
+	}
+	return string(originalRunes[runeStart:runeEnd])
+}
+
+func distinct(matches [][]string) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, match := range matches {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			result = append(result, match[1])
+		}
+	}
+	return result
+}
+
+func parseDate(text string) time.Time {
+	if match := numericDate.FindStringSubmatch(text); match != nil {
+		month, _ := strconv.Atoi(match[2])
+		if date, ok := makeDate(match[1], time.Month(month), match[3], match[4:]); ok {
+			return date
+		}
+	}
+	for _, match := range wordDate.FindAllStringSubmatch(text, -1) {
+		if month, ok := months[match[2]]; ok {
+			if date, ok := makeDate(match[1], month, match[3], match[4:]); ok {
+				return date
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func makeDate(dayText string, month time.Month, yearText string, clock []string) (time.Time, bool) {
+	day, _ := strconv.Atoi(dayText)
+	year, _ := strconv.Atoi(yearText)
+	hour, _ := strconv.Atoi(clock[0])
+	minute, _ := strconv.Atoi(clock[1])
+	second, _ := strconv.Atoi(clock[2])
+	if day < 1 || day > 31 || month < 1 || month > 12 || hour > 23 || minute > 59 || second > 59 {
+		return time.Time{}, false
+	}
+	date := time.Date(year, month, day, hour, minute, second, 0, Location)
+	return date, date.Day() == day
+}
@@ -0,0 +1,143 @@
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"main/internal/entity"
+	"main/internal/hash"
+	"math/bits"
+	"strings"
+)
+
+// SimilarityThreshold - максимальное число различающихся бит перцептивного хеша,
+// при котором скриншоты считаются одним и тем же чеком
+const SimilarityThreshold = 6
+
+// Fingerprint - отпечаток чека для поиска повторов
+type Fingerprint struct {
+	ContentHash    string // SHA-256 содержимого файла
+	PerceptualHash uint64 // dHash изображения, 0 - чек не является картинкой
+	TransactionID  string // Номер операции из текста чека
+}
+
+// Duplicate - ранее присланный чек, похожий на проверяемый
+type Duplicate struct {
+	Payment  entity.Payment
+	Reason   string
+	Distance int // Число различающихся бит перцептивного хеша
+}
+
+// TakeFingerprint - отпечаток чека из сообщения пользователя
+func TakeFingerprint(message entity.MessageFromUserBot) Fingerprint {
+	fingerprint := Fingerprint{ContentHash: hash.SHA256(string(message.RequisiteContent))}
+	if message.IsImage {
+		if img, _, err := image.Decode(bytes.NewReader(message.RequisiteContent)); err == nil {
+			fingerprint.PerceptualHash = PerceptualHash(img)
+		}
+		return fingerprint
+	}
+	text := string(message.RequisiteContent)
+	if message.IsFile {
+		text, _ = ExtractText(message.RequisiteContent)
+	}
+	fingerprint.TransactionID = Parse(text).TransactionID
+	return fingerprint
+}
+
+// Apply - сохраняет отпечаток в платеже
+func (f Fingerprint) Apply(payment *entity.Payment) {
+	payment.ReceiptHash = f.ContentHash
+	payment.ReceiptPHash = f.PerceptualHash
+	payment.TransactionID = f.TransactionID
+}
+
+// FindDuplicates - платежи других заказов с тем же файлом, уже использованным номером
+// операции или похожим скриншотом. Скриншоты одного банка сверстаны одинаково и дают
+// близкие хеши, поэтому похожий скриншот считается повтором, только если платеж на ту же
+// сумму: чек, пересланный другу, подходит лишь к заказу с той же ценой
+func FindDuplicates(fingerprint Fingerprint, current entity.Payment, payments []entity.Payment) []Duplicate {
+	var duplicates []Duplicate
+	for _, payment := range payments {
+		if payment.ID == current.ID {
+			continue
+		}
+		switch {
+		case len(fingerprint.ContentHash) > 0 && payment.ReceiptHash == fingerprint.ContentHash:
+			duplicates = append(duplicates, Duplicate{Payment: payment, Reason: "тот же файл"})
+		case len(fingerprint.TransactionID) > 0 && strings.EqualFold(payment.TransactionID, fingerprint.TransactionID):
+			duplicates = append(duplicates, Duplicate{Payment: payment, Reason: "тот же номер операции " + payment.TransactionID})
+		case fingerprint.PerceptualHash != 0 && payment.ReceiptPHash != 0 && payment.Amount == current.Amount:
+			distance := HammingDistance(fingerprint.PerceptualHash, payment.ReceiptPHash)
+			if distance <= SimilarityThreshold {
+				duplicates = append(duplicates, Duplicate{Payment: payment, Reason: "похожий скриншот на ту же сумму", Distance: distance})
+			}
+		}
+	}
+	return duplicates
+}
+
+// DuplicatesCard - текст о найденных повторах для карточки модерации
+func DuplicatesCard(duplicates []Duplicate) string {
+	if len(duplicates) == 0 {
+		return ""
+	}
+	var card strings.Builder
+	card.WriteString("⚠️ Чек уже присылали:\n")
+	for _, duplicate := range duplicates {
+		card.WriteString(fmt.Sprintf("• платеж #%d пользователя %d от %s — %s",
+			duplicate.Payment.ID, duplicate.Payment.UserID,
+			duplicate.Payment.TimeStamp.In(Location).Format("02.01.2006"), duplicate.Reason))
+		if duplicate.Distance > 0 {
+			card.WriteString(fmt.Sprintf(" (отличие %d из 64)", duplicate.Distance))
+		}
+		card.WriteString("\n")
+	}
+	return card.String()
+}
+
+// PerceptualHash - разностный хеш (dHash): изображение сжимается до 9x8 оттенков серого,
+// каждый бит - сравнение яркости соседних по горизонтали клеток.
+// Устойчив к пересжатию, изменению размера и небольшим правкам
+func PerceptualHash(img image.Image) uint64 {
+	const width, height = 9, 8
+	var sum [height][width]float64
+	var count [height][width]int
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return 0
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := (y - bounds.Min.Y) * height / bounds.Dy()
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			column := (x - bounds.Min.X) * width / bounds.Dx()
+			r, g, b, _ := img.At(x, y).RGBA()
+			sum[row][column] += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			count[row][column]++
+		}
+	}
+	var result uint64
+	for y := 0; y < height; y++ {
+		for x := 0; x < width-1; x++ {
+			result <<= 1
+			if mean(sum[y][x], count[y][x]) < mean(sum[y][x+1], count[y][x+1]) {
+				result |= 1
+			}
+		}
+	}
+	return result
+}
+
+func mean(sum float64, count int) float64 {
+	if count == 0 {
+		return 0
+	}
+	return sum / float64(count)
+}
+
+// HammingDistance - число различающихся бит двух хешей
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -0,0 +1,150 @@
+package receipt
+
+import (
+	"bytes"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"main/internal/entity"
+	"testing"
+	"time"
+)
+
+// testScreenshot - синтетический «скриншот»: градиент с темными полосами
+func testScreenshot(width, height, stripe int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			value := uint8(255 * x / width)
+			if (y*10/height)%stripe == 0 {
+				value = 255 - value
+			}
+			img.Set(x, y, color.RGBA{R: value, G: value, B: value, A: 255})
+		}
+	}
+	return img
+}
+
+func encodePng(t *testing.T, img image.Image) []byte {
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, img); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestPerceptualHash(t *testing.T) {
+	original := testScreenshot(400, 800, 2)
+	var recompressed bytes.Buffer
+	if err := jpeg.Encode(&recompressed, testScreenshot(300, 600, 2), &jpeg.Options{Quality: 40}); err != nil {
+		t.Fatal(err)
+	}
+	resized, _, err := image.Decode(&recompressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if distance := HammingDistance(PerceptualHash(original), PerceptualHash(resized)); distance > SimilarityThreshold {
+		t.Errorf("пересжатый скриншот должен считаться тем же, отличие %d", distance)
+	}
+	if distance := HammingDistance(PerceptualHash(original), PerceptualHash(testScreenshot(400, 800, 3))); distance <= SimilarityThreshold {
+		t.Errorf("разные скриншоты не должны совпадать, отличие %d", distance)
+	}
+}
+
+// bankScreenshot - скриншот чека в одной верстке банка: шапка, логотип и строки «поле - значение»
+func bankScreenshot(t *testing.T, lines ...string) *image.RGBA {
+	face, err := opentype.NewFace(goFont(t), &opentype.FaceOptions{Size: 22, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 400, 800))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, 400, 120), image.NewUniform(color.RGBA{R: 0x21, G: 0xa0, B: 0x38, A: 0xff}), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(160, 30, 240, 90), image.White, image.Point{}, draw.Src)
+	drawer := font.Drawer{Dst: img, Src: image.Black, Face: face}
+	for i, line := range lines {
+		drawer.Dot = fixed.P(24, 180+i*48)
+		drawer.DrawString(line)
+	}
+	return img
+}
+
+func goFont(t *testing.T) *opentype.Font {
+	parsed, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func recompress(t *testing.T, img image.Image) []byte {
+	var buffer bytes.Buffer
+	if err := jpeg.Encode(&buffer, img, &jpeg.Options{Quality: 40}); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestFindDuplicates(t *testing.T) {
+	screenshot := entity.MessageFromUserBot{RequisiteContent: encodePng(t, testScreenshot(200, 400, 2)), IsImage: true}
+	fingerprint := TakeFingerprint(screenshot)
+	if fingerprint.PerceptualHash == 0 || len(fingerprint.ContentHash) != 64 {
+		t.Fatalf("неполный отпечаток: %+v", fingerprint)
+	}
+
+	similar := TakeFingerprint(entity.MessageFromUserBot{RequisiteContent: encodePng(t, testScreenshot(220, 440, 2)), IsImage: true})
+	text := TakeFingerprint(entity.MessageFromUserBot{RequisiteContent: []byte(sberReceipt)})
+	if text.TransactionID != "1000000000123456789" {
+		t.Fatalf("нет номера операции в отпечатке: %+v", text)
+	}
+
+	payments := []entity.Payment{{ID: 1, UserID: 7}, {ID: 2, UserID: 7}, {ID: 3, UserID: 8}, {ID: 4, UserID: 7}}
+	fingerprint.Apply(&payments[0])
+	similar.Apply(&payments[1])
+	text.Apply(&payments[2])
+	payments[2].TimeStamp = time.Date(2026, 9, 18, 12, 0, 0, 0, Location)
+
+	duplicates := FindDuplicates(fingerprint, payments[3], payments)
+	if len(duplicates) != 2 || duplicates[0].Reason != "тот же файл" || duplicates[1].Reason != "похожий скриншот на ту же сумму" {
+		t.Errorf("ожидали тот же файл и похожий скриншот, получили %+v", duplicates)
+	}
+	if duplicates := FindDuplicates(fingerprint, payments[0], payments); len(duplicates) != 1 {
+		t.Errorf("собственный платеж не должен считаться повтором: %+v", duplicates)
+	}
+	duplicates = FindDuplicates(text, payments[3], payments)
+	if len(duplicates) != 1 || duplicates[0].Payment.ID != 3 {
+		t.Errorf("ожидали повтор номера операции, получили %+v", duplicates)
+	}
+	if card := DuplicatesCard(duplicates); card == "" {
+		t.Errorf("пустая карточка повторов")
+	}
+}
+
+func TestSameBankScreenshots(t *testing.T) {
+	first := bankScreenshot(t, "Перевод выполнен", "Сумма 4 800,00 ₽", "18.09.2026 12:41",
+		"Получатель Иван И.", "Номер операции", "A6261094131530010000")
+	second := bankScreenshot(t, "Перевод выполнен", "Сумма 1 200,00 ₽", "02.10.2026 09:15",
+		"Получатель Иван И.", "Номер операции", "A6275061836220010000")
+	firstPrint := TakeFingerprint(entity.MessageFromUserBot{RequisiteContent: encodePng(t, first), IsImage: true})
+	secondPrint := TakeFingerprint(entity.MessageFromUserBot{RequisiteContent: encodePng(t, second), IsImage: true})
+	// верстка одна, поэтому хеши разных чеков близки - одного хеша для повтора мало
+	t.Logf("отличие хешей разных чеков одного банка: %d", HammingDistance(firstPrint.PerceptualHash, secondPrint.PerceptualHash))
+
+	payments := []entity.Payment{{ID: 1, UserID: 7, Amount: 4800}, {ID: 2, UserID: 8, Amount: 1200}}
+	firstPrint.Apply(&payments[0])
+	if duplicates := FindDuplicates(secondPrint, payments[1], payments); len(duplicates) != 0 {
+		t.Errorf("похожие чеки на разные суммы не повторы: %+v", duplicates)
+	}
+
+	resent := TakeFingerprint(entity.MessageFromUserBot{RequisiteContent: recompress(t, first), IsImage: true})
+	duplicates := FindDuplicates(resent, entity.Payment{ID: 3, UserID: 9, Amount: 4800}, payments)
+	if len(duplicates) != 1 || duplicates[0].Reason != "похожий скриншот на ту же сумму" {
+		t.Errorf("пересланный другим пользователем скриншот должен находиться, получили %+v", duplicates)
+	}
+}
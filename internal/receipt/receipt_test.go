@@ -0,0 +1,183 @@
+package receipt
+
+import (
+	"bytes"
+	"github.com/go-pdf/fpdf"
+	"main/internal/entity"
+	"main/internal/qrcode"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sberReceipt = `ПАО Сбербанк
+Чек по операции
+18 октября 2026 14:05:11 (МСК)
+Операция Перевод организации
+Счёт получателя 40702810138250123017
+Сумма перевода 4 800,00 ₽
+Комиссия 0,00 ₽
+Назначение платежа: Оплата тарифа Месяц
+Номер документа 1000000000123456789`
+
+const tinkoffReceipt = `Квитанция
+18.10.2026 14:07:52
+Итого 4 800 ₽
+Комиссия без комиссии
+Получатель ООО Ромашка
+Счет получателя **3017
+Сообщение получателю Оплата тарифа Месяц
+Идентификатор операции в СБП A62911205458290K0000020011700501`
+
+func testRequisite(t *testing.T) entity.Requisite {
+	qr := qrcode.QRCode{Payment: qrcode.Payment{
+		Name:        "ООО Ромашка",
+		PersonalAcc: "40702810138250123017",
+		BankName:    "ПАО Сбербанк",
+		BIC:         "044525225",
+		CorrespAcc:  "30101810400000000225",
+		PayeeINN:    "7707083893",
+		Purpose:     "Оплата тарифа Месяц",
+	}}
+	requisite, err := qr.ToEntity("Ромашка")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return requisite
+}
+
+func testPayment() entity.Payment {
+	return entity.Payment{ID: 1, Amount: 4800, TimeStamp: time.Date(2026, 10, 18, 14, 0, 0, 0, Location)}
+}
+
+func TestParseSber(t *testing.T) {
+	extracted := Parse(sberReceipt)
+	if extracted.Amount != 4800 {
+		t.Errorf("сумма: ожидали 4800, получили %v", extracted.Amount)
+	}
+	if !extracted.Date.Equal(time.Date(2026, 10, 18, 14, 5, 11, 0, Location)) {
+		t.Errorf("неверная дата: %v", extracted.Date)
+	}
+	if extracted.Account != "40702810138250123017" {
+		t.Errorf("неверный счет: %s", extracted.Account)
+	}
+	if extracted.Purpose != "Оплата тарифа Месяц" {
+		t.Errorf("неверное назначение: %q", extracted.Purpose)
+	}
+	if extracted.TransactionID != "1000000000123456789" {
+		t.Errorf("неверный номер операции: %q", extracted.TransactionID)
+	}
+}
+
+func TestParseMasked(t *testing.T) {
+	extracted := Parse(strings.ReplaceAll(tinkoffReceipt, "4 800", "4\u00a0800"))
+	if extracted.Amount != 4800 || extracted.Account != "**3017" ||
+		extracted.TransactionID != "A62911205458290K0000020011700501" {
+		t.Errorf("неверный разбор: %+v", extracted)
+	}
+	if !extracted.Date.Equal(time.Date(2026, 10, 18, 14, 7, 52, 0, Location)) {
+		t.Errorf("неверная дата: %v", extracted.Date)
+	}
+}
+
+func TestParseAuthorizationCode(t *testing.T) {
+	// код авторизации короткий и повторяется у разных платежей, номером операции он не считается
+	extracted := Parse("Сумма 4 800,00 ₽\nКод авторизации: 123456")
+	if len(extracted.TransactionID) > 0 {
+		t.Errorf("код авторизации принят за номер операции: %q", extracted.TransactionID)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	requisite := testRequisite(t)
+	for _, text := range []string{sberReceipt, tinkoffReceipt} {
+		if result := Verify(Parse(text), testPayment(), requisite); result.Verdict != Match {
+			t.Errorf("ожидали совпадение, получили %s: %v %v", result.Verdict, result.Problems, result.Missing)
+		}
+	}
+
+	payment := testPayment()
+	payment.Amount = 5000
+	result := Verify(Parse(sberReceipt), payment, requisite)
+	if result.Verdict != Mismatch || len(result.Problems) != 1 {
+		t.Errorf("ожидали расхождение суммы, получили %s: %v", result.Verdict, result.Problems)
+	}
+
+	wrongAccount := strings.Replace(sberReceipt, "40702810138250123017", "40702810138250199999", 1)
+	if result := Verify(Parse(wrongAccount), testPayment(), requisite); result.Verdict != Mismatch {
+		t.Errorf("ожидали расхождение счета, получили %s", result.Verdict)
+	}
+
+	late := testPayment()
+	late.TimeStamp = late.TimeStamp.AddDate(0, 0, -5)
+	if result := Verify(Parse(sberReceipt), late, requisite); result.Verdict != Mismatch {
+		t.Errorf("ожидали расхождение даты, получили %s", result.Verdict)
+	}
+
+	if result := Verify(Parse("фото кота"), testPayment(), requisite); result.Verdict != Unreadable {
+		t.Errorf("ожидали нечитаемый чек, получили %s", result.Verdict)
+	}
+}
+
+func TestVerifyAny(t *testing.T) {
+	other := testRequisite(t)
+	other.Content = strings.Replace(other.Content, "40702810138250123017", "40702810138250199999", 1)
+	result := VerifyAny(Parse(sberReceipt), testPayment(), other, testRequisite(t))
+	if result.Verdict != Match {
+		t.Errorf("ожидали совпадение со вторым реквизитом, получили %s: %v", result.Verdict, result.Problems)
+	}
+}
+
+func TestAccountMatches(t *testing.T) {
+	account := "40702810138250123017"
+	cases := map[string]bool{
+		account:                true,
+		"**3017":               true,
+		"4070****3017":         true,
+		"*3018":                false,
+		"****":                 false,
+		"40702810138250123018": false,
+	}
+	for masked, expected := range cases {
+		if accountMatches(masked, account) != expected {
+			t.Errorf("accountMatches(%s) != %v", masked, expected)
+		}
+	}
+}
+
+func TestVerifyPdf(t *testing.T) {
+	document := fpdf.New("P", "mm", "A4", "")
+	document.AddPage()
+	document.SetFont("Helvetica", "", 10)
+	for _, line := range []string{"Receipt", "18.10.2026 14:05", "Amount 4800.00 RUB",
+		"Recipient account 40702810138250123017", "Purpose: Oplata tarifa"} {
+		document.CellFormat(180, 6, line, "", 1, "L", false, 0, "")
+	}
+	var buffer bytes.Buffer
+	if err := document.Output(&buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	message := entity.MessageFromUserBot{RequisiteContent: buffer.Bytes(), IsFile: true}
+	result := VerifyMessage(message, testPayment(), testRequisite(t))
+	if result.Verdict != Mismatch || len(result.Problems) != 1 || !strings.Contains(result.Problems[0], "назначение") {
+		t.Errorf("ожидали расхождение только назначения, получили %s: %v %v", result.Verdict, result.Problems, result.Missing)
+	}
+	if !strings.Contains(result.Card(), "Сумма: 4800.00 ₽") {
+		t.Errorf("в карточке нет суммы:\n%s", result.Card())
+	}
+
+	screenshot := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	for _, message := range []entity.MessageFromUserBot{
+		{RequisiteContent: screenshot, IsImage: true},
+		{RequisiteContent: screenshot, IsFile: true},
+	} {
+		result := VerifyMessage(message, testPayment())
+		if result.Verdict != NotChecked || len(result.Missing) != 0 {
+			t.Errorf("скриншот должен уходить на ручную проверку без разбора PDF, получили %s: %v", result.Verdict, result.Missing)
+		}
+		if !strings.Contains(result.Card(), "Скриншот не проверялся") {
+			t.Errorf("в карточке нет отметки о непроверенном скриншоте:\n%s", result.Card())
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package receipt
+
+import (
+	"main/internal/entity"
+	"main/internal/qrcode"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Verdict - итог автоматической проверки чека
+type Verdict string
+
+const (
+	Match      Verdict = "match"       // Чек совпадает с платежом и реквизитом
+	Mismatch   Verdict = "mismatch"    // Найдены расхождения
+	Unreadable Verdict = "unreadable"  // Не удалось прочитать ключевые данные, нужна ручная проверка
+	NotChecked Verdict = "not_checked" // Скриншот: текст с изображений не распознается, нужна ручная проверка
+)
+
+// Допустимое расхождение времени в чеке с моментом создания платежа
+const (
+	receiptEarliest = -time.Hour
+	receiptLatest   = 72 * time.Hour
+)
+
+// Result - вердикт проверки чека с найденными данными и расхождениями
+type Result struct {
+	Verdict   Verdict
+	Extracted Extracted
+	Problems  []string // Расхождения с платежом или реквизитом
+	Missing   []string // Данные, которые не удалось найти в чеке
+}
+
+// VerifyMessage - проверяет чек, присланный пользователем: PDF-файл или текст.
+// Текст со скриншотов не распознается: такие чеки получают вердикт NotChecked и уходят на ручную проверку
+func VerifyMessage(message entity.MessageFromUserBot, payment entity.Payment, requisites ...entity.Requisite) Result {
+	text := string(message.RequisiteContent)
+	if isImage(message) {
+		return Result{Verdict: NotChecked}
+	}
+	if message.IsFile {
+		var err error
+		text, err = ExtractText(message.RequisiteContent)
+		if err != nil {
+			return Result{Verdict: Unreadable, Missing: []string{"текст чека: " + err.Error()}}
+		}
+	}
+	return VerifyAny(Parse(text), payment, requisites...)
+}
+
+// isImage - фото или картинка, присланная файлом
+func isImage(message entity.MessageFromUserBot) bool {
+	if message.IsImage {
+		return true
+	}
+	return message.IsFile && strings.HasPrefix(http.DetectContentType(message.RequisiteContent), "image/")
+}
+
+// VerifyAny - проверяет чек по каждому из реквизитов, на которые мог уйти платеж.
+// Возвращает первое совпадение, иначе результат по первому реквизиту
+func VerifyAny(extracted Extracted, payment entity.Payment, requisites ...entity.Requisite) Result {
+	if len(requisites) == 0 {
+		return Verify(extracted, payment, entity.Requisite{})
+	}
+	var first Result
+	for i, requisite := range requisites {
+		result := Verify(extracted, payment, requisite)
+		if result.Verdict == Match {
+			return result
+		}
+		if i == 0 {
+			first = result
+		}
+	}
+	return first
+}
+
+// Verify - сравнивает данные чека с ожидаемым платежом и реквизитом получателя
+func Verify(extracted Extracted, payment entity.Payment, requisite entity.Requisite) Result {
+	result := Result{Extracted: extracted}
+	if extracted.Amount == 0 && len(extracted.Account) == 0 {
+		result.Verdict = Unreadable
+		result.Missing = []string{"сумма", "счет получателя"}
+		return result
+	}
+
+	if extracted.Amount == 0 {
+		result.Missing = append(result.Missing, "сумма")
+	} else if math.Abs(extracted.Amount-float64(payment.Amount)) >= 0.01 {
+		result.Problems = append(result.Problems, "сумма в чеке "+formatAmount(extracted.Amount)+
+			", ожидалось "+formatAmount(float64(payment.Amount)))
+	}
+
+	if extracted.Date.IsZero() {
+		result.Missing = append(result.Missing, "дата операции")
+	} else if !payment.TimeStamp.IsZero() {
+		shift := extracted.Date.Sub(payment.TimeStamp)
+		if shift < receiptEarliest || shift > receiptLatest {
+			result.Problems = append(result.Problems, "дата в чеке "+extracted.Date.Format("02.01.2006 15:04")+
+				" не соответствует платежу от "+payment.TimeStamp.In(Location).Format("02.01.2006 15:04"))
+		}
+	}
+
+	expected := expectedPayment(requisite)
+	if len(expected.PersonalAcc) > 0 {
+		if len(extracted.Account) == 0 {
+			result.Missing = append(result.Missing, "счет получателя")
+		} else if !accountMatches(extracted.Account, expected.PersonalAcc) {
+			result.Problems = append(result.Problems, "счет получателя "+extracted.Account+
+				" не совпадает с "+expected.PersonalAcc)
+		}
+	}
+	if len(expected.Purpose) > 0 && len(extracted.Purpose) > 0 &&
+		!strings.Contains(normalize(extracted.Purpose), normalize(expected.Purpose)) &&
+		!strings.Contains(normalize(expected.Purpose), normalize(extracted.Purpose)) {
+		result.Problems = append(result.Problems, "назначение платежа «"+extracted.Purpose+"» не совпадает с «"+expected.Purpose+"»")
+	}
+
+	switch {
+	case len(result.Problems) > 0:
+		result.Verdict = Mismatch
+	case extracted.Amount == 0 || (len(expected.PersonalAcc) > 0 && len(extracted.Account) == 0):
+		result.Verdict = Unreadable
+	default:
+		result.Verdict = Match
+	}
+	return result
+}
+
+// expectedPayment - реквизиты получателя из сохраненного реквизита.
+// Для ссылок СБП счет неизвестен и не сверяется
+func expectedPayment(requisite entity.Requisite) qrcode.Payment {
+	if len(requisite.Content) == 0 && len(requisite.Link) == 0 {
+		return qrcode.Payment{}
+	}
+	qr := qrcode.QRCode{}
+	if err := qr.FromEntity(&requisite); err != nil || qr.IsSBP() {
+		return qrcode.Payment{}
+	}
+	return qr.Payment
+}
+
+// accountMatches - сравнивает счет из чека, возможно частично скрытый звездочками, с полным счетом
+func accountMatches(masked, account string) bool {
+	first := strings.Index(masked, "*")
+	if first < 0 {
+		return masked == account
+	}
+	last := strings.LastIndex(masked, "*")
+	prefix, suffix := masked[:first], masked[last+1:]
+	if len(prefix)+len(suffix) == 0 {
+		return false
+	}
+	return strings.HasPrefix(account, prefix) && strings.HasSuffix(account, suffix)
+}
+
+// Card - текст вердикта для карточки модерации
+func (r Result) Card() string {
+	var card strings.Builder
+	switch r.Verdict {
+	case Match:
+		card.WriteString("✅ Чек совпадает с платежом\n")
+	case Mismatch:
+		card.WriteString("❌ Чек не совпадает с платежом\n")
+	case NotChecked:
+		card.WriteString("🖼 Скриншот не проверялся автоматически, нужна ручная проверка\n")
+	default:
+		card.WriteString("❔ Чек не распознан, нужна ручная проверка\n")
+	}
+	if r.Extracted.Amount > 0 {
+		card.WriteString("Сумма: " + formatAmount(r.Extracted.Amount) + "\n")
+	}
+	if !r.Extracted.Date.IsZero() {
+		card.WriteString("Дата: " + r.Extracted.Date.Format("02.01.2006 15:04") + "\n")
+	}
+	if len(r.Extracted.Account) > 0 {
+		card.WriteString("Счет получателя: " + r.Extracted.Account + "\n")
+	}
+	if len(r.Extracted.Purpose) > 0 {
+		card.WriteString("Назначение: " + r.Extracted.Purpose + "\n")
+	}
+	for _, problem := range r.Problems {
+		card.WriteString("• " + problem + "\n")
+	}
+	if len(r.Missing) > 0 {
+		card.WriteString("Не найдено: " + strings.Join(r.Missing, ", ") + "\n")
+	}
+	return card.String()
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64) + " ₽"
+}
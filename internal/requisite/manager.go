@@ -0,0 +1,220 @@
+package requisite
+
+import (
+	"errors"
+	"main/internal/database/entitybase"
+	"main/internal/entity"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrNoActive = errors.New("нет активного реквизита для оплаты")
+
+// Manager - хранение реквизитов для оплаты: один активный реквизит,
+// поочередная выдача нескольких счетов и плановое переключение
+type Manager struct {
+	base  entitybase.EntityBase[entity.Requisite]
+	mutex sync.Mutex
+}
+
+func InitManager(base entitybase.EntityBase[entity.Requisite]) *Manager {
+	return &Manager{base: base}
+}
+
+// Add - сохраняет новый реквизит. Первый сохраненный реквизит становится активным
+func (m *Manager) Add(requisite entity.Requisite) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	all, err := m.base.GetAll()
+	if err != nil {
+		return err
+	}
+	requisite.Active = len(all) == 0
+	return m.base.Add(requisite)
+}
+
+// All - все реквизиты по возрастанию ID
+func (m *Manager) All() ([]entity.Requisite, error) {
+	all, err := m.base.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+	return all, nil
+}
+
+// Get - реквизит по ID
+func (m *Manager) Get(id int) (entity.Requisite, error) {
+	requisite, err := m.base.Get(entity.Requisite{ID: id})
+	if errors.Is(err, entitybase.ErrNotFound) {
+		return requisite, errors.New("реквизит не найден")
+	}
+	return requisite, err
+}
+
+// Activate - делает реквизит активным, остальные перестают быть активными
+func (m *Manager) Activate(id int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.activate(id)
+}
+
+func (m *Manager) activate(id int) error {
+	if _, err := m.Get(id); err != nil {
+		return err
+	}
+	all, err := m.base.GetAll()
+	if err != nil {
+		return err
+	}
+	for _, requisite := range all {
+		active := requisite.ID == id
+		if requisite.Active == active && (!active || requisite.ActivateAt.IsZero()) {
+			continue
+		}
+		requisite.Active = active
+		if active {
+			requisite.ActivateAt = time.Time{}
+		}
+		if err := m.base.Update(requisite); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetRotation - включает или исключает реквизит из поочередной выдачи
+func (m *Manager) SetRotation(id int, inRotation bool) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	requisite, err := m.Get(id)
+	if err != nil {
+		return err
+	}
+	requisite.InRotation = inRotation
+	return m.base.Update(requisite)
+}
+
+// Schedule - плановое переключение на реквизит в момент at
+func (m *Manager) Schedule(id int, at time.Time) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	requisite, err := m.Get(id)
+	if err != nil {
+		return err
+	}
+	requisite.ActivateAt = at
+	return m.base.Update(requisite)
+}
+
+// Delete - удаляет реквизит. Активный реквизит удалить нельзя, сначала нужно переключиться
+func (m *Manager) Delete(id int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	requisite, err := m.Get(id)
+	if err != nil {
+		return err
+	}
+	if requisite.Active {
+		return errors.New("нельзя удалить активный реквизит")
+	}
+	return m.base.Delete(requisite)
+}
+
+// ApplySchedule - выполняет наступившие плановые переключения.
+// Возвращает реквизит, ставший активным, либо nil
+func (m *Manager) ApplySchedule(now time.Time) (*entity.Requisite, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.applySchedule(now)
+}
+
+func (m *Manager) applySchedule(now time.Time) (*entity.Requisite, error) {
+	all, err := m.base.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	var due *entity.Requisite
+	var overdue []entity.Requisite
+	for i, requisite := range all {
+		if requisite.ActivateAt.IsZero() || requisite.ActivateAt.After(now) {
+			continue
+		}
+		if due == nil || requisite.ActivateAt.After(due.ActivateAt) {
+			if due != nil {
+				overdue = append(overdue, *due)
+			}
+			due = &all[i]
+		} else {
+			overdue = append(overdue, requisite)
+		}
+	}
+	if due == nil {
+		return nil, nil
+	}
+	// Более ранние наступившие переключения перекрыты последним и больше не выполняются
+	for _, requisite := range overdue {
+		requisite.ActivateAt = time.Time{}
+		if err := m.base.Update(requisite); err != nil {
+			return nil, err
+		}
+	}
+	if err := m.activate(due.ID); err != nil {
+		return nil, err
+	}
+	due.Active = true
+	due.ActivateAt = time.Time{}
+	return due, nil
+}
+
+// Next - реквизит для очередного платежа. Если есть реквизиты в ротации,
+// выдается наименее использованный из них, иначе активный
+func (m *Manager) Next(now time.Time) (entity.Requisite, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, err := m.applySchedule(now); err != nil {
+		return entity.Requisite{}, err
+	}
+	all, err := m.base.GetAll()
+	if err != nil {
+		return entity.Requisite{}, err
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+	var picked *entity.Requisite
+	for i, requisite := range all {
+		if requisite.InRotation && (picked == nil || requisite.UsedCount < picked.UsedCount) {
+			picked = &all[i]
+		}
+	}
+	if picked == nil {
+		for i, requisite := range all {
+			if requisite.Active {
+				picked = &all[i]
+				break
+			}
+		}
+	}
+	if picked == nil {
+		return entity.Requisite{}, ErrNoActive
+	}
+	picked.UsedCount++
+	return *picked, m.base.Update(*picked)
+}
+
+// Candidates - реквизиты, на которые мог прийти платеж: активный и участвующие в ротации
+func (m *Manager) Candidates() ([]entity.Requisite, error) {
+	all, err := m.All()
+	if err != nil {
+		return nil, err
+	}
+	var result []entity.Requisite
+	for _, requisite := range all {
+		if requisite.Active {
+			result = append([]entity.Requisite{requisite}, result...)
+		} else if requisite.InRotation {
+			result = append(result, requisite)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,121 @@
+package requisite
+
+import (
+	"errors"
+	"main/internal/database/entitybase/memoryentitybase"
+	"main/internal/entity"
+	"testing"
+	"time"
+)
+
+func newManager(t *testing.T, names ...string) *Manager {
+	manager := InitManager(memoryentitybase.InitMemoryEntityBase[entity.Requisite]())
+	for _, name := range names {
+		if err := manager.Add(entity.Requisite{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return manager
+}
+
+func TestAddFirstIsActive(t *testing.T) {
+	manager := newManager(t, "Первый", "Второй")
+	all, _ := manager.All()
+	if !all[0].Active || all[1].Active {
+		t.Errorf("активным должен стать только первый реквизит: %+v", all)
+	}
+}
+
+func TestActivate(t *testing.T) {
+	manager := newManager(t, "Первый", "Второй")
+	if err := manager.Activate(2); err != nil {
+		t.Fatal(err)
+	}
+	all, _ := manager.All()
+	if all[0].Active || !all[1].Active {
+		t.Errorf("активным должен быть второй реквизит: %+v", all)
+	}
+	if err := manager.Activate(5); err == nil {
+		t.Errorf("активация несуществующего реквизита должна вернуть ошибку")
+	}
+	if err := manager.Delete(2); err == nil {
+		t.Errorf("активный реквизит нельзя удалить")
+	}
+}
+
+func TestNextActive(t *testing.T) {
+	manager := InitManager(memoryentitybase.InitMemoryEntityBase[entity.Requisite]())
+	if _, err := manager.Next(time.Now()); !errors.Is(err, ErrNoActive) {
+		t.Errorf("без реквизитов ожидается ErrNoActive, получено %v", err)
+	}
+	manager = newManager(t, "Первый", "Второй")
+	requisite, err := manager.Next(time.Now())
+	if err != nil || requisite.ID != 1 {
+		t.Errorf("ожидался активный реквизит #1, получено %+v, %v", requisite, err)
+	}
+}
+
+func TestNextRotation(t *testing.T) {
+	manager := newManager(t, "Первый", "Второй", "Третий")
+	_ = manager.SetRotation(2, true)
+	_ = manager.SetRotation(3, true)
+	var ids []int
+	for i := 0; i < 4; i++ {
+		requisite, err := manager.Next(time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, requisite.ID)
+	}
+	expected := []int{2, 3, 2, 3}
+	for i := range expected {
+		if ids[i] != expected[i] {
+			t.Errorf("порядок выдачи %v, ожидался %v", ids, expected)
+			break
+		}
+	}
+}
+
+func TestApplySchedule(t *testing.T) {
+	manager := newManager(t, "Первый", "Второй", "Третий")
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	_ = manager.Schedule(2, now.Add(-time.Hour))
+	_ = manager.Schedule(3, now.Add(time.Hour))
+
+	switched, err := manager.ApplySchedule(now)
+	if err != nil || switched == nil || switched.ID != 2 {
+		t.Fatalf("ожидалось переключение на #2, получено %+v, %v", switched, err)
+	}
+	switched, _ = manager.ApplySchedule(now)
+	if switched != nil {
+		t.Errorf("повторное переключение не ожидалось: %+v", switched)
+	}
+	requisite, _ := manager.Next(now.Add(2 * time.Hour))
+	if requisite.ID != 3 {
+		t.Errorf("после наступления расписания ожидался #3, получен #%d", requisite.ID)
+	}
+	all, _ := manager.All()
+	if !all[2].ActivateAt.IsZero() || all[0].Active || all[1].Active {
+		t.Errorf("неверное состояние после переключения: %+v", all)
+	}
+}
+
+func TestApplyOverlappingSchedules(t *testing.T) {
+	manager := newManager(t, "Первый", "Второй", "Третий")
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	_ = manager.Schedule(2, now.Add(-2*time.Hour))
+	_ = manager.Schedule(3, now.Add(-time.Hour))
+
+	switched, err := manager.ApplySchedule(now)
+	if err != nil || switched == nil || switched.ID != 3 {
+		t.Fatalf("ожидалось переключение на #3, получено %+v, %v", switched, err)
+	}
+	switched, err = manager.ApplySchedule(now.Add(time.Minute))
+	if err != nil || switched != nil {
+		t.Errorf("на следующем тике переключение не ожидалось: %+v, %v", switched, err)
+	}
+	all, _ := manager.All()
+	if !all[2].Active || all[1].Active || !all[1].ActivateAt.IsZero() {
+		t.Errorf("неверное состояние после двух тиков: %+v", all)
+	}
+}
@@ -1,5 +1,9 @@
 package service
 
+import "context"
+
 type Service interface {
 	Work()
+	// Run - работа до отмены ctx с дообработкой начатого, см. lifecycle.Runner
+	Run(ctx context.Context) error
 }
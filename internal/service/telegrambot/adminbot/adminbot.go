@@ -1,13 +1,111 @@
 package adminbot
 
 import (
+	"context"
+	"main/config"
+	"main/internal/access"
+	"main/internal/broadcast"
+	"main/internal/database/entitybase"
+	"main/internal/database/lock"
 	"main/internal/database/queue"
 	"main/internal/entity"
+	"main/internal/jobs"
+	"main/internal/reachability"
+	"main/internal/requisite"
 	"main/internal/service/telegrambot"
+	"main/internal/telegram"
+	"time"
 )
 
 type AdminBot struct {
 	queueFromAdmin queue.Queue[entity.MessageFromAdminBot]
 	queueFromUser  queue.Queue[entity.MessageFromUserBot]
+	requisites     *requisite.Manager
+	payments       entitybase.EntityBase[entity.Payment]
+	roles          *access.Roles
+	broadcaster    *broadcast.Broadcaster
+	scheduler      *broadcast.Scheduler
 	telegrambot.TelegramBot
 }
+
+func InitAdminBot(
+	token string,
+	conf config.Config,
+	locker lock.Locker,
+	queueFromAdmin queue.Queue[entity.MessageFromAdminBot],
+	queueFromUser queue.Queue[entity.MessageFromUserBot],
+	requisites *requisite.Manager,
+	payments entitybase.EntityBase[entity.Payment],
+	users entitybase.EntityBase[entity.User],
+	promoCodes entitybase.EntityBase[entity.PromoCode],
+	roles *access.Roles,
+	broadcaster *broadcast.Broadcaster,
+	scheduler *broadcast.Scheduler,
+	tracker *reachability.Tracker) (*AdminBot, error) {
+	bot, err := telegrambot.InitBot(token, conf, locker)
+	if err != nil {
+		return nil, err
+	}
+	adminBot := &AdminBot{
+		queueFromAdmin: queueFromAdmin,
+		queueFromUser:  queueFromUser,
+		requisites:     requisites,
+		payments:       payments,
+		roles:          roles,
+		broadcaster:    broadcaster,
+		scheduler:      scheduler,
+		TelegramBot:    *bot,
+	}
+	adminBot.UseRoles(roles)
+	// админ-бот только для сотрудников: общие команды доступны любой роли
+	for i, command := range adminBot.TelegramCommands {
+		if len(command.Role) == 0 {
+			adminBot.TelegramCommands[i] = command.Require(entity.RoleAnalyst)
+		}
+	}
+	// диалог рассылки принимает фото раньше загрузки реквизитов
+	sessions := telegrambot.InitStorage[telegram.Session](&adminBot.TelegramBot, conf, "sessions")
+	dialog := MakeBroadcastDialog(adminBot.Callbacks, sessions, broadcaster, scheduler)
+	for _, command := range dialog.Commands() {
+		adminBot.TelegramCommands = adminBot.TelegramCommands.AddCommand(command.Require(entity.RoleAdmin))
+	}
+	drafts := &requisiteDrafts{drafts: make(map[int64]*requisiteDraft), requisites: requisites, buttons: adminBot.Buttons}
+	adminBot.TelegramCommands = adminBot.TelegramCommands.
+		AddCommand(MakeRequisiteFieldInput(drafts).Require(entity.RoleAdmin)).
+		AddCommand(MakeRequisiteUpload(drafts).Require(entity.RoleAdmin)).
+		AddCommand(MakeRequisiteList(adminBot.Buttons, requisites).Require(entity.RoleAdmin)).
+		AddCommand(MakeRequisiteSchedule(requisites).Require(entity.RoleAdmin)).
+		AddCommand(MakePaymentList(adminBot.Callbacks, payments).Require(entity.RoleAnalyst)).
+		AddCommand(MakeUserList(adminBot.Callbacks, users, payments, roles).Require(entity.RoleAnalyst)).
+		AddCommand(MakePromoCodeList(adminBot.Callbacks, promoCodes, roles).Require(entity.RoleAnalyst)).
+		AddCommand(MakeScheduledList(adminBot.Callbacks, scheduler).Require(entity.RoleAdmin)).
+		AddCommand(MakeReachabilityReport(tracker).Require(entity.RoleAnalyst)).
+		AddCommand(MakeJobList(adminBot.Callbacks, adminBot.currentJobs).Require(entity.RoleAdmin)).
+		AddCommand(MakeGrantRole(roles)).
+		AddCommand(MakeRevokeRole(roles)).
+		AddCommand(MakeStaffList(roles))
+	RegisterRequisiteButtons(adminBot.Buttons, requisites, roles)
+	RegisterDraftButtons(adminBot.Buttons, drafts, roles)
+	RegisterBroadcastControls(adminBot.Callbacks, broadcaster, roles)
+	RegisterScheduleControls(adminBot.Callbacks, dialog, scheduler, roles)
+	RegisterJobControls(adminBot.Callbacks, adminBot.currentJobs, roles)
+	broadcaster.Progress = adminBot.broadcastProgress
+	scheduler.Announce = adminBot.announceSchedule
+	scheduler.Failed = adminBot.reportScheduleFailure
+	// рассылки отправляет только ведущая реплика, остальные их только сохраняют
+	adminBot.Lead(broadcaster.Run)
+	for _, job := range []jobs.Job{
+		{Name: "requisite-schedule", Schedule: jobs.Every(time.Minute), Exclusive: true,
+			Run: applyRequisiteSchedule(requisites)},
+		{Name: "broadcast-schedule", Schedule: jobs.Every(time.Minute), Exclusive: true,
+			Run: func(ctx context.Context) error {
+				scheduler.Tick(time.Now())
+				return nil
+			}},
+	} {
+		if err := adminBot.Jobs.Add(job); err != nil {
+			return nil, err
+		}
+	}
+	return adminBot, nil
+}
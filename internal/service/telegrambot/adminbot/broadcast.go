@@ -0,0 +1,405 @@
+package adminbot
+
+import (
+	"errors"
+	"fmt"
+	"github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log/slog"
+	"main/internal/access"
+	"main/internal/broadcast"
+	"main/internal/database/keyvalue"
+	"main/internal/entity"
+	"main/internal/entity/mapper"
+	"main/internal/telegram"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Шаги диалога /broadcast
+const (
+	stateBroadcastSegment  telegram.State = "segment"
+	stateBroadcastParam    telegram.State = "param"
+	stateBroadcastContent  telegram.State = "content"
+	stateBroadcastConfirm  telegram.State = "confirm"
+	stateBroadcastSchedule telegram.State = "schedule"
+	stateScheduleTime      telegram.State = "schedule_time"
+	stateScheduleText      telegram.State = "schedule_text"
+)
+
+// broadcastDialogTimeout - сколько ждать следующего шага диалога рассылки
+const broadcastDialogTimeout = 30 * time.Minute
+
+// Маршруты кнопок управления рассылкой
+const (
+	routeBroadcastPause  = "broadcast_pause"
+	routeBroadcastResume = "broadcast_resume"
+	routeBroadcastCancel = "broadcast_cancel"
+)
+
+// broadcastSegments - сегменты в порядке кнопок выбора
+var broadcastSegments = []entity.Segment{
+	entity.SegmentAll, entity.SegmentActive, entity.SegmentExpired,
+	entity.SegmentTariff, entity.SegmentNeverPaid, entity.SegmentLegacy,
+}
+
+type broadcastParams struct {
+	ID int
+}
+
+// MakeBroadcastDialog - /broadcast: выбор сегмента, сообщение, предпросмотр себе,
+// подтверждение и запуск рассылки сразу или по расписанию. Тот же диалог меняет
+// время и текст запланированных рассылок
+func MakeBroadcastDialog(
+	callbacks *telegram.CallbackRouter,
+	sessions keyvalue.KeyValue[telegram.Session],
+	broadcaster *broadcast.Broadcaster,
+	scheduler *broadcast.Scheduler) *telegram.StateMachine {
+	dialog := telegram.NewStateMachine("broadcast", sessions, broadcastDialogTimeout).UseCallbacks(callbacks)
+	dialog.CancelText = "Рассылка отменена"
+	dialog.Entry("broadcast", "Рассылка пользователям", stateBroadcastSegment).
+		OnEnter(stateBroadcastSegment, func(u *telemux.Update, session *telegram.Session) {
+			var rows [][]tgbotapi.InlineKeyboardButton
+			for i, segment := range broadcastSegments {
+				button, err := dialog.Button(broadcast.SegmentTitle(segment, 0), string(segment))
+				if err != nil {
+					slog.Error("broadcast segment button", "error", err)
+					sendText(u, session.ChatID, "Не удалось показать сегменты: "+err.Error())
+					return
+				}
+				if i%2 == 0 {
+					rows = append(rows, nil)
+				}
+				rows[len(rows)-1] = append(rows[len(rows)-1], button)
+			}
+			msg := tgbotapi.NewMessage(session.ChatID, "Кому отправить рассылку?")
+			msg.ReplyMarkup = telegram.SignKeyboard(u, tgbotapi.NewInlineKeyboardMarkup(rows...), session.ChatID, telegram.GetUserFromId(u))
+			_, _ = u.Bot.Send(msg)
+		}).
+		On(stateBroadcastSegment, func(u *telemux.Update, session *telegram.Session, input string) telegram.State {
+			segment := entity.Segment(input)
+			for _, known := range broadcastSegments {
+				if segment != known {
+					continue
+				}
+				session.Data["segment"] = input
+				if segment == entity.SegmentExpired || segment == entity.SegmentTariff {
+					return stateBroadcastParam
+				}
+				return stateBroadcastContent
+			}
+			sendText(u, session.ChatID, "Выберите сегмент кнопкой выше")
+			return stateBroadcastSegment
+		}).
+		OnEnter(stateBroadcastParam, func(u *telemux.Update, session *telegram.Session) {
+			if entity.Segment(session.Data["segment"]) == entity.SegmentExpired {
+				sendText(u, session.ChatID, "За сколько последних дней закончилась подписка?")
+				return
+			}
+			sendText(u, session.ChatID, "Введите ID тарифа")
+		}).
+		On(stateBroadcastParam, func(u *telemux.Update, session *telegram.Session, input string) telegram.State {
+			param, err := strconv.Atoi(strings.TrimSpace(input))
+			if err != nil || param <= 0 {
+				sendText(u, session.ChatID, "Нужно целое число больше нуля")
+				return stateBroadcastParam
+			}
+			session.Data["param"] = strconv.Itoa(param)
+			return stateBroadcastContent
+		}).
+		OnEnter(stateBroadcastContent, func(u *telemux.Update, session *telegram.Session) {
+			sendText(u, session.ChatID, "Пришлите сообщение для рассылки: текст или один файл с подписью")
+		}).
+		On(stateBroadcastContent, func(u *telemux.Update, session *telegram.Session, input string) telegram.State {
+			if u.Message == nil {
+				return stateBroadcastContent
+			}
+			if err := readBroadcastContent(u, session, scheduler.FilesDir()); err != nil {
+				sendText(u, session.ChatID, err.Error())
+				return stateBroadcastContent
+			}
+			return stateBroadcastConfirm
+		}).
+		OnEnter(stateBroadcastConfirm, func(u *telemux.Update, session *telegram.Session) {
+			sendBroadcastPreview(u, session, dialog, broadcaster)
+		}).
+		On(stateBroadcastConfirm, func(u *telemux.Update, session *telegram.Session, input string) telegram.State {
+			switch input {
+			case "confirm":
+				startBroadcast(u, session, broadcaster)
+				return telegram.StateNone
+			case "schedule":
+				return stateBroadcastSchedule
+			case "edit":
+				removeBroadcastFile(session.Data["file"])
+				delete(session.Data, "file")
+				delete(session.Data, "file_id")
+				return stateBroadcastContent
+			case "cancel":
+				removeBroadcastFile(session.Data["file"])
+				sendText(u, session.ChatID, "Рассылка отменена")
+				return telegram.StateNone
+			}
+			sendText(u, session.ChatID, "Подтвердите рассылку кнопкой выше или отправьте /cancel")
+			return stateBroadcastConfirm
+		}).
+		OnEnter(stateBroadcastSchedule, func(u *telemux.Update, session *telegram.Session) {
+			sendScheduleUsage(u, session.ChatID, scheduler)
+		}).
+		On(stateBroadcastSchedule, func(u *telemux.Update, session *telegram.Session, input string) telegram.State {
+			rule, err := broadcast.ParseRule(input, scheduler.Location(), time.Now())
+			if err != nil {
+				sendText(u, session.ChatID, err.Error())
+				return stateBroadcastSchedule
+			}
+			if err := scheduleBroadcast(u, session, scheduler, rule); err != nil {
+				sendText(u, session.ChatID, err.Error())
+				return stateBroadcastSchedule
+			}
+			return telegram.StateNone
+		}).
+		OnEnter(stateScheduleTime, func(u *telemux.Update, session *telegram.Session) {
+			sendScheduleUsage(u, session.ChatID, scheduler)
+		}).
+		On(stateScheduleTime, func(u *telemux.Update, session *telegram.Session, input string) telegram.State {
+			rule, err := broadcast.ParseRule(input, scheduler.Location(), time.Now())
+			if err != nil {
+				sendText(u, session.ChatID, err.Error())
+				return stateScheduleTime
+			}
+			id, _ := strconv.Atoi(session.Data["schedule"])
+			schedule, err := scheduler.Reschedule(id, rule)
+			if errors.Is(err, broadcast.ErrScheduleInPast) {
+				sendText(u, session.ChatID, err.Error())
+				return stateScheduleTime
+			}
+			if err != nil {
+				sendText(u, session.ChatID, err.Error())
+				return telegram.StateNone
+			}
+			sendText(u, session.ChatID, "Время изменено.\n"+scheduleText(schedule))
+			return telegram.StateNone
+		}).
+		OnEnter(stateScheduleText, func(u *telemux.Update, session *telegram.Session) {
+			sendText(u, session.ChatID, "Пришлите новый текст рассылки")
+		}).
+		On(stateScheduleText, func(u *telemux.Update, session *telegram.Session, input string) telegram.State {
+			if u.Message == nil || len(strings.TrimSpace(u.Message.Text)) == 0 {
+				return stateScheduleText
+			}
+			id, _ := strconv.Atoi(session.Data["schedule"])
+			schedule, err := scheduler.SetText(id, u.Message.Text)
+			if err != nil {
+				sendText(u, session.ChatID, err.Error())
+				return telegram.StateNone
+			}
+			sendText(u, session.ChatID, "Текст изменен.\n"+scheduleText(schedule))
+			return telegram.StateNone
+		})
+	return dialog
+}
+
+// readBroadcastContent - текст и файл рассылки из сообщения администратора.
+// Файл скачивается в dir: FileID админ-бота не действует в боте, который делает рассылку
+func readBroadcastContent(u *telemux.Update, session *telegram.Session, dir string) error {
+	message := u.Message
+	fileID, fileType, name := broadcastAttachment(message)
+	text := message.Text
+	if len(fileID) > 0 {
+		text = message.Caption
+	}
+	if len(fileID) == 0 && len(strings.TrimSpace(text)) == 0 {
+		return errors.New("Пришлите текст или файл: фото, видео, документ, аудио, голосовое или GIF")
+	}
+	session.Data["text"] = text
+	if len(fileID) == 0 {
+		return nil
+	}
+	data, err := telegram.DownloadFile(u, fileID)
+	if err != nil {
+		return errors.New("Не удалось скачать файл: " + err.Error())
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(dir, "broadcast-*"+filepath.Ext(name))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+	session.Data["file"] = file.Name()
+	session.Data["file_id"] = fileID
+	session.Data["type"] = strconv.Itoa(int(fileType))
+	return nil
+}
+
+// broadcastAttachment - файл из сообщения: FileID, тип и имя для расширения временного файла
+func broadcastAttachment(message *tgbotapi.Message) (string, entity.TypeFile, string) {
+	switch {
+	case len(message.Photo) > 0:
+		return message.Photo[len(message.Photo)-1].FileID, entity.Photo, "photo.jpg"
+	case message.Animation != nil:
+		return message.Animation.FileID, entity.Animation, message.Animation.FileName
+	case message.Video != nil:
+		return message.Video.FileID, entity.Video, message.Video.FileName
+	case message.Audio != nil:
+		return message.Audio.FileID, entity.Audio, message.Audio.FileName
+	case message.Voice != nil:
+		return message.Voice.FileID, entity.Voice, "voice.ogg"
+	case message.Document != nil:
+		return message.Document.FileID, entity.Doc, message.Document.FileName
+	}
+	return "", 0, ""
+}
+
+// broadcastMessage - сообщение рассылки из данных диалога; для предпросмотра файл берется по FileID админ-бота
+func broadcastMessage(session *telegram.Session, preview bool) entity.MessageFromAdminBot {
+	message := entity.MessageFromAdminBot{Text: session.Data["text"]}
+	if path, ok := session.Data["file"]; ok {
+		fileType, _ := strconv.Atoi(session.Data["type"])
+		file := entity.File{Filename: path, Type: entity.TypeFile(fileType)}
+		if preview {
+			file.FileID = session.Data["file_id"]
+		}
+		message.Files = []entity.File{file}
+	}
+	return message
+}
+
+func broadcastSegment(session *telegram.Session) (entity.Segment, int) {
+	param, _ := strconv.Atoi(session.Data["param"])
+	return entity.Segment(session.Data["segment"]), param
+}
+
+// sendBroadcastPreview - сообщение так, как его увидят получатели, и число получателей
+func sendBroadcastPreview(u *telemux.Update, session *telegram.Session, dialog *telegram.StateMachine, broadcaster *broadcast.Broadcaster) {
+	preview := broadcastMessage(session, true)
+	preview.TelegramID = session.ChatID
+	for _, part := range mapper.SentMessageToSend(preview) {
+		if _, err := u.Bot.Send(part); err != nil {
+			sendText(u, session.ChatID, "Не удалось показать предпросмотр: "+err.Error())
+		}
+	}
+	segment, param := broadcastSegment(session)
+	recipients, err := broadcaster.Recipients(segment, param)
+	if err != nil {
+		sendText(u, session.ChatID, err.Error())
+		return
+	}
+	msg := tgbotapi.NewMessage(session.ChatID, fmt.Sprintf("Так сообщение увидят получатели.\nАудитория: %s\nПолучателей: %d",
+		broadcast.SegmentTitle(segment, param), len(recipients)))
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, row := range [][]struct{ text, value string }{
+		{{"✅ Отправить сейчас", "confirm"}, {"🕒 Запланировать", "schedule"}},
+		{{"✏️ Изменить", "edit"}, {"✖️ Отмена", "cancel"}},
+	} {
+		var buttons []tgbotapi.InlineKeyboardButton
+		for _, item := range row {
+			button, err := dialog.Button(item.text, item.value)
+			if err != nil {
+				slog.Error("broadcast confirm button", "error", err)
+				sendText(u, session.ChatID, "Не удалось показать кнопки подтверждения: "+err.Error())
+				return
+			}
+			buttons = append(buttons, button)
+		}
+		rows = append(rows, buttons)
+	}
+	msg.ReplyMarkup = telegram.SignKeyboard(u, tgbotapi.NewInlineKeyboardMarkup(rows...), session.ChatID, telegram.GetUserFromId(u))
+	_, _ = u.Bot.Send(msg)
+}
+
+// startBroadcast - сообщение с ходом рассылки и запуск; дальше сообщение обновляет progress
+func startBroadcast(u *telemux.Update, session *telegram.Session, broadcaster *broadcast.Broadcaster) {
+	progress, err := u.Bot.Send(tgbotapi.NewMessage(session.ChatID, "Рассылка запускается…"))
+	if err != nil {
+		slog.Error("broadcast progress message", "error", err)
+	}
+	segment, param := broadcastSegment(session)
+	_, err = broadcaster.Start(entity.Broadcast{
+		Message:           broadcastMessage(session, false),
+		Segment:           segment,
+		SegmentParam:      param,
+		CreatedBy:         telegram.GetUserFromId(u),
+		AdminChatID:       session.ChatID,
+		ProgressMessageID: progress.MessageID,
+	})
+	if err != nil {
+		removeBroadcastFile(session.Data["file"])
+		sendText(u, session.ChatID, "Рассылка не запущена: "+err.Error())
+	}
+}
+
+// RegisterBroadcastControls - кнопки паузы, продолжения и отмены рассылки
+func RegisterBroadcastControls(callbacks *telegram.CallbackRouter, broadcaster *broadcast.Broadcaster, roles *access.Roles) {
+	control := func(change func(id int) error, done string) func(u *telemux.Update, params broadcastParams) {
+		return func(u *telemux.Update, params broadcastParams) {
+			if !roles.Check(u, entity.RoleAdmin) {
+				return
+			}
+			text := done
+			if err := change(params.ID); err != nil {
+				text = err.Error()
+			}
+			_, _ = u.Bot.Request(tgbotapi.NewCallback(u.CallbackQuery.ID, text))
+		}
+	}
+	telegram.Route(callbacks, routeBroadcastPause, control(broadcaster.Pause, "Рассылка приостановлена"))
+	telegram.Route(callbacks, routeBroadcastResume, control(broadcaster.Resume, "Рассылка продолжается"))
+	telegram.Route(callbacks, routeBroadcastCancel, control(broadcaster.Cancel, "Рассылка отменяется"))
+}
+
+// broadcastProgress - обновляет сообщение с ходом рассылки в чате администратора
+func (adminBot *AdminBot) broadcastProgress(item entity.Broadcast) {
+	if item.AdminChatID == 0 || item.ProgressMessageID == 0 {
+		return
+	}
+	edit := tgbotapi.NewEditMessageText(item.AdminChatID, item.ProgressMessageID, broadcast.ProgressText(item))
+	var buttons []tgbotapi.InlineKeyboardButton
+	switch item.Status {
+	case entity.BroadcastRunning:
+		buttons = adminBot.broadcastButtons(item.ID, "⏸ Пауза", routeBroadcastPause)
+	case entity.BroadcastPaused:
+		buttons = adminBot.broadcastButtons(item.ID, "▶️ Продолжить", routeBroadcastResume)
+	default:
+		for _, file := range item.Message.Files {
+			removeBroadcastFile(file.Filename)
+		}
+	}
+	if len(buttons) > 0 {
+		markup := adminBot.Callbacks.SignKeyboard(tgbotapi.NewInlineKeyboardMarkup(buttons), item.AdminChatID, item.CreatedBy)
+		edit.ReplyMarkup = &markup
+	}
+	if _, err := adminBot.Send(edit); err != nil && !strings.Contains(err.Error(), "message is not modified") {
+		slog.Warn("broadcast progress", "broadcast", item.ID, "error", err)
+	}
+}
+
+func (adminBot *AdminBot) broadcastButtons(id int, text, route string) []tgbotapi.InlineKeyboardButton {
+	var buttons []tgbotapi.InlineKeyboardButton
+	for _, button := range []struct{ text, route string }{{text, route}, {"⛔ Отменить", routeBroadcastCancel}} {
+		created, err := telegram.Callback(adminBot.Callbacks, button.text, button.route, broadcastParams{ID: id})
+		if err != nil {
+			slog.Error("broadcast button", "error", err)
+			continue
+		}
+		buttons = append(buttons, created)
+	}
+	return buttons
+}
+
+// removeBroadcastFile - удаляет временный файл рассылки
+func removeBroadcastFile(path string) {
+	if len(path) == 0 || !strings.HasPrefix(filepath.Base(path), "broadcast-") {
+		return
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("broadcast file", "path", path, "error", err)
+	}
+}
@@ -0,0 +1,143 @@
+package adminbot
+
+import (
+	"fmt"
+	"github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log/slog"
+	"main/internal/access"
+	"main/internal/entity"
+	"main/internal/jobs"
+	"main/internal/telegram"
+	"time"
+)
+
+// Маршруты кнопок фоновых задач
+const (
+	routeJobRun    = "job_run"
+	routeJobPause  = "job_pause"
+	routeJobResume = "job_resume"
+)
+
+const jobTimeLayout = "02.01.2006 15:04:05"
+
+type jobParams struct {
+	Name string
+}
+
+// jobText - состояние задачи для администратора
+func jobText(status jobs.Status) string {
+	text := fmt.Sprintf("⚙️ %s\nРасписание: %s", status.Name, status.Schedule)
+	if status.Exclusive {
+		text += " (одна реплика)"
+	}
+	switch {
+	case status.Running:
+		text += "\nСостояние: выполняется"
+	case status.Paused:
+		text += "\nСостояние: на паузе"
+	default:
+		text += "\nСледующий запуск: " + jobTime(status.NextRun)
+	}
+	text += "\nПоследний запуск: " + jobTime(status.LastRun)
+	if !status.LastRun.IsZero() {
+		text += fmt.Sprintf(" (%s)", status.LastDuration.Round(time.Millisecond))
+	}
+	text += fmt.Sprintf("\nЗапусков: %d, ошибок: %d, выполнено другой репликой: %d",
+		status.Runs, status.Failures, status.Skipped)
+	if len(status.LastError) > 0 {
+		text += "\nПоследняя ошибка: " + status.LastError
+	}
+	return text
+}
+
+func jobTime(t time.Time) string {
+	if t.IsZero() {
+		return "—"
+	}
+	return t.Format(jobTimeLayout)
+}
+
+// MakeJobList - /jobs: фоновые задачи с кнопками запуска и паузы.
+// scheduler вызывается на каждую команду: бот может перейти на общий планировщик
+func MakeJobList(callbacks *telegram.CallbackRouter, scheduler func() *jobs.Scheduler) telegram.TelegramCommand {
+	return telegram.MakeCommandByFilterDefault("jobs", "Фоновые задачи",
+		telegram.SimpleActionStruct{
+			SimpleAction: func(u *telemux.Update) {
+				chatID := u.Message.Chat.ID
+				statuses := scheduler().Status()
+				if len(statuses) == 0 {
+					sendText(u, chatID, "Фоновых задач нет")
+					return
+				}
+				for _, status := range statuses {
+					msg := tgbotapi.NewMessage(chatID, jobText(status))
+					if markup, err := jobKeyboard(callbacks, status); err == nil {
+						msg.ReplyMarkup = telegram.SignKeyboard(u, markup, chatID, telegram.GetUserFromId(u))
+					} else {
+						slog.Error("job buttons", "error", err)
+					}
+					_, _ = u.Bot.Send(msg)
+				}
+			},
+		})
+}
+
+func jobKeyboard(callbacks *telegram.CallbackRouter, status jobs.Status) (tgbotapi.InlineKeyboardMarkup, error) {
+	pause := struct{ text, route string }{"⏸ Пауза", routeJobPause}
+	if status.Paused {
+		pause = struct{ text, route string }{"▶ Возобновить", routeJobResume}
+	}
+	var row []tgbotapi.InlineKeyboardButton
+	for _, button := range []struct{ text, route string }{{"▶️ Запустить", routeJobRun}, pause} {
+		created, err := telegram.Callback(callbacks, button.text, button.route, jobParams{Name: status.Name})
+		if err != nil {
+			return tgbotapi.InlineKeyboardMarkup{}, err
+		}
+		row = append(row, created)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(row), nil
+}
+
+// RegisterJobControls - кнопки запуска, паузы и возобновления фоновых задач
+func RegisterJobControls(callbacks *telegram.CallbackRouter, scheduler func() *jobs.Scheduler, roles *access.Roles) {
+	control := func(action func(s *jobs.Scheduler, name string) error, done string) func(u *telemux.Update, params jobParams) {
+		return func(u *telemux.Update, params jobParams) {
+			if !roles.Check(u, entity.RoleAdmin) {
+				return
+			}
+			text := done
+			if err := action(scheduler(), params.Name); err != nil {
+				text = err.Error()
+			} else if u.CallbackQuery.Message != nil {
+				refreshJobMessage(u, callbacks, scheduler(), params.Name)
+			}
+			_, _ = u.Bot.Request(tgbotapi.NewCallback(u.CallbackQuery.ID, text))
+		}
+	}
+	telegram.Route(callbacks, routeJobRun, control((*jobs.Scheduler).Trigger, "Задача запущена"))
+	telegram.Route(callbacks, routeJobPause, control((*jobs.Scheduler).Pause, "Задача на паузе"))
+	telegram.Route(callbacks, routeJobResume, control((*jobs.Scheduler).Resume, "Задача возобновлена"))
+}
+
+// refreshJobMessage - обновляет сообщение со статусом задачи после нажатия кнопки
+func refreshJobMessage(u *telemux.Update, callbacks *telegram.CallbackRouter, scheduler *jobs.Scheduler, name string) {
+	message := u.CallbackQuery.Message
+	for _, status := range scheduler.Status() {
+		if status.Name != name {
+			continue
+		}
+		edit := tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, jobText(status))
+		if markup, err := jobKeyboard(callbacks, status); err == nil {
+			signed := telegram.SignKeyboard(u, markup, message.Chat.ID, telegram.GetUserFromId(u))
+			edit.ReplyMarkup = &signed
+		}
+		_, _ = u.Bot.Send(edit)
+		return
+	}
+}
+
+// currentJobs - планировщик бота на момент вызова, см. TelegramBot.UseJobs
+func (adminBot *AdminBot) currentJobs() *jobs.Scheduler {
+	return adminBot.Jobs
+}
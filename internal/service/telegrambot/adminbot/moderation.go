@@ -0,0 +1,66 @@
+package adminbot
+
+import (
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"main/internal/entity"
+	"main/internal/receipt"
+)
+
+// captionLimit - максимальная длина подписи к файлу в Telegram
+const captionLimit = 1024
+
+// SendModerationCard - отправляет администратору чек пользователя с вердиктом автоматической проверки
+// и найденными повторами. Отпечаток чека сохраняется в платеже
+func (adminBot *AdminBot) SendModerationCard(chatID int64, payment entity.Payment, message entity.MessageFromUserBot) error {
+	requisites, err := adminBot.requisites.Candidates()
+	if err != nil {
+		return err
+	}
+	result := receipt.VerifyMessage(message, payment, requisites...)
+	duplicates, err := adminBot.registerReceipt(&payment, message)
+	if err != nil {
+		return err
+	}
+	caption := moderationCaption(payment, message, result, duplicates)
+
+	var card tgbotapi.Chattable
+	switch {
+	case message.IsImage:
+		photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: "receipt.jpg", Bytes: message.RequisiteContent})
+		photo.Caption = caption
+		card = photo
+	case message.IsFile:
+		document := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: "receipt.pdf", Bytes: message.RequisiteContent})
+		document.Caption = caption
+		card = document
+	default:
+		card = tgbotapi.NewMessage(chatID, caption+"\n"+string(message.RequisiteContent))
+	}
+	_, err = adminBot.Send(card)
+	return err
+}
+
+// registerReceipt - ищет повторы чека среди всех платежей и запоминает его отпечаток
+func (adminBot *AdminBot) registerReceipt(payment *entity.Payment, message entity.MessageFromUserBot) ([]receipt.Duplicate, error) {
+	fingerprint := receipt.TakeFingerprint(message)
+	payments, err := adminBot.payments.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	duplicates := receipt.FindDuplicates(fingerprint, *payment, payments)
+	fingerprint.Apply(payment)
+	return duplicates, adminBot.payments.Update(*payment)
+}
+
+func moderationCaption(payment entity.Payment, message entity.MessageFromUserBot, result receipt.Result, duplicates []receipt.Duplicate) string {
+	caption := fmt.Sprintf("Платеж #%d, тариф «%s», %d ₽\n", payment.ID, message.TariffPicked.Name, payment.Amount)
+	if len(message.PromoCodePicked.Code) > 0 {
+		caption += "Промокод: " + message.PromoCodePicked.Code + "\n"
+	}
+	caption += "\n" + receipt.DuplicatesCard(duplicates) + result.Card()
+	if runes := []rune(caption); len(runes) > captionLimit {
+		caption = string(runes[:captionLimit-1]) + "…"
+	}
+	return caption
+}
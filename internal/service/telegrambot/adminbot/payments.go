@@ -0,0 +1,52 @@
+package adminbot
+
+import (
+	"errors"
+	"fmt"
+	"github.com/and3rson/telemux/v2"
+	"main/internal/database/entitybase"
+	"main/internal/entity"
+	"main/internal/telegram"
+	"sort"
+	"strings"
+)
+
+// paymentsPageSize - платежей на одной странице /payments
+const paymentsPageSize = 10
+
+// MakePaymentList - /payments: постраничный список платежей, новые сверху
+func MakePaymentList(callbacks *telegram.CallbackRouter, payments entitybase.EntityBase[entity.Payment]) telegram.TelegramCommand {
+	paginator := telegram.NewPaginator(callbacks, "payments", paymentsPageSize,
+		func(u *telemux.Update) ([]entity.Payment, error) {
+			all, err := payments.GetAll()
+			if err != nil {
+				return nil, err
+			}
+			sort.Slice(all, func(i, j int) bool { return all[i].ID > all[j].ID })
+			return all, nil
+		})
+	paginator.Title = "Платежи"
+	paginator.Format = formatPayments
+	return telegram.MakeCommandByFilterDefault("payments", "Список платежей",
+		telegram.SimpleActionStruct{
+			SimpleAction: func(u *telemux.Update) {
+				chatID := u.Message.Chat.ID
+				if err := paginator.Send(u, chatID); err != nil {
+					sendText(u, chatID, err.Error())
+				}
+			},
+		})
+}
+
+// formatPayments - одна строка на платеж: номер, пользователь, сумма, статус и дата
+func formatPayments(payments []entity.Payment) (string, error) {
+	if len(payments) == 0 {
+		return "", errors.New("Нет данных.")
+	}
+	lines := make([]string, len(payments))
+	for i, payment := range payments {
+		lines[i] = fmt.Sprintf("#%d — пользователь %d — %d ₽ — %s — %s", payment.ID, payment.UserID,
+			payment.Amount, telegram.EscapeMarkdown(payment.Status), payment.TimeStamp.Format("02.01.2006 15:04"))
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,53 @@
+package adminbot
+
+import (
+	"github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log/slog"
+	"main/internal/access"
+	"main/internal/database/entitybase"
+	"main/internal/entity"
+	"main/internal/telegram"
+	"sort"
+	"strconv"
+)
+
+// promoCodesPageSize - промокодов на одной странице /promocodes
+const promoCodesPageSize = 10
+
+// MakePromoCodeList - /promocodes: постраничный список промокодов с кнопкой удаления.
+// Удалять может только администратор
+func MakePromoCodeList(callbacks *telegram.CallbackRouter, promoCodes entitybase.EntityBase[entity.PromoCode], roles *access.Roles) telegram.TelegramCommand {
+	paginator := telegram.NewPaginator(callbacks, "promocodes", promoCodesPageSize,
+		func(u *telemux.Update) ([]entity.PromoCode, error) {
+			all, err := promoCodes.GetAll()
+			if err != nil {
+				return nil, err
+			}
+			sort.Slice(all, func(i, j int) bool { return all[i].ID > all[j].ID })
+			return all, nil
+		}).AddAction(func(promoCode entity.PromoCode) string {
+		return "🗑 " + promoCode.Code
+	}, func(u *telemux.Update, promoCode entity.PromoCode) {
+		if !roles.Check(u, entity.RoleAdmin) {
+			return
+		}
+		text := "Промокод " + promoCode.Code + " удален"
+		if err := promoCodes.Delete(promoCode); err != nil {
+			slog.Error("delete promo code", "code", promoCode.Code, "error", err)
+			text = err.Error()
+		}
+		_, _ = u.Bot.Send(tgbotapi.NewMessage(u.CallbackQuery.Message.Chat.ID, text))
+	})
+	paginator.Title = "Промокоды"
+	paginator.Key = func(promoCode entity.PromoCode) string { return strconv.Itoa(promoCode.ID) }
+	return telegram.MakeCommandByFilterDefault("promocodes", "Список промокодов",
+		telegram.SimpleActionStruct{
+			SimpleAction: func(u *telemux.Update) {
+				chatID := u.Message.Chat.ID
+				if err := paginator.Send(u, chatID); err != nil {
+					sendText(u, chatID, err.Error())
+				}
+			},
+		})
+}
@@ -0,0 +1,23 @@
+package adminbot
+
+import (
+	"github.com/and3rson/telemux/v2"
+	"main/internal/reachability"
+	"main/internal/telegram"
+)
+
+// MakeReachabilityReport - /reach: сколько пользователей доступны для сообщений
+// и сколько заблокировали бота или удалили аккаунт
+func MakeReachabilityReport(tracker *reachability.Tracker) telegram.TelegramCommand {
+	return telegram.MakeCommandByFilterDefault("reach", "Доступность пользователей",
+		telegram.SimpleActionStruct{
+			SimpleAction: func(u *telemux.Update) {
+				stats, err := tracker.Stats()
+				if err != nil {
+					sendText(u, u.Message.Chat.ID, err.Error())
+					return
+				}
+				sendText(u, u.Message.Chat.ID, stats.Text()+"\n\nНедоступные пользователи не получают рассылки")
+			},
+		})
+}
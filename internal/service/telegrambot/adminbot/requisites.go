@@ -0,0 +1,431 @@
+package adminbot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"image"
+	"log"
+	"main/internal/access"
+	"main/internal/entity"
+	"main/internal/qrcode"
+	"main/internal/requisite"
+	"main/internal/telegram"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const scheduleLayout = "02.01.2006 15:04"
+
+// editableFields - реквизиты, которые можно поправить после распознавания
+var editableFields = []string{"Name", "PersonalAcc", "BankName", "BIC", "CorrespAcc", "KPP", "PayeeINN", "Purpose"}
+
+// requisiteDraft - распознанный, но еще не сохраненный реквизит
+type requisiteDraft struct {
+	qr      qrcode.QRCode
+	name    string
+	editing string // Реквизит, значение которого ожидается следующим сообщением
+}
+
+// requisiteDrafts - черновики реквизитов по чатам администраторов
+type requisiteDrafts struct {
+	mutex      sync.Mutex
+	drafts     map[int64]*requisiteDraft
+	requisites *requisite.Manager
+	buttons    *telegram.ButtonRegistry
+}
+
+func (d *requisiteDrafts) get(chatID int64) (requisiteDraft, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	draft, ok := d.drafts[chatID]
+	if !ok {
+		return requisiteDraft{}, false
+	}
+	return *draft, true
+}
+
+func (d *requisiteDrafts) set(chatID int64, draft requisiteDraft) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.drafts[chatID] = &draft
+}
+
+func (d *requisiteDrafts) delete(chatID int64) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	delete(d.drafts, chatID)
+}
+
+// MakeRequisiteUpload - фото или файл с QR-кодом: распознает реквизиты и показывает их на проверку.
+// Подпись к фото становится названием реквизита
+func MakeRequisiteUpload(drafts *requisiteDrafts) telegram.TelegramCommand {
+	return telegram.MakeFullCommand("RequisiteUpload", "",
+		func(u *telemux.Update) bool {
+			return u.Message != nil && len(uploadedFileID(u.Message)) > 0
+		},
+		telegram.SimpleActionStruct{
+			SimpleAction: func(u *telemux.Update) {
+				chatID := u.Message.Chat.ID
+				data, err := telegram.DownloadFile(u, uploadedFileID(u.Message))
+				if err != nil {
+					sendText(u, chatID, "Не удалось скачать файл: "+err.Error())
+					return
+				}
+				img, _, err := image.Decode(bytes.NewReader(data))
+				if err != nil {
+					sendText(u, chatID, "Файл не является изображением: "+err.Error())
+					return
+				}
+				draft := requisiteDraft{name: strings.TrimSpace(u.Message.Caption)}
+				if err := draft.qr.AnalyseImage(img); err != nil {
+					sendText(u, chatID, "QR-код с реквизитами не найден: "+err.Error())
+					return
+				}
+				if len(draft.name) == 0 {
+					draft.name = defaultRequisiteName(&draft.qr)
+				}
+				drafts.set(chatID, draft)
+				sendPreview(u, chatID, draft, drafts)
+			},
+		})
+}
+
+// MakeRequisiteFieldInput - новое значение реквизита, выбранного кнопкой «Изменить»
+func MakeRequisiteFieldInput(drafts *requisiteDrafts) telegram.TelegramCommand {
+	return telegram.MakeFullCommand("RequisiteFieldInput", "",
+		func(u *telemux.Update) bool {
+			if u.Message == nil || len(u.Message.Text) == 0 || strings.HasPrefix(u.Message.Text, "/") {
+				return false
+			}
+			draft, ok := drafts.get(u.Message.Chat.ID)
+			return ok && len(draft.editing) > 0
+		},
+		telegram.SimpleActionStruct{
+			SimpleAction: func(u *telemux.Update) {
+				chatID := u.Message.Chat.ID
+				draft, ok := drafts.get(chatID)
+				if !ok {
+					return
+				}
+				if err := draft.qr.SetField(draft.editing, strings.TrimSpace(u.Message.Text)); err != nil {
+					sendText(u, chatID, err.Error())
+					return
+				}
+				draft.editing = ""
+				drafts.set(chatID, draft)
+				sendPreview(u, chatID, draft, drafts)
+			},
+		})
+}
+
+// MakeRequisiteList - /requisites: список реквизитов с кнопками управления
+func MakeRequisiteList(buttons *telegram.ButtonRegistry, requisites *requisite.Manager) telegram.TelegramCommand {
+	return telegram.MakeCommandByFilterDefault("requisites", "Реквизиты для оплаты",
+		telegram.SimpleActionStruct{
+			SimpleAction: func(u *telemux.Update) {
+				sendRequisiteList(u, telegram.GetMessage(u).Chat.ID, buttons, requisites)
+			},
+		})
+}
+
+// MakeRequisiteSchedule - /requisite_switch <ID> <ДД.ММ.ГГГГ ЧЧ:ММ>: плановое переключение реквизита
+func MakeRequisiteSchedule(requisites *requisite.Manager) telegram.TelegramCommand {
+	return telegram.MakeCommandByFilterDefault("requisite_switch", "Запланировать смену реквизита",
+		telegram.SimpleActionStruct{
+			SimpleAction: func(u *telemux.Update) {
+				chatID := u.Message.Chat.ID
+				id, at, err := parseSchedule(u.Message.CommandArguments(), time.Local)
+				if err != nil {
+					sendText(u, chatID, err.Error())
+					return
+				}
+				if err := requisites.Schedule(id, at); err != nil {
+					sendText(u, chatID, err.Error())
+					return
+				}
+				sendText(u, chatID, fmt.Sprintf("Реквизит #%d станет активным %s", id, at.Format(scheduleLayout)))
+			},
+		})
+}
+
+// parseSchedule - разбирает аргументы команды /requisite_switch
+func parseSchedule(arguments string, location *time.Location) (int, time.Time, error) {
+	usage := errors.New("Формат: /requisite_switch <ID> <ДД.ММ.ГГГГ ЧЧ:ММ>")
+	idText, dateText, ok := strings.Cut(strings.TrimSpace(arguments), " ")
+	if !ok {
+		return 0, time.Time{}, usage
+	}
+	id, err := strconv.Atoi(idText)
+	if err != nil {
+		return 0, time.Time{}, usage
+	}
+	at, err := time.ParseInLocation(scheduleLayout, strings.TrimSpace(dateText), location)
+	if err != nil {
+		return 0, time.Time{}, usage
+	}
+	return id, at, nil
+}
+
+// applyRequisiteSchedule - задача, выполняющая наступившие переключения реквизитов
+func applyRequisiteSchedule(requisites *requisite.Manager) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		switched, err := requisites.ApplySchedule(time.Now())
+		if err != nil {
+			return err
+		}
+		if switched != nil {
+			log.Printf("requisite #%d %s is active now", switched.ID, switched.Name)
+		}
+		return nil
+	}
+}
+
+// uploadedFileID - файл изображения из сообщения: самое большое фото либо документ-картинка
+func uploadedFileID(message *tgbotapi.Message) string {
+	if len(message.Photo) > 0 {
+		return message.Photo[len(message.Photo)-1].FileID
+	}
+	if message.Document != nil && strings.HasPrefix(message.Document.MimeType, "image/") {
+		return message.Document.FileID
+	}
+	return ""
+}
+
+func defaultRequisiteName(qr *qrcode.QRCode) string {
+	if qr.IsSBP() {
+		return "СБП " + qr.SBP.ID
+	}
+	name := qr.Name
+	if account := qr.PersonalAcc; len(account) > 4 {
+		name += " *" + account[len(account)-4:]
+	}
+	return name
+}
+
+// previewText - распознанные реквизиты и ошибки проверки
+func previewText(draft requisiteDraft) string {
+	var text strings.Builder
+	text.WriteString("Название: " + draft.name + "\n")
+	var err error
+	if draft.qr.IsSBP() {
+		sbp := draft.qr.SBP
+		text.WriteString("Ссылка СБП: " + sbp.String() + "\n")
+		text.WriteString("Банк: " + sbp.Bank + "\n")
+		err = sbp.Validate()
+	} else {
+		for _, field := range draft.qr.Fields() {
+			if len(field.Value) > 0 && field.Value != "0" {
+				text.WriteString(field.Key + ": " + field.Value + "\n")
+			}
+		}
+		err = draft.qr.Validate()
+	}
+	var validation qrcode.ValidationError
+	if errors.As(err, &validation) {
+		text.WriteString("\nОшибки:\n")
+		for _, fieldError := range validation {
+			text.WriteString("• " + fieldError.Error() + "\n")
+		}
+	}
+	return text.String()
+}
+
+// Имена обработчиков кнопок черновика реквизита в реестре кнопок
+const (
+	buttonDraftSave   = "requisite.draft.save"
+	buttonDraftCancel = "requisite.draft.cancel"
+	buttonDraftEdit   = "requisite.draft.edit"
+)
+
+func sendPreview(u *telemux.Update, chatID int64, draft requisiteDraft, drafts *requisiteDrafts) {
+	msg := tgbotapi.NewMessage(chatID, previewText(draft))
+	keyboard, err := draftKeyboard(drafts.buttons, draft)
+	if err != nil {
+		sendText(u, chatID, err.Error())
+		return
+	}
+	msg.ReplyMarkup = telegram.SignKeyboard(u, keyboard, chatID, telegram.GetUserFromId(u))
+	_, _ = u.Bot.Send(msg)
+}
+
+func draftKeyboard(buttons *telegram.ButtonRegistry, draft requisiteDraft) (tgbotapi.InlineKeyboardMarkup, error) {
+	save, err := buttons.Button("💾 Сохранить", buttonDraftSave, "")
+	if err != nil {
+		return tgbotapi.InlineKeyboardMarkup{}, err
+	}
+	cancel, err := buttons.ButtonWithRequest("❌ Отмена", "Реквизит не сохранен", buttonDraftCancel, "")
+	if err != nil {
+		return tgbotapi.InlineKeyboardMarkup{}, err
+	}
+	rows := [][]tgbotapi.InlineKeyboardButton{{save, cancel}}
+	if !draft.qr.IsSBP() {
+		var row []tgbotapi.InlineKeyboardButton
+		for _, key := range editableFields {
+			edit, err := buttons.ButtonWithRequest("✏️ "+key, "Введите новое значение "+key, buttonDraftEdit, key)
+			if err != nil {
+				return tgbotapi.InlineKeyboardMarkup{}, err
+			}
+			row = append(row, edit)
+			if len(row) == 2 {
+				rows = append(rows, row)
+				row = nil
+			}
+		}
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...), nil
+}
+
+// RegisterDraftButtons - обработчики кнопок черновика реквизита. Сам черновик хранится
+// в памяти процесса: после перезапуска кнопка ответит, что черновика нет
+func RegisterDraftButtons(buttons *telegram.ButtonRegistry, drafts *requisiteDrafts, roles *access.Roles) {
+	buttons.
+		Handle(buttonDraftSave, requireButton(roles, entity.RoleAdmin, saveDraft(drafts))).
+		Handle(buttonDraftCancel, requireButton(roles, entity.RoleAdmin, func(u *telemux.Update, _ string) {
+			drafts.delete(u.CallbackQuery.Message.Chat.ID)
+		})).
+		Handle(buttonDraftEdit, requireButton(roles, entity.RoleAdmin, func(u *telemux.Update, key string) {
+			chatID := u.CallbackQuery.Message.Chat.ID
+			draft, ok := drafts.get(chatID)
+			if !ok {
+				return
+			}
+			draft.editing = key
+			drafts.set(chatID, draft)
+		}))
+}
+
+// saveDraft - проверяет и сохраняет черновик реквизита
+func saveDraft(drafts *requisiteDrafts) telegram.ButtonHandler {
+	return func(u *telemux.Update, _ string) {
+		chatID := u.CallbackQuery.Message.Chat.ID
+		draft, ok := drafts.get(chatID)
+		if !ok {
+			sendText(u, chatID, "Нет распознанного реквизита, пришлите фото QR-кода")
+			return
+		}
+		requisite, err := draft.qr.ToEntity(draft.name)
+		if err != nil {
+			sendText(u, chatID, "Реквизит не сохранен: "+err.Error())
+			return
+		}
+		if err := drafts.requisites.Add(requisite); err != nil {
+			sendText(u, chatID, "Реквизит не сохранен: "+err.Error())
+			return
+		}
+		drafts.delete(chatID)
+		sendText(u, chatID, "Реквизит «"+requisite.Name+"» сохранен")
+	}
+}
+
+// requisiteStatus - краткое описание реквизита в списке
+func requisiteStatus(requisite entity.Requisite) string {
+	text := fmt.Sprintf("#%d %s", requisite.ID, requisite.Name)
+	if requisite.Active {
+		text += " — активный"
+	}
+	if requisite.InRotation {
+		text += fmt.Sprintf(" — в ротации, выдан %d раз", requisite.UsedCount)
+	}
+	if !requisite.ActivateAt.IsZero() {
+		text += " — станет активным " + requisite.ActivateAt.Format(scheduleLayout)
+	}
+	return text
+}
+
+// Имена обработчиков кнопок списка реквизитов в реестре кнопок
+const (
+	buttonRequisiteActivate = "requisite.activate"
+	buttonRequisiteRotation = "requisite.rotation"
+	buttonRequisiteDelete   = "requisite.delete"
+)
+
+// RegisterRequisiteButtons - обработчики кнопок списка реквизитов.
+// Регистрируются при каждом запуске, поэтому кнопки работают и после перезапуска бота.
+// Нажимать кнопки может только администратор: роль могли отозвать после отправки списка
+func RegisterRequisiteButtons(buttons *telegram.ButtonRegistry, requisites *requisite.Manager, roles *access.Roles) {
+	buttons.
+		Handle(buttonRequisiteActivate, requireButton(roles, entity.RoleAdmin,
+			requisiteAction(buttons, requisites, func(id int, _ string) error {
+				return requisites.Activate(id)
+			}))).
+		Handle(buttonRequisiteRotation, requireButton(roles, entity.RoleAdmin,
+			requisiteAction(buttons, requisites, func(id int, argument string) error {
+				return requisites.SetRotation(id, argument == "on")
+			}))).
+		Handle(buttonRequisiteDelete, requireButton(roles, entity.RoleAdmin,
+			requisiteAction(buttons, requisites, func(id int, _ string) error {
+				return requisites.Delete(id)
+			})))
+}
+
+func sendRequisiteList(u *telemux.Update, chatID int64, buttons *telegram.ButtonRegistry, requisites *requisite.Manager) {
+	all, err := requisites.All()
+	if err != nil {
+		sendText(u, chatID, err.Error())
+		return
+	}
+	if len(all) == 0 {
+		sendText(u, chatID, "Реквизитов нет. Пришлите фото QR-кода для оплаты, чтобы добавить")
+		return
+	}
+	for _, item := range all {
+		keyboard, err := requisiteKeyboard(buttons, item)
+		if err != nil {
+			sendText(u, chatID, err.Error())
+			return
+		}
+		msg := tgbotapi.NewMessage(chatID, requisiteStatus(item))
+		msg.ReplyMarkup = telegram.SignKeyboard(u, keyboard, chatID, telegram.GetUserFromId(u))
+		_, _ = u.Bot.Send(msg)
+	}
+}
+
+func requisiteKeyboard(buttons *telegram.ButtonRegistry, item entity.Requisite) (tgbotapi.InlineKeyboardMarkup, error) {
+	id := strconv.Itoa(item.ID)
+	type button struct{ text, handler, payload string }
+	list := []button{{"🔁 Включить ротацию", buttonRequisiteRotation, id + ":on"}}
+	if item.InRotation {
+		list[0] = button{"⏸ Исключить из ротации", buttonRequisiteRotation, id + ":off"}
+	}
+	if !item.Active {
+		list = append([]button{{"✅ Сделать активным", buttonRequisiteActivate, id}}, list...)
+		list = append(list, button{"🗑 Удалить", buttonRequisiteDelete, id})
+	}
+	row := make([]tgbotapi.InlineKeyboardButton, len(list))
+	for i, b := range list {
+		var err error
+		if row[i], err = buttons.Button(b.text, b.handler, b.payload); err != nil {
+			return tgbotapi.InlineKeyboardMarkup{}, err
+		}
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(row), nil
+}
+
+// requisiteAction - выполняет change для реквизита из payload вида "ID[:аргумент]"
+// и показывает обновленный список реквизитов
+func requisiteAction(buttons *telegram.ButtonRegistry, requisites *requisite.Manager, change func(id int, argument string) error) telegram.ButtonHandler {
+	return func(u *telemux.Update, payload string) {
+		chatID := u.CallbackQuery.Message.Chat.ID
+		idText, argument, _ := strings.Cut(payload, ":")
+		id, err := strconv.Atoi(idText)
+		if err != nil {
+			sendText(u, chatID, "неверная кнопка: "+payload)
+			return
+		}
+		if err := change(id, argument); err != nil {
+			sendText(u, chatID, err.Error())
+			return
+		}
+		sendRequisiteList(u, chatID, buttons, requisites)
+	}
+}
+
+func sendText(u *telemux.Update, chatID int64, text string) {
+	_, _ = u.Bot.Send(tgbotapi.NewMessage(chatID, text))
+}
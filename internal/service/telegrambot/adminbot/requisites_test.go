@@ -0,0 +1,45 @@
+package adminbot
+
+import (
+	"main/internal/qrcode"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	id, at, err := parseSchedule(" 3 01.11.2026 09:30", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 3 || !at.Equal(time.Date(2026, 11, 1, 9, 30, 0, 0, time.UTC)) {
+		t.Errorf("неверный разбор: %d %v", id, at)
+	}
+	for _, arguments := range []string{"", "3", "x 01.11.2026 09:30", "3 2026-11-01"} {
+		if _, _, err := parseSchedule(arguments, time.UTC); err == nil {
+			t.Errorf("для %q ожидалась ошибка", arguments)
+		}
+	}
+}
+
+func TestPreviewText(t *testing.T) {
+	draft := requisiteDraft{name: "ООО Ромашка *3017"}
+	draft.qr.Payment = qrcode.Payment{
+		Name:        "ООО Ромашка",
+		PersonalAcc: "40702810138250123017",
+		BankName:    "ПАО Сбербанк",
+		BIC:         "044525225",
+		CorrespAcc:  "30101810400000000225",
+	}
+	text := previewText(draft)
+	if !strings.Contains(text, "PersonalAcc: 40702810138250123017") || strings.Contains(text, "Ошибки") {
+		t.Errorf("неверный предпросмотр:\n%s", text)
+	}
+	draft.qr.BIC = "123"
+	if text := previewText(draft); !strings.Contains(text, "BIC: БИК") {
+		t.Errorf("в предпросмотре нет ошибки БИК:\n%s", text)
+	}
+	if name := defaultRequisiteName(&draft.qr); name != "ООО Ромашка *3017" {
+		t.Errorf("неверное название по умолчанию: %s", name)
+	}
+}
@@ -0,0 +1,116 @@
+package adminbot
+
+import (
+	"errors"
+	"fmt"
+	"github.com/and3rson/telemux/v2"
+	"main/internal/access"
+	"main/internal/entity"
+	"main/internal/telegram"
+	"strings"
+)
+
+// MakeGrantRole - /grant <ID|@username> <роль>: выдача или смена роли сотрудника
+func MakeGrantRole(roles *access.Roles) telegram.TelegramCommand {
+	return telegram.MakeCommandByFilterDefault("grant", "Выдать роль сотруднику",
+		telegram.SimpleActionStruct{
+			SimpleAction: func(u *telemux.Update) {
+				chatID := u.Message.Chat.ID
+				target, role, err := parseGrant(u.Message.CommandArguments())
+				if err != nil {
+					sendText(u, chatID, err.Error())
+					return
+				}
+				if _, err := roles.Grant(u.Message.From.ID, target, role); err != nil {
+					sendText(u, chatID, err.Error())
+					return
+				}
+				sendText(u, chatID, fmt.Sprintf("%s теперь %s", target, role.Title()))
+			},
+		}).Require(entity.RoleOwner)
+}
+
+// MakeRevokeRole - /revoke <ID|@username>: отзыв роли сотрудника
+func MakeRevokeRole(roles *access.Roles) telegram.TelegramCommand {
+	return telegram.MakeCommandByFilterDefault("revoke", "Отозвать роль сотрудника",
+		telegram.SimpleActionStruct{
+			SimpleAction: func(u *telemux.Update) {
+				chatID := u.Message.Chat.ID
+				target := strings.TrimSpace(u.Message.CommandArguments())
+				if len(target) == 0 {
+					sendText(u, chatID, "Формат: /revoke <ID|@username>")
+					return
+				}
+				staff, err := roles.Revoke(u.Message.From.ID, target)
+				if err != nil {
+					sendText(u, chatID, err.Error())
+					return
+				}
+				sendText(u, chatID, fmt.Sprintf("Роль «%s» у %s отозвана", staff.Role.Title(), target))
+			},
+		}).Require(entity.RoleOwner)
+}
+
+// MakeStaffList - /staff: сотрудники и их роли
+func MakeStaffList(roles *access.Roles) telegram.TelegramCommand {
+	return telegram.MakeCommandByFilterDefault("staff", "Сотрудники и роли",
+		telegram.SimpleActionStruct{
+			SimpleAction: func(u *telemux.Update) {
+				chatID := u.Message.Chat.ID
+				staff, err := roles.Staff()
+				if err != nil {
+					sendText(u, chatID, err.Error())
+					return
+				}
+				sendText(u, chatID, formatStaff(staff))
+			},
+		}).Require(entity.RoleOwner)
+}
+
+// parseGrant - разбирает аргументы команды /grant
+func parseGrant(arguments string) (string, entity.Role, error) {
+	fields := strings.Fields(arguments)
+	if len(fields) != 2 {
+		names := make([]string, len(entity.Roles))
+		for i, role := range entity.Roles {
+			names[i] = string(role)
+		}
+		return "", "", errors.New("Формат: /grant <ID|@username> <" + strings.Join(names, "|") + ">")
+	}
+	if _, _, err := access.ParseTarget(fields[0]); err != nil {
+		return "", "", err
+	}
+	role, err := access.ParseRole(fields[1])
+	if err != nil {
+		return "", "", err
+	}
+	return fields[0], role, nil
+}
+
+// formatStaff - одна строка на сотрудника
+func formatStaff(staff []entity.Staff) string {
+	if len(staff) == 0 {
+		return "Ролей не выдано. Владельцы задаются в конфигурации"
+	}
+	lines := make([]string, len(staff))
+	for i, item := range staff {
+		who := fmt.Sprint(item.TelegramID)
+		if len(item.UserName) > 0 {
+			who = "@" + item.UserName
+			if item.TelegramID != 0 {
+				who += fmt.Sprintf(" (%d)", item.TelegramID)
+			}
+		}
+		lines[i] = fmt.Sprintf("%s — %s, с %s", who, item.Role.Title(), item.GrantedAt.Format("02.01.2006"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// requireButton - обработчик кнопки только для сотрудников с ролью role и старше
+func requireButton(roles *access.Roles, role entity.Role, handler telegram.ButtonHandler) telegram.ButtonHandler {
+	return func(u *telemux.Update, payload string) {
+		if roles.Check(u, role) {
+			handler(u, payload)
+		}
+	}
+}
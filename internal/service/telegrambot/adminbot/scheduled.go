@@ -0,0 +1,195 @@
+package adminbot
+
+import (
+	"fmt"
+	"github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log/slog"
+	"main/internal/access"
+	"main/internal/broadcast"
+	"main/internal/entity"
+	"main/internal/telegram"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Маршруты кнопок запланированных рассылок
+const (
+	routeScheduleTime   = "schedule_time"
+	routeScheduleText   = "schedule_text"
+	routeScheduleCancel = "schedule_cancel"
+)
+
+// schedulePreviewLength - сколько символов текста показывать в списке расписаний
+const schedulePreviewLength = 200
+
+type scheduleParams struct {
+	ID int
+}
+
+func sendScheduleUsage(u *telemux.Update, chatID int64, scheduler *broadcast.Scheduler) {
+	sendText(u, chatID, broadcast.ScheduleUsage+"\nЧасовой пояс по умолчанию: "+scheduler.Location().String())
+}
+
+// scheduleBroadcast - сохраняет рассылку из диалога в расписание. Файл диалога уже
+// лежит в каталоге планировщика и переименовывается, чтобы его не удалила первая же
+// рассылка по этому расписанию
+func scheduleBroadcast(u *telemux.Update, session *telegram.Session, scheduler *broadcast.Scheduler, rule entity.ScheduleRule) error {
+	temporary, ok := session.Data["file"]
+	if ok {
+		kept := filepath.Join(filepath.Dir(temporary), "scheduled-"+strings.TrimPrefix(filepath.Base(temporary), "broadcast-"))
+		if err := os.Rename(temporary, kept); err != nil {
+			return err
+		}
+		session.Data["file"] = kept
+	}
+	segment, param := broadcastSegment(session)
+	schedule, err := scheduler.Add(entity.ScheduledBroadcast{
+		Message:      broadcastMessage(session, false),
+		Segment:      segment,
+		SegmentParam: param,
+		Rule:         rule,
+		CreatedBy:    telegram.GetUserFromId(u),
+		AdminChatID:  session.ChatID,
+	})
+	if err != nil {
+		if ok {
+			_ = os.Rename(session.Data["file"], temporary)
+			session.Data["file"] = temporary
+		}
+		return err
+	}
+	sendText(u, session.ChatID, "Рассылка запланирована.\n"+scheduleText(schedule)+"\n\nСписок расписаний: /scheduled")
+	return nil
+}
+
+// scheduleText - расписание для администратора
+func scheduleText(schedule entity.ScheduledBroadcast) string {
+	location := broadcast.Location(schedule.Rule, time.UTC)
+	text := fmt.Sprintf("Расписание #%d: %s\nАудитория: %s\nБлижайший запуск: %s\nЗапусков: %d",
+		schedule.ID, broadcast.RuleTitle(schedule.Rule),
+		broadcast.SegmentTitle(schedule.Segment, schedule.SegmentParam),
+		schedule.NextRun.In(location).Format(scheduleLayout), schedule.Runs)
+	switch {
+	case schedule.Status == entity.ScheduleFailed:
+		text += "\n❌ Не запущена: " + schedule.LastError + "\nПеренесите время или отмените"
+	case len(schedule.LastError) > 0:
+		text += "\n⚠️ Последний запуск не удался: " + schedule.LastError
+	}
+	return text
+}
+
+// MakeScheduledList - /scheduled: действующие и не запустившиеся расписания с кнопками изменения и отмены
+func MakeScheduledList(callbacks *telegram.CallbackRouter, scheduler *broadcast.Scheduler) telegram.TelegramCommand {
+	return telegram.MakeCommandByFilterDefault("scheduled", "Запланированные рассылки",
+		telegram.SimpleActionStruct{
+			SimpleAction: func(u *telemux.Update) {
+				chatID := u.Message.Chat.ID
+				schedules, err := scheduler.Pending()
+				if err != nil {
+					sendText(u, chatID, err.Error())
+					return
+				}
+				if len(schedules) == 0 {
+					sendText(u, chatID, "Запланированных рассылок нет")
+					return
+				}
+				for _, schedule := range schedules {
+					text := scheduleText(schedule)
+					if preview := []rune(schedule.Message.Text); len(preview) > schedulePreviewLength {
+						text += "\n\n" + string(preview[:schedulePreviewLength]) + "…"
+					} else if len(preview) > 0 {
+						text += "\n\n" + string(preview)
+					}
+					if len(schedule.Message.Files) > 0 {
+						text += "\n📎 с файлом"
+					}
+					msg := tgbotapi.NewMessage(chatID, text)
+					if markup, err := scheduleKeyboard(callbacks, schedule.ID); err == nil {
+						msg.ReplyMarkup = telegram.SignKeyboard(u, markup, chatID, telegram.GetUserFromId(u))
+					} else {
+						slog.Error("schedule buttons", "error", err)
+					}
+					_, _ = u.Bot.Send(msg)
+				}
+			},
+		})
+}
+
+func scheduleKeyboard(callbacks *telegram.CallbackRouter, id int) (tgbotapi.InlineKeyboardMarkup, error) {
+	var row []tgbotapi.InlineKeyboardButton
+	for _, button := range []struct{ text, route string }{
+		{"🕒 Время", routeScheduleTime}, {"✏️ Текст", routeScheduleText}, {"⛔ Отменить", routeScheduleCancel},
+	} {
+		created, err := telegram.Callback(callbacks, button.text, button.route, scheduleParams{ID: id})
+		if err != nil {
+			return tgbotapi.InlineKeyboardMarkup{}, err
+		}
+		row = append(row, created)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(row), nil
+}
+
+// RegisterScheduleControls - кнопки изменения и отмены запланированной рассылки;
+// изменение продолжается в диалоге рассылки
+func RegisterScheduleControls(callbacks *telegram.CallbackRouter, dialog *telegram.StateMachine, scheduler *broadcast.Scheduler, roles *access.Roles) {
+	edit := func(state telegram.State) func(u *telemux.Update, params scheduleParams) {
+		return func(u *telemux.Update, params scheduleParams) {
+			if !roles.Check(u, entity.RoleAdmin) {
+				return
+			}
+			if _, err := scheduler.Get(params.ID); err != nil {
+				_, _ = u.Bot.Request(tgbotapi.NewCallback(u.CallbackQuery.ID, err.Error()))
+				return
+			}
+			_, _ = u.Bot.Request(tgbotapi.NewCallback(u.CallbackQuery.ID, ""))
+			dialog.StartWith(u, state, map[string]string{"schedule": strconv.Itoa(params.ID)})
+		}
+	}
+	telegram.Route(callbacks, routeScheduleTime, edit(stateScheduleTime))
+	telegram.Route(callbacks, routeScheduleText, edit(stateScheduleText))
+	telegram.Route(callbacks, routeScheduleCancel, func(u *telemux.Update, params scheduleParams) {
+		if !roles.Check(u, entity.RoleAdmin) {
+			return
+		}
+		text := "Расписание отменено"
+		if err := scheduler.Cancel(params.ID); err != nil {
+			text = err.Error()
+		} else if message := u.CallbackQuery.Message; message != nil {
+			_, _ = u.Bot.Send(tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID,
+				message.Text+"\n\n⛔ Отменено"))
+		}
+		_, _ = u.Bot.Request(tgbotapi.NewCallback(u.CallbackQuery.ID, text))
+	})
+}
+
+// announceSchedule - сообщение о запуске рассылки по расписанию, в нем же дальше ход рассылки
+func (adminBot *AdminBot) announceSchedule(schedule entity.ScheduledBroadcast) int {
+	if schedule.AdminChatID == 0 {
+		return 0
+	}
+	message, err := adminBot.Send(tgbotapi.NewMessage(schedule.AdminChatID,
+		fmt.Sprintf("Запуск по расписанию #%d (%s)…", schedule.ID, broadcast.RuleTitle(schedule.Rule))))
+	if err != nil {
+		slog.Warn("schedule announce", "schedule", schedule.ID, "error", err)
+		return 0
+	}
+	return message.MessageID
+}
+
+// reportScheduleFailure - сообщение администратору о рассылке, которая не запустилась по расписанию
+func (adminBot *AdminBot) reportScheduleFailure(schedule entity.ScheduledBroadcast, err error) {
+	if schedule.AdminChatID == 0 {
+		return
+	}
+	text := fmt.Sprintf("Рассылка по расписанию #%d не запущена: %s", schedule.ID, err.Error())
+	if schedule.Status == entity.ScheduleFailed {
+		text += "\nПеренесите ее или отмените: /scheduled"
+	}
+	if _, err := adminBot.Send(tgbotapi.NewMessage(schedule.AdminChatID, text)); err != nil {
+		slog.Warn("schedule failure report", "schedule", schedule.ID, "error", err)
+	}
+}
@@ -0,0 +1,97 @@
+package adminbot
+
+import (
+	"errors"
+	"fmt"
+	"github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"main/internal/access"
+	"main/internal/database/entitybase"
+	"main/internal/entity"
+	"main/internal/telegram"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// usersPageSize - пользователей на одной странице /users
+const usersPageSize = 10
+
+// MakeUserList - /users: постраничный список пользователей, новые сверху.
+// Кнопка у каждого пользователя присылает его платежи
+func MakeUserList(callbacks *telegram.CallbackRouter, users entitybase.EntityBase[entity.User],
+	payments entitybase.EntityBase[entity.Payment], roles *access.Roles) telegram.TelegramCommand {
+	paginator := telegram.NewPaginator(callbacks, "users", usersPageSize,
+		func(u *telemux.Update) ([]entity.User, error) {
+			all, err := users.GetAll()
+			if err != nil {
+				return nil, err
+			}
+			sort.Slice(all, func(i, j int) bool { return all[i].ID > all[j].ID })
+			return all, nil
+		}).AddAction(func(user entity.User) string {
+		return "💳 Платежи #" + strconv.Itoa(user.ID)
+	}, func(u *telemux.Update, user entity.User) {
+		if !roles.Check(u, entity.RoleAnalyst) {
+			return
+		}
+		sendUserPayments(u, u.CallbackQuery.Message.Chat.ID, payments, user)
+	})
+	paginator.Title = "Пользователи"
+	paginator.Format = formatUsers
+	paginator.Key = func(user entity.User) string { return strconv.Itoa(user.ID) }
+	return telegram.MakeCommandByFilterDefault("users", "Список пользователей",
+		telegram.SimpleActionStruct{
+			SimpleAction: func(u *telemux.Update) {
+				chatID := u.Message.Chat.ID
+				if err := paginator.Send(u, chatID); err != nil {
+					sendText(u, chatID, err.Error())
+				}
+			},
+		})
+}
+
+// formatUsers - одна строка на пользователя: номер, имя, Telegram ID, подписка и доступность
+func formatUsers(users []entity.User) (string, error) {
+	if len(users) == 0 {
+		return "", errors.New("Нет данных.")
+	}
+	lines := make([]string, len(users))
+	for i, user := range users {
+		name := "без имени"
+		if len(user.UserName) > 0 {
+			name = "@" + telegram.EscapeMarkdown(user.UserName)
+		}
+		lines[i] = fmt.Sprintf("#%d — %s — %d", user.ID, name, user.UserTelegramId)
+		if user.ContainsSub {
+			lines[i] += " — подписка"
+		}
+		if user.Unreachable {
+			lines[i] += " — недоступен"
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// sendUserPayments - платежи пользователя новым сообщением, новые сверху
+func sendUserPayments(u *telemux.Update, chatID int64, payments entitybase.EntityBase[entity.Payment], user entity.User) {
+	all, err := payments.GetAll()
+	if err != nil {
+		sendText(u, chatID, err.Error())
+		return
+	}
+	var own []entity.Payment
+	for _, payment := range all {
+		if payment.UserID == user.ID {
+			own = append(own, payment)
+		}
+	}
+	sort.Slice(own, func(i, j int) bool { return own[i].ID > own[j].ID })
+	text, err := formatPayments(own)
+	if err != nil {
+		text = err.Error()
+	}
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("*Платежи пользователя #%d*\n%s", user.ID, text))
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	_, _ = u.Bot.Send(msg)
+}
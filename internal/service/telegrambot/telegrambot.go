@@ -1,28 +1,168 @@
 package telegrambot
 
 import (
+	"context"
+	"errors"
 	"github.com/and3rson/telemux/v2"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"io"
+	"log/slog"
+	"main/config"
+	"main/internal/access"
+	"main/internal/database/keyvalue"
+	"main/internal/database/keyvalue/memorykeyvalue"
+	"main/internal/database/keyvalue/rediskeyvalue"
+	"main/internal/database/lock"
+	"main/internal/database/lock/memorylock"
+	"main/internal/database/lock/redislock"
+	"main/internal/jobs"
+	"main/internal/leader"
+	"main/internal/reachability"
 	"main/internal/telegram"
+	"strings"
+	"sync"
+	"time"
 )
 
+// buttonsCollectPeriod - как часто удаляются истекшие кнопки
+const buttonsCollectPeriod = time.Hour
+
+// pollTimeout - секунд ожидания обновлений в одном запросе getUpdates
+const pollTimeout = 40
+
+// pollRetry - пауза после ошибки getUpdates
+const pollRetry = 3 * time.Second
+
 type TelegramBot struct {
-	telegram.Goroutines
 	telegram.TelegramCommands
-	bot *tgbotapi.BotAPI
+	// Jobs - фоновые задачи бота; работают, пока работает Run, если планировщик не общий, см. UseJobs
+	Jobs       *jobs.Scheduler
+	sharedJobs bool
+	Buttons    *telegram.ButtonRegistry
+	Callbacks  *telegram.CallbackRouter
+	// Outbox - отправка с учетом лимитов Telegram, работает, пока работает Run
+	Outbox *telegram.Outbox
+	bot    *tgbotapi.BotAPI
+	// middlewares - общие для всех команд, выполняются до middleware команды
+	middlewares []telegram.Middleware
+	// roles - проверка TelegramCommand.Role; без ролей команды с ролью недоступны
+	roles *access.Roles
+	// webhook - сервер, на который Telegram присылает обновления; nil - long polling
+	webhook     *WebhookServer
+	webhookPath string
+	// closers - соединения бота, закрываются в Shutdown
+	closers []io.Closer
+	// leader - при нескольких репликах обновления получает и задачи выполняет только ведущая
+	leader *leader.Elector
+	// processes - работают в Run только на ведущей реплике, см. Lead
+	processes []func(ctx context.Context) error
+	// offset - первое обновление, которое бот еще не обработал
+	offset int
+}
+
+// InitLocker - блокировки между репликами из conf: Redis, если он задан, иначе
+// блокировки внутри процесса - для единственной реплики. Соединение с Redis
+// закрывает вызывающий код: блокировки общие для всех ботов процесса
+func InitLocker(conf config.Config) lock.Locker {
+	if len(conf.Redis.Addr) == 0 {
+		return memorylock.InitMemoryLock()
+	}
+	return redislock.InitRedisLock(conf.Redis.Addr, conf.Redis.Password, "paybot:lock:")
 }
 
-func InitBot(token string) (*TelegramBot, error) {
+// InitBot - бот с токеном token. Если в conf задан Redis, кнопки и параметры кнопок
+// хранятся в нем и работают после перезапуска и на другой реплике. locker - блокировки
+// эксклюзивных задач бота и, если включен conf.Leader, аренда ведущей реплики, см. InitLocker
+func InitBot(token string, conf config.Config, locker lock.Locker) (*TelegramBot, error) {
 	api, err := tgbotapi.NewBotAPI(token)
 	if err != nil {
 		return nil, err
 	}
-	return &TelegramBot{
-		Goroutines: *telegram.InitGoroutines(),
+	buttons := telegram.NewButtonRegistry(
+		memorykeyvalue.InitMemoryKeyValue[telegram.ButtonRecord](),
+		telegram.DefaultButtonTTL)
+	callbacks := telegram.NewCallbackRouter(memorykeyvalue.InitMemoryKeyValue[string]())
+	telegramBot := &TelegramBot{
+		Jobs: jobs.NewScheduler(locker),
 		TelegramCommands: telegram.TelegramCommands{
-			telegram.MakeButtonAnalyser(),
+			telegram.MakeCallbackAnalyser(callbacks, buttons),
 			telegram.MakeUserRequestConfirmed(nil)},
-		bot: api}, nil
+		Buttons:   buttons,
+		Callbacks: callbacks,
+		Outbox:    telegram.NewOutbox(api),
+		bot:       api,
+		middlewares: []telegram.Middleware{
+			telegram.Recovery("Произошла ошибка, попробуйте позже"),
+			telegram.WithCallbacks(callbacks)}}
+	if conf.Leader.Enabled {
+		// у каждого бота своя ведущая реплика: ключ с именем бота
+		telegramBot.UseLeader(leader.NewElector(locker, conf.Leader.Key+":"+api.Self.UserName, conf.Leader.TTL))
+	}
+	if len(conf.Redis.Addr) > 0 {
+		telegramBot.UseButtonStorage(
+			InitStorage[telegram.ButtonRecord](telegramBot, conf, "buttons"),
+			InitStorage[string](telegramBot, conf, "callbacks"))
+	}
+	// кнопки в памяти процесса - свои у каждой реплики, поэтому очистка не эксклюзивная;
+	// имя с ботом - чтобы у двух ботов в общем планировщике задачи различались
+	err = telegramBot.Jobs.Add(jobs.Job{
+		Name:     "buttons-gc@" + api.Self.UserName,
+		Schedule: jobs.Every(buttonsCollectPeriod),
+		Jitter:   time.Minute,
+		Run: func(ctx context.Context) error {
+			telegram.CollectButtons(telegram.DefaultButtonTTL)
+			now := time.Now()
+			telegramBot.Buttons.CollectGarbage(now)
+			telegramBot.Callbacks.CollectGarbage(now)
+			return nil
+		},
+	})
+	return telegramBot, err
+}
+
+// InitStorage - хранилище бота с именем name: в Redis под префиксом бота, если Redis
+// задан в conf, иначе в памяти процесса. Соединение с Redis закрывает Shutdown бота
+func InitStorage[Anything any](telegramBot *TelegramBot, conf config.Config, name string) keyvalue.KeyValue[Anything] {
+	if len(conf.Redis.Addr) == 0 {
+		return memorykeyvalue.InitMemoryKeyValue[Anything]()
+	}
+	prefix := "paybot:" + telegramBot.bot.Self.UserName + ":" + name + ":"
+	storage := rediskeyvalue.InitRedisKeyValue[Anything](conf.Redis.Addr, conf.Redis.Password, prefix)
+	telegramBot.closers = append(telegramBot.closers, storage)
+	return storage
+}
+
+// UseJobs - перенести задачи бота в общий планировщик процесса, например чтобы
+// /jobs админ-бота показывал задачи обоих ботов. Общий планировщик запускает
+// и останавливает вызывающий код, Run бота его не запускает. Вызывать до Work
+func (telegramBot *TelegramBot) UseJobs(scheduler *jobs.Scheduler) error {
+	for _, job := range telegramBot.Jobs.Jobs() {
+		if err := scheduler.Add(job); err != nil {
+			return err
+		}
+	}
+	telegramBot.Jobs = scheduler
+	telegramBot.sharedJobs = true
+	return nil
+}
+
+// UseButtonStorage - хранить кнопки реестра и большие параметры кнопок вне процесса,
+// чтобы они работали после перезапуска
+func (telegramBot *TelegramBot) UseButtonStorage(
+	buttons keyvalue.KeyValue[telegram.ButtonRecord],
+	callbacks keyvalue.KeyValue[string]) {
+	telegramBot.Buttons.SetStorage(buttons)
+	telegramBot.Callbacks.SetStorage(callbacks)
+}
+
+// UseCallbackSecret - подписывать callback data кнопок этого бота секретом и отклонять
+// нажатия с неверной или устаревшей подписью. Пустой секрет выключает подпись
+func (telegramBot *TelegramBot) UseCallbackSecret(secret string, ttl time.Duration) {
+	if len(secret) == 0 {
+		telegramBot.Callbacks.SetSigner(nil)
+		return
+	}
+	telegramBot.Callbacks.SetSigner(telegram.NewCallbackSigner(secret, ttl))
 }
 
 func (telegramBot *TelegramBot) initBotMenu() {
@@ -41,26 +181,307 @@ func (telegramBot *TelegramBot) initBotMenu() {
 	_, _ = telegramBot.bot.Send(cmdCfg)
 }
 
-func (telegramBot *TelegramBot) getUpdates(timeOut int) tgbotapi.UpdatesChannel {
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = timeOut
-	return telegramBot.bot.GetUpdatesChan(u)
+// poll - long polling до отмены ctx. В отличие от GetUpdatesChan его можно запустить
+// снова, когда реплика опять станет ведущей. Обновления, полученные после отмены,
+// не передаются боту и не подтверждаются: Telegram отдаст их следующему получателю
+func (telegramBot *TelegramBot) poll(ctx context.Context) tgbotapi.UpdatesChannel {
+	updates := make(chan tgbotapi.Update, telegramBot.bot.Buffer)
+	offset := telegramBot.offset
+	go func() {
+		defer close(updates)
+		for ctx.Err() == nil {
+			request := tgbotapi.NewUpdate(offset)
+			request.Timeout = pollTimeout
+			received, err := telegramBot.bot.GetUpdates(request)
+			if err != nil {
+				slog.Warn("telegram polling", "error", err)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(pollRetry):
+				}
+				continue
+			}
+			for _, update := range received {
+				select {
+				case <-ctx.Done():
+					return
+				case updates <- update:
+					offset = update.UpdateID + 1
+				}
+			}
+		}
+	}()
+	return updates
 }
 
-func (telegramBot *TelegramBot) dispatchUpdates() {
+// confirmUpdates - подтверждает обработанные обновления, чтобы следующая ведущая реплика
+// или следующий запуск не обработали их повторно. Обновление, которое вернет этот
+// запрос, не подтверждается и придет снова
+func (telegramBot *TelegramBot) confirmUpdates() {
+	if telegramBot.offset == 0 {
+		return
+	}
+	request := tgbotapi.NewUpdate(telegramBot.offset)
+	request.Limit = 1
+	if _, err := telegramBot.bot.GetUpdates(request); err != nil {
+		slog.Warn("telegram polling: confirm updates", "offset", telegramBot.offset, "error", err)
+	}
+}
+
+// UseWebhook - получать обновления через server на пути path вместо long polling.
+// Вызывать до Work; у каждого бота процесса должен быть свой path
+func (telegramBot *TelegramBot) UseWebhook(server *WebhookServer, path string) {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	telegramBot.webhook = server
+	telegramBot.webhookPath = path
+}
+
+// updates - источник обновлений: webhook, если он задан, иначе long polling.
+// Telegram не отдает обновления через getUpdates, пока установлен webhook, поэтому
+// в режиме polling webhook удаляется
+func (telegramBot *TelegramBot) updates(ctx context.Context) (tgbotapi.UpdatesChannel, error) {
+	if telegramBot.webhook == nil {
+		if err := telegramBot.StopWebhook(); err != nil {
+			return nil, err
+		}
+		return telegramBot.poll(ctx), nil
+	}
+	return telegramBot.webhook.listen(telegramBot.webhookPath), nil
+}
+
+// SetWebhook - регистрирует адрес бота из UseWebhook в Telegram. Адрес общий для всех
+// реплик, поэтому регистрировать его достаточно один раз: Run делает это на ведущей
+// реплике или, без UseLeader, при запуске
+func (telegramBot *TelegramBot) SetWebhook() error {
+	if telegramBot.webhook == nil {
+		return errors.New("webhook не задан, см. UseWebhook")
+	}
+	return telegramBot.webhook.setWebhook(telegramBot.bot, telegramBot.webhookPath)
+}
+
+// registerWebhook - SetWebhook, пока реплика ведущая
+func (telegramBot *TelegramBot) registerWebhook(ctx context.Context) error {
+	if err := telegramBot.SetWebhook(); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	return nil
+}
+
+// StopWebhook - удаляет webhook в Telegram, обновления копятся, пока бот снова не
+// начнет их получать. Остановка реплики webhook не удаляет: его обслуживают остальные
+// реплики; удалять его нужно при выводе бота из эксплуатации или переходе на polling
+func (telegramBot *TelegramBot) StopWebhook() error {
+	_, err := telegramBot.bot.Request(tgbotapi.DeleteWebhookConfig{})
+	return err
+}
+
+// Use - добавляет middleware для всех команд. Вызывать до Work
+func (telegramBot *TelegramBot) Use(middlewares ...telegram.Middleware) {
+	telegramBot.middlewares = append(telegramBot.middlewares, middlewares...)
+}
+
+// UseRoles - проверять роли сотрудников для команд с TelegramCommand.Role. Вызывать до Work
+func (telegramBot *TelegramBot) UseRoles(roles *access.Roles) {
+	telegramBot.roles = roles
+}
+
+// UseLeader - при нескольких репликах получать обновления long polling, регистрировать
+// webhook и выполнять фоновые задачи бота только на ведущей; остальные реплики только
+// отправляют сообщения и принимают webhook. InitBot вызывает его, если включен conf.Leader.
+// Elector запускает Run бота, поэтому он не должен быть общим с другим кодом; общий
+// планировщик UseJobs (через Elector.Lead) запускает вызывающий код. Вызывать до Work
+func (telegramBot *TelegramBot) UseLeader(elector *leader.Elector) {
+	telegramBot.leader = elector
+}
+
+// Lead - процесс, который Run выполняет, пока реплика ведущая (без UseLeader - все время
+// работы Run): например продолжение прерванных рассылок. Run возвращается после остановки
+// процессов, но до остановки Outbox, чтобы их сообщения успели уйти. Вызывать до Work
+func (telegramBot *TelegramBot) Lead(runner func(ctx context.Context) error) {
+	telegramBot.processes = append(telegramBot.processes, runner)
+}
+
+// UseReachability - отмечать пользователей, заблокировавших бота: по ошибкам отправки
+// через Outbox и по my_chat_member. Для бота, который пишет пользователям; вызывать до Work
+func (telegramBot *TelegramBot) UseReachability(tracker *reachability.Tracker) {
+	telegramBot.Outbox.Unreachable = tracker.MarkUnreachable
+	telegramBot.TelegramCommands = append(telegram.TelegramCommands{reachability.MakeChatMemberCommand(tracker)},
+		telegramBot.TelegramCommands...)
+}
+
+func (telegramBot *TelegramBot) makeMux() *telemux.Mux {
 	mux := telemux.NewMux()
+	// паника в фильтре не должна останавливать бота
+	mux.Recover = func(u *telemux.Update, err error, stackTrace string) {
+		slog.Error("panic in telegram filter", "error", err, "stack", stackTrace)
+	}
 	for _, command := range telegramBot.TelegramCommands {
+		middlewares := append([]telegram.Middleware{}, telegramBot.middlewares...)
+		if len(command.Role) > 0 {
+			middlewares = append(middlewares, telegramBot.roles.Require(command.Role))
+		}
+		middlewares = append(middlewares, command.Middlewares...)
+		action := telegram.Chain(command.Action, middlewares...)
 		mux.AddHandler(telemux.NewHandler(command.Filter, func(u *telemux.Update) {
-			command.Action.Action(u)
+			telegram.SetCommandName(u, command.Name)
+			action.Action(u)
 		}))
+	}
+	return mux
+}
+
+// Run - обрабатывает обновления до отмены ctx. После отмены бот перестает получать
+// обновления, дообрабатывает уже полученные и возвращается; обновления, которые
+// Telegram не успел отдать, придут при следующем запуске.
+// С UseLeader обновления из long polling, фоновые задачи и процессы Lead обрабатываются
+// только на ведущей реплике, очередь отправки работает на всех
+func (telegramBot *TelegramBot) Run(ctx context.Context) error {
+	telegramBot.initBotMenu()
+	mux := telegramBot.makeMux()
+	// очередь отправки останавливается после дообработки обновлений,
+	// чтобы ответы на них успели уйти
+	outboxCtx, stopOutbox := context.WithCancel(context.Background())
+	outboxStopped := make(chan struct{})
+	go func() {
+		_ = telegramBot.Outbox.Run(outboxCtx)
+		close(outboxStopped)
+	}()
+	defer func() {
+		stopOutbox()
+		<-outboxStopped
+	}()
+	// аренда освобождается после остановки процессов Lead: другая реплика становится
+	// ведущей сразу, но не раньше, чем эта дошлет уже поставленные в очередь сообщения
+	if telegramBot.leader != nil {
+		electorCtx, stopElector := context.WithCancel(context.Background())
+		electorStopped := make(chan struct{})
+		go func() {
+			if err := telegramBot.leader.Run(electorCtx); err != nil {
+				slog.Error("telegram bot leader", "error", err)
+			}
+			close(electorStopped)
+		}()
+		defer func() {
+			stopElector()
+			<-electorStopped
+		}()
+	}
+	var processes sync.WaitGroup
+	defer processes.Wait()
+	for _, process := range telegramBot.processes {
+		processes.Add(1)
+		go func() {
+			defer processes.Done()
+			if err := telegramBot.lead(ctx, process); err != nil {
+				slog.Error("telegram bot process", "error", err)
+			}
+		}()
+	}
+	if !telegramBot.sharedJobs {
+		go func() {
+			if err := telegramBot.lead(ctx, telegramBot.Jobs.Run); err != nil {
+				slog.Error("telegram bot jobs", "error", err)
+			}
+		}()
+	}
+	receive := func(ctx context.Context) error {
+		return telegramBot.receive(ctx, mux)
+	}
+	// webhook получает та реплика, на которую обновление направит балансировщик,
+	// а регистрирует его в Telegram одна из них
+	if telegramBot.webhook != nil {
+		if telegramBot.leader == nil {
+			if err := telegramBot.SetWebhook(); err != nil {
+				return err
+			}
+		} else {
+			go func() {
+				if err := telegramBot.lead(ctx, telegramBot.registerWebhook); err != nil {
+					slog.Error("telegram webhook", "error", err)
+				}
+			}()
+		}
+		return receive(ctx)
+	}
+	return telegramBot.lead(ctx, receive)
+}
+
+// lead - runner только на ведущей реплике, если задан выбор ведущего
+func (telegramBot *TelegramBot) lead(ctx context.Context, runner func(ctx context.Context) error) error {
+	if telegramBot.leader == nil {
+		return runner(ctx)
+	}
+	return telegramBot.leader.Lead(ctx, runner)
+}
 
+// receive - получает и обрабатывает обновления до отмены ctx
+func (telegramBot *TelegramBot) receive(ctx context.Context, mux *telemux.Mux) error {
+	updates, err := telegramBot.updates(ctx)
+	if err != nil {
+		return err
 	}
-	for update := range telegramBot.getUpdates(40) {
+	dispatch := func(update tgbotapi.Update) {
 		mux.Dispatch(telegramBot.bot, update)
+		telegramBot.offset = update.UpdateID + 1
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			drainUpdates(updates, dispatch)
+			if telegramBot.webhook == nil {
+				telegramBot.confirmUpdates()
+			}
+			return nil
+		case update, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			dispatch(update)
+		}
+	}
+}
+
+// drainUpdates - обрабатывает обновления, уже лежащие в канале, не дожидаясь новых
+func drainUpdates(updates tgbotapi.UpdatesChannel, dispatch func(tgbotapi.Update)) {
+	for {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			dispatch(update)
+		default:
+			return
+		}
+	}
+}
+
+// Shutdown - дожидается завершения фоновых задач бота до дедлайна ctx, затем
+// закрывает соединения бота. Webhook остается зарегистрированным, см. StopWebhook.
+// Вызывать после возврата из Run
+func (telegramBot *TelegramBot) Shutdown(ctx context.Context) error {
+	var err error
+	if !telegramBot.sharedJobs {
+		err = telegramBot.Jobs.Wait(ctx)
 	}
+	for _, closer := range telegramBot.closers {
+		err = errors.Join(err, closer.Close())
+	}
+	return err
 }
 
 func (telegramBot *TelegramBot) Work() {
-	telegramBot.initBotMenu()
-	telegramBot.dispatchUpdates()
+	if err := telegramBot.Run(context.Background()); err != nil {
+		slog.Error("telegram bot", "error", err)
+	}
+}
+
+// Send - отправляет сообщение от имени бота вне обработки обновлений, сразу и без учета лимитов.
+// Для рассылок и массовых уведомлений - Outbox
+func (telegramBot *TelegramBot) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	return telegramBot.bot.Send(c)
 }
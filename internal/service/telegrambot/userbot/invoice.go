@@ -0,0 +1,18 @@
+package userbot
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"main/internal/invoice"
+)
+
+// SendInvoice - отправляет пользователю счет на оплату PDF-документом
+func (userBot *UserBot) SendInvoice(chatID int64, inv *invoice.Invoice) error {
+	data, err := inv.Pdf()
+	if err != nil {
+		return err
+	}
+	document := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: inv.FileName(), Bytes: data})
+	document.Caption = inv.Title() + "\n" + invoice.SumInWords(inv.Total())
+	_, err = userBot.Send(document)
+	return err
+}
@@ -0,0 +1,31 @@
+package userbot
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"main/internal/entity"
+	"main/internal/qrcode"
+	"strconv"
+)
+
+// SendRequisite - отправляет пользователю QR-код для оплаты суммы sum
+// по настроенному реквизиту: ссылку СБП, если она задана, иначе QR-код по ГОСТ
+func (userBot *UserBot) SendRequisite(chatID int64, requisite *entity.Requisite, sum float64) error {
+	qr := qrcode.QRCode{}
+	if err := qr.FromEntity(requisite); err != nil {
+		return err
+	}
+	image, err := qr.Image(sum, 512)
+	if err != nil {
+		return err
+	}
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: "qr.png", Bytes: image})
+	photo.Caption = requisite.Name + "\nСумма к оплате: " + strconv.FormatFloat(sum, 'f', 2, 64) + " ₽"
+	if qr.IsSBP() {
+		link := qr.SBP.WithSum(sum)
+		photo.Caption += "\nОтсканируйте QR-код камерой телефона или откройте ссылку СБП:\n" + link.String()
+	} else {
+		photo.Caption += "\nОтсканируйте QR-код в приложении банка, реквизиты заполнятся автоматически"
+	}
+	_, err = userBot.Send(photo)
+	return err
+}
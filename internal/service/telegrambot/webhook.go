@@ -0,0 +1,173 @@
+package telegrambot
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log/slog"
+	"main/config"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Способы получения обновлений, config.Webhook.Mode
+const (
+	ModePolling = "polling"
+	ModeWebhook = "webhook"
+)
+
+// secretTokenHeader - заголовок, в котором Telegram передает secret_token из setWebhook
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// webhookQueueSize - обновлений в очереди бота, пока обработчики заняты
+const webhookQueueSize = 100
+
+// webhookShutdownTimeout - сколько сервер ждет текущие запросы при остановке
+const webhookShutdownTimeout = 5 * time.Second
+
+// secretTokenPattern - допустимые символы secret_token по документации Bot API
+var secretTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// WebhookServer - один HTTP(S) сервер на процесс, каждый бот принимает обновления на своем пути
+type WebhookServer struct {
+	baseURL  string
+	secret   string
+	certFile string
+	keyFile  string
+	// publicCert - самоподписанный сертификат, который нужно передать в setWebhook
+	publicCert string
+	mux        *http.ServeMux
+	server     *http.Server
+}
+
+// InitWebhookServer - сервер из конфигурации; nil в режиме polling
+func InitWebhookServer(conf config.Config) (*WebhookServer, error) {
+	switch conf.Webhook.Mode {
+	case "", ModePolling:
+		return nil, nil
+	case ModeWebhook:
+		return NewWebhookServer(conf.Webhook.Listen, conf.Webhook.URL, conf.Webhook.Secret,
+			conf.Webhook.CertFile, conf.Webhook.KeyFile, conf.Webhook.PublicCert)
+	}
+	return nil, fmt.Errorf("неизвестный режим получения обновлений: %s", conf.Webhook.Mode)
+}
+
+// NewWebhookServer - сервер на адресе listen. baseURL - публичный адрес, по которому
+// Telegram доступен сервер; при certFile и keyFile сервер сам завершает TLS
+func NewWebhookServer(listen, baseURL, secret, certFile, keyFile, publicCert string) (*WebhookServer, error) {
+	if len(baseURL) == 0 {
+		return nil, errors.New("webhook: не задан публичный url")
+	}
+	if !secretTokenPattern.MatchString(secret) {
+		return nil, errors.New("webhook: secret должен состоять из 1-256 символов A-Z, a-z, 0-9, _ и -")
+	}
+	mux := http.NewServeMux()
+	return &WebhookServer{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		secret:     secret,
+		certFile:   certFile,
+		keyFile:    keyFile,
+		publicCert: publicCert,
+		mux:        mux,
+		server:     &http.Server{Addr: listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second},
+	}, nil
+}
+
+// ListenAndServe - принимает обновления до Shutdown
+func (s *WebhookServer) ListenAndServe() error {
+	var err error
+	if len(s.certFile) > 0 && len(s.keyFile) > 0 {
+		err = s.server.ListenAndServeTLS(s.certFile, s.keyFile)
+	} else {
+		err = s.server.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Run - принимает обновления до отмены ctx, затем останавливает сервер,
+// давая текущим запросам webhookShutdownTimeout
+func (s *WebhookServer) Run(ctx context.Context) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.ListenAndServe()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+	defer cancel()
+	return s.Shutdown(shutdownCtx)
+}
+
+// Shutdown - останавливает сервер, дожидаясь текущих запросов
+func (s *WebhookServer) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// Handler - обработчик всех путей сервера, например для подключения к своему http.Server
+func (s *WebhookServer) Handler() http.Handler {
+	return s.mux
+}
+
+// listen - обновления, пришедшие на path
+func (s *WebhookServer) listen(path string) tgbotapi.UpdatesChannel {
+	updates := make(chan tgbotapi.Update, webhookQueueSize)
+	s.mux.Handle(path, s.updateHandler(updates))
+	return updates
+}
+
+// updateHandler - проверяет secret_token и передает обновление боту. Пока очередь бота
+// полна, запрос ждет; если Telegram оборвал запрос, он повторит обновление сам
+func (s *WebhookServer) updateHandler(updates chan<- tgbotapi.Update) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		token := r.Header.Get(secretTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.secret)) != 1 {
+			slog.Warn("webhook: wrong secret token", "path", r.URL.Path, "remote", r.RemoteAddr)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		var update tgbotapi.Update
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&update); err != nil {
+			http.Error(w, "bad update", http.StatusBadRequest)
+			return
+		}
+		select {
+		case updates <- update:
+			w.WriteHeader(http.StatusOK)
+		case <-r.Context().Done():
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		}
+	})
+}
+
+// setWebhook - регистрирует адрес бота в Telegram. Библиотека не знает secret_token,
+// поэтому параметры собираются вручную
+func (s *WebhookServer) setWebhook(bot *tgbotapi.BotAPI, path string) error {
+	params := tgbotapi.Params{}
+	params["url"] = s.baseURL + path
+	params["secret_token"] = s.secret
+	var err error
+	if len(s.publicCert) > 0 {
+		_, err = bot.UploadFiles("setWebhook", params, []tgbotapi.RequestFile{
+			{Name: "certificate", Data: tgbotapi.FilePath(s.publicCert)},
+		})
+	} else {
+		_, err = bot.MakeRequest("setWebhook", params)
+	}
+	return err
+}
@@ -0,0 +1,77 @@
+package telegrambot
+
+import (
+	"main/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhookServerValidation(t *testing.T) {
+	if _, err := NewWebhookServer(":0", "https://example.com", "bad secret!", "", "", ""); err == nil {
+		t.Errorf("secret с недопустимыми символами должен отклоняться")
+	}
+	if _, err := NewWebhookServer(":0", "", "secret", "", "", ""); err == nil {
+		t.Errorf("без публичного url webhook невозможен")
+	}
+	var conf config.Config
+	if server, err := InitWebhookServer(conf); server != nil || err != nil {
+		t.Errorf("по умолчанию должен использоваться polling")
+	}
+	conf.Webhook.Mode = "socket"
+	if _, err := InitWebhookServer(conf); err == nil {
+		t.Errorf("неизвестный режим должен быть ошибкой")
+	}
+}
+
+func TestWebhookHandler(t *testing.T) {
+	server, err := NewWebhookServer(":0", "https://example.com/", "top_secret-1", "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userUpdates := server.listen("/user")
+	adminUpdates := server.listen("/admin")
+
+	send := func(method, path, secret, body string) int {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		if len(secret) > 0 {
+			request.Header.Set(secretTokenHeader, secret)
+		}
+		recorder := httptest.NewRecorder()
+		server.Handler().ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	update := `{"update_id":7,"message":{"message_id":1,"text":"/start","chat":{"id":5}}}`
+	if code := send(http.MethodPost, "/admin", "top_secret-1", update); code != http.StatusOK {
+		t.Fatalf("ожидали 200, получили %d", code)
+	}
+	select {
+	case got := <-adminUpdates:
+		if got.UpdateID != 7 || got.Message == nil || got.Message.Text != "/start" {
+			t.Errorf("обновление разобрано неверно: %+v", got)
+		}
+	default:
+		t.Fatalf("обновление не попало в очередь бота")
+	}
+	if len(userUpdates) != 0 {
+		t.Errorf("обновление попало не тому боту")
+	}
+
+	if code := send(http.MethodPost, "/user", "wrong", update); code != http.StatusForbidden {
+		t.Errorf("неверный secret token: ожидали 403, получили %d", code)
+	}
+	if code := send(http.MethodPost, "/user", "", update); code != http.StatusForbidden {
+		t.Errorf("без secret token: ожидали 403, получили %d", code)
+	}
+	if code := send(http.MethodGet, "/user", "top_secret-1", ""); code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: ожидали 405, получили %d", code)
+	}
+	if code := send(http.MethodPost, "/user", "top_secret-1", "{"); code != http.StatusBadRequest {
+		t.Errorf("битый JSON: ожидали 400, получили %d", code)
+	}
+	if len(userUpdates) != 0 {
+		t.Errorf("отклоненные запросы не должны попадать в очередь")
+	}
+}
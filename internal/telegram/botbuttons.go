@@ -4,31 +4,78 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"main/internal/hash"
 	"strconv"
+	"sync"
+	"time"
 )
 
 type RequestFromButton struct {
 	RequestMessage string
 	SecondAction   Action
+	CreatedAt      time.Time
 }
 
 type UsefulContentButtons map[string]RequestFromButton
 
 var (
 	globalUsefulContentButtons UsefulContentButtons
+	globalButtonsMutex         sync.RWMutex
 )
 
 func init() {
 	globalUsefulContentButtons = make(UsefulContentButtons)
 }
 
-// Создание кнопки с отсылаемым текстом и дополнительным действием
+// Создание кнопки с отсылаемым текстом и дополнительным действием.
+// Действие живет только в памяти процесса, кнопки, переживающие перезапуск, создает ButtonRegistry
 func MakeButton(text string, request string, action Action) tgbotapi.InlineKeyboardButton {
 	str := text + request + strconv.Itoa(len(request)+len(text))
-	globalUsefulContentButtons[hash.MD5(str)] =
-		RequestFromButton{RequestMessage: request, SecondAction: action}
+	storeButton(hash.MD5(str), RequestFromButton{RequestMessage: request, SecondAction: action})
 	return tgbotapi.NewInlineKeyboardButtonData(text, hash.MD5(str))
 }
 
+// MakeButtonWithKey - кнопка, различаемая по key, а не по тексту: одинаковые надписи
+// с разными key не перезаписывают действия друг друга
+func MakeButtonWithKey(text string, key string, action Action) tgbotapi.InlineKeyboardButton {
+	storeButton(hash.MD5(key), RequestFromButton{SecondAction: action})
+	return tgbotapi.NewInlineKeyboardButtonData(text, hash.MD5(key))
+}
+
+func storeButton(key string, button RequestFromButton) {
+	button.CreatedAt = time.Now()
+	globalButtonsMutex.Lock()
+	defer globalButtonsMutex.Unlock()
+	globalUsefulContentButtons[key] = button
+}
+
+func getButton(key string) (RequestFromButton, bool) {
+	globalButtonsMutex.RLock()
+	defer globalButtonsMutex.RUnlock()
+	button, ok := globalUsefulContentButtons[key]
+	return button, ok
+}
+
+// GetGlobalUsefulContentButtons - копия всех кнопок, созданных MakeButton
 func GetGlobalUsefulContentButtons() UsefulContentButtons {
-	return globalUsefulContentButtons
+	globalButtonsMutex.RLock()
+	defer globalButtonsMutex.RUnlock()
+	buttons := make(UsefulContentButtons, len(globalUsefulContentButtons))
+	for key, button := range globalUsefulContentButtons {
+		buttons[key] = button
+	}
+	return buttons
+}
+
+// CollectButtons - забывает кнопки MakeButton старше maxAge. Возвращает их количество
+func CollectButtons(maxAge time.Duration) int {
+	deadline := time.Now().Add(-maxAge)
+	globalButtonsMutex.Lock()
+	defer globalButtonsMutex.Unlock()
+	removed := 0
+	for key, button := range globalUsefulContentButtons {
+		if button.CreatedAt.Before(deadline) {
+			delete(globalUsefulContentButtons, key)
+			removed++
+		}
+	}
+	return removed
 }
@@ -0,0 +1,132 @@
+package telegram
+
+import (
+	"errors"
+	"github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log"
+	"main/internal/database/keyvalue"
+	"main/internal/hash"
+	"sync"
+	"time"
+)
+
+// DefaultButtonTTL - сколько живут кнопки, если не указано иное
+const DefaultButtonTTL = 30 * 24 * time.Hour
+
+// ButtonHandler - именованный обработчик нажатия, payload сохранен при создании кнопки
+type ButtonHandler func(u *telemux.Update, payload string)
+
+// ButtonRecord - сохраненная кнопка: имя обработчика вместо замыкания,
+// поэтому кнопка работает и после перезапуска бота
+type ButtonRecord struct {
+	Handler        string
+	Payload        string
+	RequestMessage string
+	ExpiresAt      time.Time
+}
+
+// ButtonRegistry - реестр кнопок в хранилище с временем жизни.
+// Обработчики регистрируются по имени при каждом запуске
+type ButtonRegistry struct {
+	mutex    sync.RWMutex
+	storage  keyvalue.KeyValue[ButtonRecord]
+	handlers map[string]ButtonHandler
+	ttl      time.Duration
+}
+
+func NewButtonRegistry(storage keyvalue.KeyValue[ButtonRecord], ttl time.Duration) *ButtonRegistry {
+	return &ButtonRegistry{
+		storage:  storage,
+		handlers: make(map[string]ButtonHandler),
+		ttl:      ttl,
+	}
+}
+
+// SetStorage - переключает реестр на другое хранилище, например Redis
+func (r *ButtonRegistry) SetStorage(storage keyvalue.KeyValue[ButtonRecord]) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.storage = storage
+}
+
+// Handle - привязывает обработчик к имени
+func (r *ButtonRegistry) Handle(name string, handler ButtonHandler) *ButtonRegistry {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.handlers[name] = handler
+	return r
+}
+
+// Button - кнопка, нажатие которой вызовет обработчик handler с payload.
+// Повторное создание той же кнопки продлевает ее жизнь
+func (r *ButtonRegistry) Button(text, handler, payload string) (tgbotapi.InlineKeyboardButton, error) {
+	return r.ButtonWithRequest(text, "", handler, payload)
+}
+
+// ButtonWithRequest - как Button, но при нажатии бот сначала отправляет request
+func (r *ButtonRegistry) ButtonWithRequest(text, request, handler, payload string) (tgbotapi.InlineKeyboardButton, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if _, ok := r.handlers[handler]; !ok {
+		return tgbotapi.InlineKeyboardButton{}, errors.New("unknown button handler " + handler)
+	}
+	key := buttonKey(handler, payload, request)
+	record := ButtonRecord{Handler: handler, Payload: payload, RequestMessage: request}
+	if r.ttl > 0 {
+		record.ExpiresAt = time.Now().Add(r.ttl)
+	}
+	if err := r.storage.Set(key, record, r.ttl); err != nil {
+		return tgbotapi.InlineKeyboardButton{}, err
+	}
+	return tgbotapi.NewInlineKeyboardButtonData(text, key), nil
+}
+
+// Dispatch - обрабатывает нажатие кнопки из реестра. false - кнопка не найдена или истекла
+func (r *ButtonRegistry) Dispatch(u *telemux.Update) bool {
+	if u.CallbackQuery == nil {
+		return false
+	}
+	r.mutex.RLock()
+	storage := r.storage
+	r.mutex.RUnlock()
+	record, err := storage.Get(u.CallbackQuery.Data)
+	if err != nil {
+		if !errors.Is(err, keyvalue.ErrNotFound) {
+			log.Println("button registry:", err)
+		}
+		return false
+	}
+	// хранилища без собственного TTL, например БД, возвращают истекшие записи
+	if !record.ExpiresAt.IsZero() && time.Now().After(record.ExpiresAt) {
+		_ = storage.Delete(u.CallbackQuery.Data)
+		return false
+	}
+	r.mutex.RLock()
+	handler, ok := r.handlers[record.Handler]
+	r.mutex.RUnlock()
+	if !ok {
+		log.Println("button registry: handler is not registered:", record.Handler)
+		return false
+	}
+	if len(record.RequestMessage) > 0 && u.Bot != nil {
+		_, _ = u.Bot.Send(tgbotapi.NewMessage(u.CallbackQuery.Message.Chat.ID, record.RequestMessage))
+	}
+	handler(u, record.Payload)
+	return true
+}
+
+// CollectGarbage - удаляет истекшие кнопки, если хранилище само этого не делает
+func (r *ButtonRegistry) CollectGarbage(now time.Time) int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if collector, ok := r.storage.(interface{ CollectGarbage(time.Time) int }); ok {
+		return collector.CollectGarbage(now)
+	}
+	return 0
+}
+
+// buttonKey - callback data кнопки: 32 hex-символа, укладывается в лимит Telegram в 64 байта
+func buttonKey(handler, payload, request string) string {
+	return hash.MD5(handler + "\x00" + payload + "\x00" + request)
+}
@@ -0,0 +1,111 @@
+package telegram
+
+import (
+	"github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"main/internal/database/keyvalue"
+	"main/internal/database/keyvalue/memorykeyvalue"
+	"testing"
+	"time"
+)
+
+// recordsWithoutTTL - хранилище, которое не умеет удалять истекшие записи, как таблица в БД
+type recordsWithoutTTL map[string]ButtonRecord
+
+func (r recordsWithoutTTL) Set(key string, value ButtonRecord, ttl time.Duration) error {
+	r[key] = value
+	return nil
+}
+
+func (r recordsWithoutTTL) Get(key string) (*ButtonRecord, error) {
+	value, ok := r[key]
+	if !ok {
+		return nil, keyvalue.ErrNotFound
+	}
+	return &value, nil
+}
+
+func (r recordsWithoutTTL) Delete(key string) error {
+	delete(r, key)
+	return nil
+}
+
+func callbackUpdate(data string) *telemux.Update {
+	return &telemux.Update{Update: tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		Data:    data,
+		From:    &tgbotapi.User{ID: 1},
+		Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 1}},
+	}}}
+}
+
+func TestButtonRegistrySurvivesRestart(t *testing.T) {
+	storage := memorykeyvalue.InitMemoryKeyValue[ButtonRecord]()
+	registry := NewButtonRegistry(storage, time.Hour)
+	if _, err := registry.Button("Продлить", "renew", "42"); err == nil {
+		t.Errorf("кнопка с незарегистрированным обработчиком должна давать ошибку")
+	}
+	registry.Handle("renew", func(u *telemux.Update, payload string) {})
+	button, err := registry.Button("Продлить", "renew", "42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*button.CallbackData) > 64 {
+		t.Errorf("callback data длиннее 64 байт: %s", *button.CallbackData)
+	}
+
+	// после перезапуска обработчик регистрируется заново под тем же именем
+	var got string
+	restarted := NewButtonRegistry(storage, time.Hour).Handle("renew", func(u *telemux.Update, payload string) {
+		got = payload
+	})
+	if !restarted.Dispatch(callbackUpdate(*button.CallbackData)) || got != "42" {
+		t.Errorf("кнопка должна работать после перезапуска, payload %q", got)
+	}
+	if restarted.Dispatch(callbackUpdate("unknown")) {
+		t.Errorf("неизвестная кнопка не должна обрабатываться")
+	}
+}
+
+func TestButtonRegistryExpiration(t *testing.T) {
+	storage := make(recordsWithoutTTL)
+	calls := 0
+	registry := NewButtonRegistry(storage, time.Hour).Handle("renew", func(u *telemux.Update, payload string) {
+		calls++
+	})
+	button, _ := registry.Button("Продлить", "renew", "42")
+	key := *button.CallbackData
+	record := storage[key]
+	record.ExpiresAt = time.Now().Add(-time.Minute)
+	storage[key] = record
+	if registry.Dispatch(callbackUpdate(key)) || calls != 0 {
+		t.Errorf("истекшая кнопка не должна срабатывать")
+	}
+	if _, ok := storage[key]; ok {
+		t.Errorf("истекшая кнопка должна удаляться при нажатии")
+	}
+
+	memory := memorykeyvalue.InitMemoryKeyValue[ButtonRecord]()
+	registry.SetStorage(memory)
+	_, _ = registry.Button("Продлить", "renew", "43")
+	if removed := registry.CollectGarbage(time.Now().Add(2 * time.Hour)); removed != 1 {
+		t.Errorf("ожидали удаление одной истекшей кнопки, удалено %d", removed)
+	}
+}
+
+func TestCollectButtons(t *testing.T) {
+	button := MakeButton("Старая", "", nil)
+	globalButtonsMutex.Lock()
+	old := globalUsefulContentButtons[*button.CallbackData]
+	old.CreatedAt = time.Now().Add(-48 * time.Hour)
+	globalUsefulContentButtons[*button.CallbackData] = old
+	globalButtonsMutex.Unlock()
+	fresh := MakeButton("Новая", "", nil)
+
+	CollectButtons(24 * time.Hour)
+	if _, ok := getButton(*button.CallbackData); ok {
+		t.Errorf("старая кнопка должна быть удалена")
+	}
+	if _, ok := getButton(*fresh.CallbackData); !ok {
+		t.Errorf("новая кнопка должна остаться")
+	}
+}
@@ -0,0 +1,111 @@
+package telegram
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+)
+
+// encodeCallback - компактная двоичная запись параметров кнопки: поля структуры по порядку,
+// целые числа - varint, строки - длина и байты. Имена полей не записываются
+func encodeCallback(value any) ([]byte, error) {
+	return appendValue(nil, reflect.ValueOf(value))
+}
+
+func appendValue(data []byte, value reflect.Value) ([]byte, error) {
+	switch value.Kind() {
+	case reflect.Bool:
+		if value.Bool() {
+			return append(data, 1), nil
+		}
+		return append(data, 0), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.AppendVarint(data, value.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return binary.AppendUvarint(data, value.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return binary.BigEndian.AppendUint64(data, math.Float64bits(value.Float())), nil
+	case reflect.String:
+		data = binary.AppendUvarint(data, uint64(value.Len()))
+		return append(data, value.String()...), nil
+	case reflect.Struct:
+		var err error
+		for i := 0; i < value.NumField(); i++ {
+			if !value.Type().Field(i).IsExported() {
+				continue
+			}
+			if data, err = appendValue(data, value.Field(i)); err != nil {
+				return nil, err
+			}
+		}
+		return data, nil
+	default:
+		return nil, errors.New("callback parameter type is not supported: " + value.Type().String())
+	}
+}
+
+// decodeCallback - разбирает параметры, записанные encodeCallback, в target
+func decodeCallback(data []byte, target any) error {
+	rest, err := readValue(data, reflect.ValueOf(target).Elem())
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return errors.New("callback data has extra bytes")
+	}
+	return nil
+}
+
+var errShortCallback = errors.New("callback data is too short")
+
+func readValue(data []byte, value reflect.Value) ([]byte, error) {
+	switch value.Kind() {
+	case reflect.Bool:
+		if len(data) == 0 {
+			return nil, errShortCallback
+		}
+		value.SetBool(data[0] == 1)
+		return data[1:], nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number, n := binary.Varint(data)
+		if n <= 0 {
+			return nil, errShortCallback
+		}
+		value.SetInt(number)
+		return data[n:], nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		number, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errShortCallback
+		}
+		value.SetUint(number)
+		return data[n:], nil
+	case reflect.Float32, reflect.Float64:
+		if len(data) < 8 {
+			return nil, errShortCallback
+		}
+		value.SetFloat(math.Float64frombits(binary.BigEndian.Uint64(data)))
+		return data[8:], nil
+	case reflect.String:
+		length, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < length {
+			return nil, errShortCallback
+		}
+		value.SetString(string(data[n : n+int(length)]))
+		return data[n+int(length):], nil
+	case reflect.Struct:
+		var err error
+		for i := 0; i < value.NumField(); i++ {
+			if !value.Type().Field(i).IsExported() {
+				continue
+			}
+			if data, err = readValue(data, value.Field(i)); err != nil {
+				return nil, err
+			}
+		}
+		return data, nil
+	default:
+		return nil, errors.New("callback parameter type is not supported: " + value.Type().String())
+	}
+}
@@ -0,0 +1,182 @@
+package telegram
+
+import (
+	"encoding/base64"
+	"errors"
+	"github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log"
+	"main/internal/database/keyvalue"
+	"main/internal/hash"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// callbackDataLimit - ограничение Telegram на длину callback data
+	callbackDataLimit = 64
+	// callbackSeparator - отделяет маршрут от параметров: "tariff.CNQB"
+	callbackSeparator = "."
+	// callbackStoredPrefix - параметры не влезли в 64 байта и лежат в хранилище: "#<md5>"
+	callbackStoredPrefix = "#"
+)
+
+// CallbackDispatcher - обработчик нажатий inline-кнопок. false - кнопка не его
+type CallbackDispatcher interface {
+	Dispatch(u *telemux.Update) bool
+}
+
+type callbackRoute func(u *telemux.Update, data []byte) error
+
+// CallbackRouter - маршрутизация нажатий по имени маршрута с типизированными параметрами.
+// Маршрут и параметры кодируются в callback data, большие параметры хранятся на сервере
+type CallbackRouter struct {
+	mutex   sync.RWMutex
+	routes  map[string]callbackRoute
+	storage keyvalue.KeyValue[string]
+	// signer - подпись кнопок бота; nil - подпись выключена
+	signer *CallbackSigner
+}
+
+func NewCallbackRouter(storage keyvalue.KeyValue[string]) *CallbackRouter {
+	return &CallbackRouter{routes: make(map[string]callbackRoute), storage: storage}
+}
+
+// SetSigner - подписывать кнопки бота и отклонять нажатия с неверной подписью. nil - выключает
+func (r *CallbackRouter) SetSigner(signer *CallbackSigner) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.signer = signer
+}
+
+// Signer - подпись кнопок бота; nil - подпись выключена
+func (r *CallbackRouter) Signer() *CallbackSigner {
+	if r == nil {
+		return nil
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.signer
+}
+
+// SignKeyboard - подписывает клавиатуру для чата chatID и пользователя userID, если подпись включена
+func (r *CallbackRouter) SignKeyboard(markup tgbotapi.InlineKeyboardMarkup, chatID, userID int64) tgbotapi.InlineKeyboardMarkup {
+	signer := r.Signer()
+	if signer == nil {
+		return markup
+	}
+	return signer.SignKeyboard(markup, chatID, userID)
+}
+
+// Route - регистрирует обработчик маршрута name с параметрами типа Params
+func Route[Params any](router *CallbackRouter, name string, handler func(u *telemux.Update, params Params)) {
+	if strings.Contains(name, callbackSeparator) || strings.HasPrefix(name, callbackStoredPrefix) || len(name) == 0 {
+		panic("invalid callback route name: " + name)
+	}
+	router.mutex.Lock()
+	defer router.mutex.Unlock()
+	router.routes[name] = func(u *telemux.Update, data []byte) error {
+		var params Params
+		if err := decodeCallback(data, &params); err != nil {
+			return err
+		}
+		handler(u, params)
+		return nil
+	}
+}
+
+// Callback - кнопка маршрута name с параметрами params
+func Callback[Params any](router *CallbackRouter, text, name string, params Params) (tgbotapi.InlineKeyboardButton, error) {
+	data, err := router.encode(name, params)
+	if err != nil {
+		return tgbotapi.InlineKeyboardButton{}, err
+	}
+	return tgbotapi.NewInlineKeyboardButtonData(text, data), nil
+}
+
+func (r *CallbackRouter) encode(name string, params any) (string, error) {
+	r.mutex.RLock()
+	_, ok := r.routes[name]
+	storage := r.storage
+	r.mutex.RUnlock()
+	if !ok {
+		return "", errors.New("unknown callback route " + name)
+	}
+	raw, err := encodeCallback(params)
+	if err != nil {
+		return "", err
+	}
+	data := name + callbackSeparator + base64.RawURLEncoding.EncodeToString(raw)
+	limit := callbackDataLimit
+	if r.Signer() != nil {
+		limit -= signatureOverhead
+	}
+	if len(data) <= limit {
+		return data, nil
+	}
+	if storage == nil {
+		return "", errors.New("callback data exceeds 64 bytes and no storage configured")
+	}
+	key := hash.MD5(data)
+	if err := storage.Set(key, data, DefaultButtonTTL); err != nil {
+		return "", err
+	}
+	return callbackStoredPrefix + key, nil
+}
+
+// Dispatch - вызывает обработчик маршрута из callback data
+func (r *CallbackRouter) Dispatch(u *telemux.Update) bool {
+	if u.CallbackQuery == nil {
+		return false
+	}
+	r.mutex.RLock()
+	storage := r.storage
+	r.mutex.RUnlock()
+	data := u.CallbackQuery.Data
+	if strings.HasPrefix(data, callbackStoredPrefix) {
+		if storage == nil {
+			return false
+		}
+		stored, err := storage.Get(strings.TrimPrefix(data, callbackStoredPrefix))
+		if err != nil {
+			return false
+		}
+		data = *stored
+	}
+	name, encoded, ok := strings.Cut(data, callbackSeparator)
+	if !ok {
+		return false
+	}
+	r.mutex.RLock()
+	route, ok := r.routes[name]
+	r.mutex.RUnlock()
+	if !ok {
+		return false
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err == nil {
+		err = route(u, raw)
+	}
+	if err != nil {
+		log.Println("callback route "+name+":", err)
+	}
+	return true
+}
+
+// SetStorage - хранилище параметров, не поместившихся в callback data
+func (r *CallbackRouter) SetStorage(storage keyvalue.KeyValue[string]) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.storage = storage
+}
+
+// CollectGarbage - удаляет истекшие параметры, если хранилище само этого не делает
+func (r *CallbackRouter) CollectGarbage(now time.Time) int {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if collector, ok := r.storage.(interface{ CollectGarbage(time.Time) int }); ok {
+		return collector.CollectGarbage(now)
+	}
+	return 0
+}
@@ -0,0 +1,83 @@
+package telegram
+
+import (
+	"github.com/and3rson/telemux/v2"
+	"main/internal/database/keyvalue/memorykeyvalue"
+	"strings"
+	"testing"
+)
+
+type tariffParams struct {
+	TariffID int
+	Page     uint8
+	Promo    string
+	Yearly   bool
+	Price    float64
+	internal int
+}
+
+func TestCallbackCodec(t *testing.T) {
+	params := tariffParams{TariffID: -12, Page: 3, Promo: "ЛЕТО", Yearly: true, Price: 4800.5}
+	raw, err := encodeCallback(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded tariffParams
+	if err := decodeCallback(raw, &decoded); err != nil || decoded != params {
+		t.Errorf("ожидали %+v, получили %+v (%v)", params, decoded, err)
+	}
+	if err := decodeCallback(raw[:len(raw)-1], &decoded); err == nil {
+		t.Errorf("обрезанные данные должны давать ошибку")
+	}
+	if _, err := encodeCallback(struct{ IDs []int }{}); err == nil {
+		t.Errorf("срезы не поддерживаются и должны давать ошибку")
+	}
+}
+
+func TestCallbackRouter(t *testing.T) {
+	router := NewCallbackRouter(memorykeyvalue.InitMemoryKeyValue[string]())
+	var got tariffParams
+	Route(router, "tariff", func(u *telemux.Update, params tariffParams) {
+		got = params
+	})
+	var page int
+	Route(router, "page", func(u *telemux.Update, params int) {
+		page = params
+	})
+
+	button, err := Callback(router, "Месяц", "tariff", tariffParams{TariffID: 7, Page: 1, Promo: "LETO"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := *button.CallbackData
+	if !strings.HasPrefix(data, "tariff.") || len(data) > callbackDataLimit {
+		t.Errorf("неверная callback data: %s", data)
+	}
+	if !router.Dispatch(callbackUpdate(data)) || got.TariffID != 7 || got.Promo != "LETO" {
+		t.Errorf("обработчик получил %+v", got)
+	}
+
+	button, _ = Callback(router, "Далее", "page", 5)
+	if !router.Dispatch(callbackUpdate(*button.CallbackData)) || page != 5 {
+		t.Errorf("ожидали страницу 5, получили %d", page)
+	}
+
+	long := tariffParams{TariffID: 8, Promo: strings.Repeat("ПРОМОКОД", 10)}
+	button, err = Callback(router, "Длинный", "tariff", long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := *button.CallbackData; !strings.HasPrefix(data, callbackStoredPrefix) || len(data) > callbackDataLimit {
+		t.Errorf("большие параметры должны храниться на сервере: %s", data)
+	}
+	if !router.Dispatch(callbackUpdate(*button.CallbackData)) || got != long {
+		t.Errorf("большие параметры не восстановлены: %+v", got)
+	}
+
+	if _, err := Callback(router, "?", "unknown", 1); err == nil {
+		t.Errorf("неизвестный маршрут должен давать ошибку")
+	}
+	if router.Dispatch(callbackUpdate("0123456789abcdef0123456789abcdef")) {
+		t.Errorf("ключи MakeButton не должны перехватываться маршрутизатором")
+	}
+}
@@ -0,0 +1,127 @@
+package telegram
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"strings"
+	"time"
+)
+
+const (
+	signatureSeparator = "~"
+	signatureMacSize   = 12
+)
+
+var (
+	// signatureOverhead - сколько байт callback data занимает подпись: разделитель и base64 от срока и MAC
+	signatureOverhead = len(signatureSeparator) + base64.RawURLEncoding.EncodedLen(4+signatureMacSize)
+
+	ErrCallbackUnsigned = errors.New("callback data is not signed")
+	ErrCallbackForged   = errors.New("callback signature mismatch")
+	ErrCallbackExpired  = errors.New("callback data expired")
+)
+
+// CallbackSigner - подпись callback data секретом сервера с привязкой к чату
+// и, при необходимости, к пользователю. Подделанные и устаревшие нажатия отклоняются
+type CallbackSigner struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewCallbackSigner - ttl = 0 - подпись не истекает
+func NewCallbackSigner(secret string, ttl time.Duration) *CallbackSigner {
+	return &CallbackSigner{secret: []byte(secret), ttl: ttl}
+}
+
+// Sign - подписывает data для чата chatID. userID = 0 - кнопку может нажать любой участник чата
+func (s *CallbackSigner) Sign(data string, chatID, userID int64) string {
+	var expiresAt uint32
+	if s.ttl > 0 {
+		expiresAt = uint32(time.Now().Add(s.ttl).Unix())
+	}
+	return s.signUntil(data, chatID, userID, expiresAt)
+}
+
+func (s *CallbackSigner) signUntil(data string, chatID, userID int64, expiresAt uint32) string {
+	signature := binary.BigEndian.AppendUint32(nil, expiresAt)
+	signature = append(signature, s.mac(data, chatID, userID, expiresAt)...)
+	return data + signatureSeparator + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// Verify - проверяет подпись нажатия пользователем userID в чате chatID и возвращает исходные данные
+func (s *CallbackSigner) Verify(signed string, chatID, userID int64) (string, error) {
+	index := strings.LastIndex(signed, signatureSeparator)
+	if index < 0 {
+		return "", ErrCallbackUnsigned
+	}
+	data := signed[:index]
+	signature, err := base64.RawURLEncoding.DecodeString(signed[index+len(signatureSeparator):])
+	if err != nil || len(signature) != 4+signatureMacSize {
+		return "", ErrCallbackForged
+	}
+	expiresAt := binary.BigEndian.Uint32(signature)
+	mac := signature[4:]
+	if !hmac.Equal(mac, s.mac(data, chatID, userID, expiresAt)) &&
+		!hmac.Equal(mac, s.mac(data, chatID, 0, expiresAt)) {
+		return "", ErrCallbackForged
+	}
+	if expiresAt != 0 && time.Now().Unix() > int64(expiresAt) {
+		return "", ErrCallbackExpired
+	}
+	return data, nil
+}
+
+// SignKeyboard - подписывает все callback-кнопки клавиатуры для чата chatID
+func (s *CallbackSigner) SignKeyboard(markup tgbotapi.InlineKeyboardMarkup, chatID, userID int64) tgbotapi.InlineKeyboardMarkup {
+	signed := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: make([][]tgbotapi.InlineKeyboardButton, len(markup.InlineKeyboard))}
+	for i, row := range markup.InlineKeyboard {
+		signed.InlineKeyboard[i] = make([]tgbotapi.InlineKeyboardButton, len(row))
+		for j, button := range row {
+			if button.CallbackData != nil {
+				data := s.Sign(*button.CallbackData, chatID, userID)
+				button.CallbackData = &data
+			}
+			signed.InlineKeyboard[i][j] = button
+		}
+	}
+	return signed
+}
+
+func (s *CallbackSigner) mac(data string, chatID, userID int64, expiresAt uint32) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	header := binary.BigEndian.AppendUint64(nil, uint64(chatID))
+	header = binary.BigEndian.AppendUint64(header, uint64(userID))
+	header = binary.BigEndian.AppendUint32(header, expiresAt)
+	mac.Write(header)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)[:signatureMacSize]
+}
+
+// SignKeyboard - подписывает клавиатуру подписью бота, обрабатывающего u (см. WithCallbacks),
+// для чата chatID и пользователя userID. userID = 0 - кнопку может нажать любой участник чата
+func SignKeyboard(u *telemux.Update, markup tgbotapi.InlineKeyboardMarkup, chatID, userID int64) tgbotapi.InlineKeyboardMarkup {
+	router, _ := contextValue(u, contextCallbacks).(*CallbackRouter)
+	return router.SignKeyboard(markup, chatID, userID)
+}
+
+// verifyCallback - проверяет подпись нажатия и заменяет callback data исходными данными
+func verifyCallback(u *telemux.Update, signer *CallbackSigner) error {
+	if signer == nil {
+		return nil
+	}
+	query := u.CallbackQuery
+	if query.Message == nil || query.From == nil {
+		return ErrCallbackForged
+	}
+	data, err := signer.Verify(query.Data, query.Message.Chat.ID, query.From.ID)
+	if err != nil {
+		return err
+	}
+	query.Data = data
+	return nil
+}
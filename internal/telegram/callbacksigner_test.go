@@ -0,0 +1,117 @@
+package telegram
+
+import (
+	"errors"
+	"github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"main/internal/database/keyvalue/memorykeyvalue"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCallbackSigner(t *testing.T) {
+	signer := NewCallbackSigner("secret", time.Hour)
+	data := "0123456789abcdef0123456789abcdef"
+	signed := signer.Sign(data, 10, 0)
+	if len(signed) != len(data)+signatureOverhead || len(signed) > callbackDataLimit {
+		t.Errorf("неверная длина подписанных данных: %d", len(signed))
+	}
+	if got, err := signer.Verify(signed, 10, 77); err != nil || got != data {
+		t.Errorf("подпись для чата должна приниматься от любого пользователя: %q %v", got, err)
+	}
+	if _, err := signer.Verify(signed, 11, 77); !errors.Is(err, ErrCallbackForged) {
+		t.Errorf("подпись другого чата должна отклоняться, получили %v", err)
+	}
+	if _, err := NewCallbackSigner("other", time.Hour).Verify(signed, 10, 77); !errors.Is(err, ErrCallbackForged) {
+		t.Errorf("подпись другим секретом должна отклоняться, получили %v", err)
+	}
+	tampered := strings.Replace(signed, "0123", "3210", 1)
+	if _, err := signer.Verify(tampered, 10, 77); !errors.Is(err, ErrCallbackForged) {
+		t.Errorf("измененные данные должны отклоняться, получили %v", err)
+	}
+	if _, err := signer.Verify(data, 10, 77); !errors.Is(err, ErrCallbackUnsigned) {
+		t.Errorf("неподписанные данные должны отклоняться, получили %v", err)
+	}
+
+	personal := signer.Sign(data, 10, 77)
+	if _, err := signer.Verify(personal, 10, 78); !errors.Is(err, ErrCallbackForged) {
+		t.Errorf("кнопка пользователя 77 не должна работать для 78, получили %v", err)
+	}
+
+	expired := signer.signUntil(data, 10, 0, uint32(time.Now().Add(-time.Minute).Unix()))
+	if _, err := signer.Verify(expired, 10, 77); !errors.Is(err, ErrCallbackExpired) {
+		t.Errorf("устаревшая подпись должна отклоняться, получили %v", err)
+	}
+	forever := NewCallbackSigner("secret", 0).Sign(data, 10, 0)
+	if _, err := signer.Verify(forever, 10, 77); err != nil {
+		t.Errorf("подпись без срока не должна истекать: %v", err)
+	}
+}
+
+func TestSignedCallbackAnalyser(t *testing.T) {
+	router := NewCallbackRouter(memorykeyvalue.InitMemoryKeyValue[string]())
+	router.SetSigner(NewCallbackSigner("secret", time.Hour))
+	var got string
+	Route(router, "promo", func(u *telemux.Update, code string) {
+		got = code
+	})
+	button, err := Callback(router, "Промокод", "promo", strings.Repeat("A", 30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// клавиатура отправлена пользователю 1 в ответ на его сообщение
+	u := &telemux.Update{Update: tgbotapi.Update{Message: &tgbotapi.Message{From: &tgbotapi.User{ID: 1}}}}
+	WithCallbacks(router)(SimpleActionStruct{SimpleAction: func(u *telemux.Update) {}}).Action(u)
+	keyboard := SignKeyboard(u, tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(button)), 1, GetUserFromId(u))
+	signed := *keyboard.InlineKeyboard[0][0].CallbackData
+	if len(signed) > callbackDataLimit {
+		t.Errorf("подписанные данные длиннее 64 байт: %d", len(signed))
+	}
+
+	analyser := MakeCallbackAnalyser(router)
+	press := func(data string, chatID, userID int64) {
+		u := callbackUpdate(data)
+		u.CallbackQuery.Message.Chat.ID = chatID
+		u.CallbackQuery.From = &tgbotapi.User{ID: userID}
+		analyser.Action.Action(u)
+	}
+	press(*button.CallbackData, 1, 1)
+	if got != "" {
+		t.Errorf("неподписанное нажатие должно отклоняться")
+	}
+	press(signed, 2, 1)
+	if got != "" {
+		t.Errorf("нажатие из чужого чата должно отклоняться")
+	}
+	press(signed, 1, 3)
+	if got != "" {
+		t.Errorf("нажатие другим участником чата должно отклоняться")
+	}
+	press(signed, 1, 1)
+	if got != strings.Repeat("A", 30) {
+		t.Errorf("подписанное нажатие должно обрабатываться, получили %q", got)
+	}
+}
+
+func TestCallbackSecretPerBot(t *testing.T) {
+	first := NewCallbackRouter(memorykeyvalue.InitMemoryKeyValue[string]())
+	second := NewCallbackRouter(memorykeyvalue.InitMemoryKeyValue[string]())
+	first.SetSigner(NewCallbackSigner("first", time.Hour))
+	pressed := 0
+	Route(second, "ping", func(u *telemux.Update, _ int) { pressed++ })
+	button, err := Callback(second, "Пинг", "ping", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// подпись первого бота не включает ее у второго
+	if signed := second.SignKeyboard(tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(button)), 1, 1); *signed.InlineKeyboard[0][0].CallbackData != *button.CallbackData {
+		t.Errorf("кнопки второго бота не должны подписываться")
+	}
+	u := callbackUpdate(*button.CallbackData)
+	u.CallbackQuery.From = &tgbotapi.User{ID: 1}
+	MakeCallbackAnalyser(second).Action.Action(u)
+	if pressed != 1 {
+		t.Errorf("у второго бота подпись выключена, нажатие должно обрабатываться")
+	}
+}
@@ -13,6 +13,15 @@ type TelegramCommand struct {
 	Description string
 	Filter      telemux.FilterFunc
 	Action      Action
+	Middlewares []Middleware
+	// Role - минимальная роль для выполнения команды, пустая - команда доступна всем
+	Role entity.Role
+}
+
+// Require - команда доступна только сотрудникам с ролью role и старше
+func (c TelegramCommand) Require(role entity.Role) TelegramCommand {
+	c.Role = role
+	return c
 }
 
 type TelegramCommands []TelegramCommand
@@ -54,16 +63,37 @@ func MakeFullCommand(name string, description string, filter telemux.FilterFunc,
 }
 
 func MakeButtonAnalyser() TelegramCommand {
+	return MakeCallbackAnalyser(nil)
+}
+
+// MakeCallbackAnalyser - обработка нажатий: router, dispatchers по порядку, затем кнопки MakeButton.
+// Если у router включена подпись, нажатия с неверной или устаревшей подписью отклоняются до обработки
+func MakeCallbackAnalyser(router *CallbackRouter, dispatchers ...CallbackDispatcher) TelegramCommand {
+	if router != nil {
+		dispatchers = append([]CallbackDispatcher{router}, dispatchers...)
+	}
 	return TelegramCommand{
-		"Analyser",
-		"",
-		func(u *telemux.Update) bool {
+		Name:        "Analyser",
+		Description: "",
+		Filter: func(u *telemux.Update) bool {
 			return u.CallbackQuery != nil
 		},
-		SimpleActionStruct{
+		Action: SimpleActionStruct{
 			SimpleAction: func(u *telemux.Update) {
 				if u.CallbackQuery != nil {
-					val, ok := GetGlobalUsefulContentButtons()[u.CallbackQuery.Data]
+					if err := verifyCallback(u, router.Signer()); err != nil {
+						if u.Bot != nil {
+							_, _ = u.Bot.Request(tgbotapi.NewCallbackWithAlert(u.CallbackQuery.ID,
+								"Кнопка устарела или недействительна"))
+						}
+						return
+					}
+					for _, dispatcher := range dispatchers {
+						if dispatcher.Dispatch(u) {
+							return
+						}
+					}
+					val, ok := getButton(u.CallbackQuery.Data)
 					if ok {
 						if len(val.RequestMessage) > 0 {
 							msg := tgbotapi.NewMessage(
@@ -84,12 +114,12 @@ func MakeButtonAnalyser() TelegramCommand {
 
 func MakeUserRequestConfirmed(base entitybase.EntityBase[entity.User]) TelegramCommand {
 	return TelegramCommand{
-		"Request",
-		"",
-		func(u *telemux.Update) bool {
+		Name:        "Request",
+		Description: "",
+		Filter: func(u *telemux.Update) bool {
 			return u.ChatJoinRequest != nil
 		},
-		UserCheckActionStruct{
+		Action: UserCheckActionStruct{
 			Base: base,
 			SimpleAction: func(base entitybase.EntityBase[entity.User], u *telemux.Update) {
 				if base != nil {
@@ -0,0 +1,25 @@
+package telegram
+
+import (
+	"errors"
+	"github.com/and3rson/telemux/v2"
+	"io"
+	"net/http"
+)
+
+// DownloadFile - скачивает файл, присланный боту, по его FileID
+func DownloadFile(u *telemux.Update, fileID string) ([]byte, error) {
+	url, err := u.Bot.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, err
+	}
+	response, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.New("download file: " + response.Status)
+	}
+	return io.ReadAll(response.Body)
+}
@@ -0,0 +1,265 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log/slog"
+	"main/internal/database/entitybase"
+	"main/internal/entity"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Ключи telemux.Update.Context, которые заполняют middleware
+const (
+	contextCommand   = "command"
+	contextUser      = "user"
+	contextCallbacks = "callbacks"
+)
+
+// Middleware - обертка над Action: код до и после обработки, либо прерывание обработки
+type Middleware func(next Action) Action
+
+// Chain - оборачивает action в middlewares, первый middleware выполняется первым
+func Chain(action Action, middlewares ...Middleware) Action {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		action = middlewares[i](action)
+	}
+	return action
+}
+
+// With - команда с дополнительными middleware, выполняемыми после общих
+func (c TelegramCommand) With(middlewares ...Middleware) TelegramCommand {
+	c.Middlewares = append(append([]Middleware{}, c.Middlewares...), middlewares...)
+	return c
+}
+
+// CommandName - имя команды, обрабатывающей обновление
+func CommandName(u *telemux.Update) string {
+	name, _ := contextValue(u, contextCommand).(string)
+	return name
+}
+
+// SetCommandName - запоминает имя команды для middleware
+func SetCommandName(u *telemux.Update, name string) {
+	if u.Context == nil {
+		u.Context = make(telemux.Map)
+	}
+	u.Context[contextCommand] = name
+}
+
+// UserFromContext - пользователь, загруженный LoadUser
+func UserFromContext(u *telemux.Update) (entity.User, bool) {
+	user, ok := contextValue(u, contextUser).(entity.User)
+	return user, ok
+}
+
+func contextValue(u *telemux.Update, key string) interface{} {
+	if u == nil || u.Context == nil {
+		return nil
+	}
+	return u.Context[key]
+}
+
+// WithCallbacks - маршрутизатор кнопок бота для обработчиков: по нему SignKeyboard
+// находит подпись бота, который обрабатывает обновление
+func WithCallbacks(router *CallbackRouter) Middleware {
+	return func(next Action) Action {
+		return SimpleActionStruct{
+			SimpleAction: func(u *telemux.Update) {
+				if u.Context == nil {
+					u.Context = make(telemux.Map)
+				}
+				u.Context[contextCallbacks] = router
+				next.Action(u)
+			},
+		}
+	}
+}
+
+// Recovery - паника в обработчике логируется, а бот продолжает работу.
+// Пользователю отправляется text, если он не пустой
+func Recovery(text string) Middleware {
+	return func(next Action) Action {
+		return SimpleActionStruct{
+			SimpleAction: func(u *telemux.Update) {
+				defer func() {
+					if r := recover(); r != nil {
+						slog.Error("panic in telegram handler",
+							"command", CommandName(u), "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+						if chatID := chatIDFromUpdate(u); len(text) > 0 && chatID != 0 && u.Bot != nil {
+							_, _ = u.Bot.Send(tgbotapi.NewMessage(chatID, text))
+						}
+					}
+				}()
+				next.Action(u)
+			},
+		}
+	}
+}
+
+// Logging - структурированная запись о каждом обновлении
+func Logging(logger *slog.Logger) Middleware {
+	return func(next Action) Action {
+		return SimpleActionStruct{
+			SimpleAction: func(u *telemux.Update) {
+				attributes := []any{"command", CommandName(u)}
+				if u != nil {
+					attributes = append(attributes, "update_id", u.UpdateID, "chat_id", chatIDFromUpdate(u),
+						"user_id", GetUserFromId(u))
+					if u.Message != nil {
+						attributes = append(attributes, "text", u.Message.Text)
+					} else if u.CallbackQuery != nil {
+						attributes = append(attributes, "callback", u.CallbackQuery.Data)
+					}
+				}
+				logger.Info("telegram update", attributes...)
+				next.Action(u)
+			},
+		}
+	}
+}
+
+// Timing - время обработки обновления; дольше slow - предупреждение
+func Timing(logger *slog.Logger, slow time.Duration) Middleware {
+	return func(next Action) Action {
+		return SimpleActionStruct{
+			SimpleAction: func(u *telemux.Update) {
+				start := time.Now()
+				next.Action(u)
+				elapsed := time.Since(start)
+				if slow > 0 && elapsed > slow {
+					logger.Warn("slow telegram handler", "command", CommandName(u), "elapsed", elapsed)
+				} else {
+					logger.Debug("telegram handler", "command", CommandName(u), "elapsed", elapsed)
+				}
+			},
+		}
+	}
+}
+
+// LoadUser - загружает пользователя по Telegram ID в контекст обновления, см. UserFromContext
+func LoadUser(base entitybase.EntityBase[entity.User]) Middleware {
+	return func(next Action) Action {
+		return SimpleActionStruct{
+			SimpleAction: func(u *telemux.Update) {
+				if id := GetUserFromId(u); id != 0 {
+					user, err := base.Get(entity.User{UserTelegramId: id})
+					if err != nil && !errors.Is(err, entitybase.ErrNotFound) {
+						slog.Warn("load telegram user", "user_id", id, "error", err)
+					} else if err == nil {
+						if u.Context == nil {
+							u.Context = make(telemux.Map)
+						}
+						u.Context[contextUser] = user
+					}
+				}
+				next.Action(u)
+			},
+		}
+	}
+}
+
+// Authorize - обработка только если allowed вернул true, иначе пользователю отправляется deniedText
+func Authorize(allowed func(u *telemux.Update) bool, deniedText string) Middleware {
+	return func(next Action) Action {
+		return SimpleActionStruct{
+			SimpleAction: func(u *telemux.Update) {
+				if allowed(u) {
+					next.Action(u)
+					return
+				}
+				slog.Warn("telegram access denied", "command", CommandName(u), "user_id", GetUserFromId(u))
+				if chatID := chatIDFromUpdate(u); len(deniedText) > 0 && chatID != 0 && u.Bot != nil {
+					_, _ = u.Bot.Send(tgbotapi.NewMessage(chatID, deniedText))
+				}
+			},
+		}
+	}
+}
+
+// AllowUsers - проверка для Authorize: пользователь входит в список ids
+func AllowUsers(ids ...int64) func(u *telemux.Update) bool {
+	allowed := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		allowed[id] = true
+	}
+	return func(u *telemux.Update) bool {
+		return allowed[GetUserFromId(u)]
+	}
+}
+
+// RateLimit - не больше limit обновлений за interval от одного пользователя.
+// Лишние обновления отбрасываются, пользователь один раз получает text
+func RateLimit(limit int, interval time.Duration, text string) Middleware {
+	limiter := &rateLimiter{
+		buckets:  make(map[int64]*rateBucket),
+		capacity: float64(limit),
+		rate:     float64(limit) / interval.Seconds(),
+	}
+	return func(next Action) Action {
+		return SimpleActionStruct{
+			SimpleAction: func(u *telemux.Update) {
+				id := GetUserFromId(u)
+				allowed, warn := limiter.allow(id, time.Now())
+				if id == 0 || allowed {
+					next.Action(u)
+					return
+				}
+				if chatID := chatIDFromUpdate(u); warn && len(text) > 0 && chatID != 0 && u.Bot != nil {
+					_, _ = u.Bot.Send(tgbotapi.NewMessage(chatID, text))
+				}
+			},
+		}
+	}
+}
+
+type rateBucket struct {
+	tokens  float64
+	updated time.Time
+	warned  bool
+}
+
+// rateLimiter - token bucket на каждого пользователя
+type rateLimiter struct {
+	mutex    sync.Mutex
+	buckets  map[int64]*rateBucket
+	capacity float64
+	rate     float64 // токенов в секунду
+}
+
+// allow - можно ли обработать обновление; warn - нужно ли предупредить пользователя
+func (l *rateLimiter) allow(id int64, now time.Time) (allowed bool, warn bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	bucket, ok := l.buckets[id]
+	if !ok {
+		if len(l.buckets) > 10000 {
+			l.collect(now)
+		}
+		bucket = &rateBucket{tokens: l.capacity, updated: now}
+		l.buckets[id] = bucket
+	}
+	bucket.tokens = min(l.capacity, bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate)
+	bucket.updated = now
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		bucket.warned = false
+		return true, false
+	}
+	warn = !bucket.warned
+	bucket.warned = true
+	return false, warn
+}
+
+// collect - забывает пользователей, чьи корзины уже полностью восполнились
+func (l *rateLimiter) collect(now time.Time) {
+	for id, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate >= l.capacity {
+			delete(l.buckets, id)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package telegram
+
+import (
+	"github.com/and3rson/telemux/v2"
+	"io"
+	"log/slog"
+	"main/internal/database/entitybase/memoryentitybase"
+	"main/internal/entity"
+	"testing"
+	"time"
+)
+
+func recordingMiddleware(name string, calls *[]string) Middleware {
+	return func(next Action) Action {
+		return SimpleActionStruct{SimpleAction: func(u *telemux.Update) {
+			*calls = append(*calls, name)
+			next.Action(u)
+		}}
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	command := MakeCommandByFilterDefault("start", "", SimpleActionStruct{SimpleAction: func(u *telemux.Update) {
+		calls = append(calls, "action")
+	}}).With(recordingMiddleware("command", &calls))
+	other := command.With(recordingMiddleware("other", &calls))
+	if len(command.Middlewares) != 1 || len(other.Middlewares) != 2 {
+		t.Fatalf("With не должен менять исходную команду")
+	}
+
+	action := Chain(command.Action, append([]Middleware{recordingMiddleware("global", &calls)}, command.Middlewares...)...)
+	action.Action(textUpdate(1, "/start"))
+	expected := []string{"global", "command", "action"}
+	if len(calls) != len(expected) {
+		t.Fatalf("ожидали %v, получили %v", expected, calls)
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Errorf("ожидали %v, получили %v", expected, calls)
+			break
+		}
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	action := Chain(SimpleActionStruct{SimpleAction: func(u *telemux.Update) {
+		panic("boom")
+	}}, Recovery(""), Logging(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	u := textUpdate(1, "/start")
+	SetCommandName(u, "start")
+	action.Action(u)
+	if CommandName(u) != "start" {
+		t.Errorf("имя команды потеряно: %q", CommandName(u))
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	handled := 0
+	action := Chain(SimpleActionStruct{SimpleAction: func(u *telemux.Update) { handled++ }},
+		Authorize(AllowUsers(1, 2), ""))
+	action.Action(textUpdate(1, "/admin"))
+	action.Action(textUpdate(3, "/admin"))
+	if handled != 1 {
+		t.Errorf("должен пройти только разрешенный пользователь, обработано %d", handled)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := &rateLimiter{buckets: make(map[int64]*rateBucket), capacity: 2, rate: 1}
+	now := time.Now()
+	results := []bool{}
+	warnings := 0
+	for i := 0; i < 4; i++ {
+		allowed, warn := limiter.allow(1, now)
+		results = append(results, allowed)
+		if warn {
+			warnings++
+		}
+	}
+	if !results[0] || !results[1] || results[2] || results[3] || warnings != 1 {
+		t.Errorf("ожидали 2 пропуска и одно предупреждение: %v, предупреждений %d", results, warnings)
+	}
+	if allowed, _ := limiter.allow(1, now.Add(time.Second)); !allowed {
+		t.Errorf("через секунду должен восполниться один токен")
+	}
+	if allowed, _ := limiter.allow(2, now); !allowed {
+		t.Errorf("лимит считается отдельно для каждого пользователя")
+	}
+}
+
+func TestLoadUser(t *testing.T) {
+	base := memoryentitybase.InitMemoryEntityBase(entity.User{ID: 50, UserTelegramId: 5, UserName: "alice"})
+	var loaded entity.User
+	var found bool
+	action := Chain(SimpleActionStruct{SimpleAction: func(u *telemux.Update) {
+		loaded, found = UserFromContext(u)
+	}}, LoadUser(base))
+	action.Action(textUpdate(5, "/start"))
+	if !found || loaded.ID != 50 {
+		t.Errorf("пользователь не загружен: %+v", loaded)
+	}
+	action.Action(textUpdate(6, "/start"))
+	if found {
+		t.Errorf("неизвестный пользователь не должен попадать в контекст")
+	}
+}
@@ -0,0 +1,411 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Priority - очередность отправки: транзакционные сообщения уходят раньше рассылок
+type Priority int
+
+const (
+	// PriorityTransactional - ответы пользователю, счета, уведомления об оплате
+	PriorityTransactional Priority = iota
+	// PriorityMarketing - рассылки
+	PriorityMarketing
+	priorities
+)
+
+// Ограничения Telegram на отправку сообщений
+const (
+	globalPerSecond  = 30
+	privatePerSecond = 1
+	groupPerMinute   = 20
+	// outboxMaxAttempts - попыток отправки при сетевых ошибках и ошибках сервера Telegram
+	outboxMaxAttempts = 3
+	// outboxWorkers - одновременных запросов к Telegram
+	outboxWorkers = 8
+)
+
+var ErrOutboxStopped = errors.New("отправка остановлена")
+
+// SendResult - итог отправки сообщения
+type SendResult struct {
+	Message  tgbotapi.Message
+	Err      error
+	Attempts int
+}
+
+// Sender - то, чем Outbox отправляет сообщения, обычно *tgbotapi.BotAPI
+type Sender interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+}
+
+// mediaGroupSender - отправка альбома: Send не умеет разбирать ответ sendMediaGroup
+type mediaGroupSender interface {
+	SendMediaGroup(config tgbotapi.MediaGroupConfig) ([]tgbotapi.Message, error)
+}
+
+type outgoing struct {
+	chattable tgbotapi.Chattable
+	chatID    int64
+	priority  Priority
+	callback  func(SendResult)
+	attempts  int
+	// notBefore - повтор после ошибки не раньше этого времени
+	notBefore time.Time
+}
+
+// Outbox - очередь исходящих сообщений с ограничением скорости: общий лимит бота,
+// лимит на чат и паузы, которые Telegram требует ответом 429 retry_after.
+// Сообщения одного чата отправляются по порядку
+type Outbox struct {
+	sender Sender
+	mutex  sync.Mutex
+	wake   chan struct{}
+	queues [priorities][]*outgoing
+	global *tokenBucket
+	chats  map[int64]*tokenBucket
+	// busy - чаты, сообщение в которые сейчас отправляется
+	busy map[int64]bool
+	// stopped - рассылки больше не принимаются, closed - не принимается ничего
+	stopped bool
+	closed  bool
+	// Unreachable - получатель запретил боту писать ему, см. UnreachableReason.
+	// Задавать до Run
+	Unreachable func(chatID int64, reason string)
+	// now и backoff подменяются в тестах
+	now     func() time.Time
+	backoff func(attempt int) time.Duration
+}
+
+func NewOutbox(sender Sender) *Outbox {
+	return &Outbox{
+		sender:  sender,
+		wake:    make(chan struct{}, 1),
+		global:  newTokenBucket(globalPerSecond, globalPerSecond),
+		chats:   make(map[int64]*tokenBucket),
+		busy:    make(map[int64]bool),
+		now:     time.Now,
+		backoff: func(attempt int) time.Duration { return time.Duration(attempt) * time.Second },
+	}
+}
+
+// Enqueue - ставит сообщение в очередь; callback получает результат и может быть nil
+func (o *Outbox) Enqueue(c tgbotapi.Chattable, priority Priority, callback func(SendResult)) {
+	if priority < 0 || priority >= priorities {
+		priority = PriorityMarketing
+	}
+	item := &outgoing{chattable: c, chatID: chatIDOf(c), priority: priority, callback: callback}
+	o.mutex.Lock()
+	if o.closed || (o.stopped && priority != PriorityTransactional) {
+		o.mutex.Unlock()
+		item.finish(SendResult{Err: ErrOutboxStopped})
+		return
+	}
+	o.queues[priority] = append(o.queues[priority], item)
+	o.mutex.Unlock()
+	o.notify()
+}
+
+// SendWait - отправка через очередь с ожиданием результата
+func (o *Outbox) SendWait(ctx context.Context, c tgbotapi.Chattable, priority Priority) (tgbotapi.Message, error) {
+	results := make(chan SendResult, 1)
+	o.Enqueue(c, priority, func(result SendResult) { results <- result })
+	select {
+	case result := <-results:
+		return result.Message, result.Err
+	case <-ctx.Done():
+		return tgbotapi.Message{}, ctx.Err()
+	}
+}
+
+// Pending - сообщений в очереди по приоритетам
+func (o *Outbox) Pending() [priorities]int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	var pending [priorities]int
+	for priority, queue := range o.queues {
+		pending[priority] = len(queue)
+	}
+	return pending
+}
+
+// Run - отправляет сообщения до отмены ctx. После отмены рассылки отменяются,
+// а транзакционные сообщения дозакрываются, пока очередь не опустеет
+func (o *Outbox) Run(ctx context.Context) error {
+	var wait sync.WaitGroup
+	for i := 0; i < outboxWorkers; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			o.work(ctx)
+		}()
+	}
+	<-ctx.Done()
+	o.mutex.Lock()
+	o.stopped = true
+	marketing := o.queues[PriorityMarketing]
+	o.queues[PriorityMarketing] = nil
+	o.mutex.Unlock()
+	for _, item := range marketing {
+		item.finish(SendResult{Err: ErrOutboxStopped, Attempts: item.attempts})
+	}
+	o.notify()
+	wait.Wait()
+	o.mutex.Lock()
+	o.closed = true
+	o.mutex.Unlock()
+	return nil
+}
+
+func (o *Outbox) work(ctx context.Context) {
+	for {
+		item, delay, done := o.next()
+		if done {
+			return
+		}
+		if item == nil {
+			timer := time.NewTimer(delay)
+			select {
+			case <-o.wake:
+			case <-timer.C:
+			case <-ctx.Done():
+				// после отмены работники дозакрывают очередь, не дожидаясь уведомлений
+				select {
+				case <-o.wake:
+				case <-timer.C:
+				}
+			}
+			timer.Stop()
+			continue
+		}
+		o.send(item)
+	}
+}
+
+// next - первое сообщение, которое можно отправить сейчас, иначе время до ближайшей
+// возможности. done - очередь остановлена и пуста
+func (o *Outbox) next() (item *outgoing, delay time.Duration, done bool) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	now := o.now()
+	delay = time.Second
+	if wait := o.global.wait(now); wait > 0 {
+		return nil, wait, false
+	}
+	empty := true
+	for priority := range o.queues {
+		seen := make(map[int64]bool)
+		for i, candidate := range o.queues[priority] {
+			empty = false
+			chatID := candidate.chatID
+			// сообщения чата уходят строго по очереди; у запросов без чата
+			// (ответы на нажатия кнопок) нет ни порядка, ни лимита чата
+			if chatID != 0 && (seen[chatID] || o.busy[chatID]) {
+				seen[chatID] = true
+				continue
+			}
+			seen[chatID] = true
+			wait := candidate.notBefore.Sub(now)
+			if chatID != 0 {
+				wait = max(wait, o.chatBucket(chatID).wait(now))
+			}
+			if wait > 0 {
+				delay = min(delay, wait)
+				continue
+			}
+			o.global.take(now)
+			if chatID != 0 {
+				o.chatBucket(chatID).take(now)
+				o.busy[chatID] = true
+			}
+			o.queues[priority] = append(o.queues[priority][:i:i], o.queues[priority][i+1:]...)
+			return candidate, 0, false
+		}
+	}
+	if empty && o.stopped {
+		// разбудить остальных работников, чтобы они тоже завершились
+		o.notify()
+		return nil, 0, true
+	}
+	if len(o.chats) > 10000 {
+		o.collect(now)
+	}
+	return nil, delay, false
+}
+
+// send - отправка и разбор ответа: 429 - пауза чата и бота на retry_after,
+// сетевые ошибки и 5xx - повтор, остальные ошибки окончательные
+func (o *Outbox) send(item *outgoing) {
+	item.attempts++
+	message, err := o.deliver(item.chattable)
+	o.mutex.Lock()
+	delete(o.busy, item.chatID)
+	retry := false
+	if err != nil {
+		now := o.now()
+		var apiErr *tgbotapi.Error
+		switch {
+		case errors.As(err, &apiErr) && apiErr.Code == http.StatusTooManyRequests:
+			pause := time.Duration(max(apiErr.RetryAfter, 1)) * time.Second
+			slog.Warn("telegram flood limit", "chat_id", item.chatID, "retry_after", pause)
+			if item.chatID != 0 {
+				o.chatBucket(item.chatID).block(now.Add(pause))
+			}
+			o.global.block(now.Add(pause))
+			// ожидание retry_after не считается попыткой
+			item.attempts--
+			retry = true
+		case errors.As(err, &apiErr) && apiErr.Code < http.StatusInternalServerError:
+		case item.attempts < outboxMaxAttempts:
+			item.notBefore = now.Add(o.backoff(item.attempts))
+			retry = true
+		}
+	}
+	if retry && o.stopped && item.priority != PriorityTransactional {
+		retry = false
+		err = ErrOutboxStopped
+	}
+	if retry {
+		// в начало очереди, чтобы не нарушить порядок сообщений чата
+		o.queues[item.priority] = append([]*outgoing{item}, o.queues[item.priority]...)
+	}
+	o.mutex.Unlock()
+	o.notify()
+	if !retry {
+		if reason := UnreachableReason(err); len(reason) > 0 && item.chatID > 0 && o.Unreachable != nil {
+			o.Unreachable(item.chatID, reason)
+		}
+		item.finish(SendResult{Message: message, Err: err, Attempts: item.attempts})
+	}
+}
+
+// deliver - отправка одного сообщения; для альбома результат - первое сообщение альбома
+func (o *Outbox) deliver(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	group, isGroup := c.(tgbotapi.MediaGroupConfig)
+	groupSender, canSendGroup := o.sender.(mediaGroupSender)
+	if !isGroup || !canSendGroup {
+		return o.sender.Send(c)
+	}
+	messages, err := groupSender.SendMediaGroup(group)
+	if len(messages) == 0 {
+		return tgbotapi.Message{}, err
+	}
+	return messages[0], err
+}
+
+func (o *Outbox) notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// chatBucket - лимит чата: 1 сообщение в секунду в личке, 20 в минуту в группе
+func (o *Outbox) chatBucket(chatID int64) *tokenBucket {
+	bucket, ok := o.chats[chatID]
+	if !ok {
+		if chatID < 0 {
+			bucket = newTokenBucket(1, groupPerMinute/60.0)
+		} else {
+			bucket = newTokenBucket(1, privatePerSecond)
+		}
+		o.chats[chatID] = bucket
+	}
+	return bucket
+}
+
+// collect - забывает чаты, лимит которых полностью восстановился
+func (o *Outbox) collect(now time.Time) {
+	for chatID, bucket := range o.chats {
+		if !o.busy[chatID] && bucket.full(now) {
+			delete(o.chats, chatID)
+		}
+	}
+}
+
+func (item *outgoing) finish(result SendResult) {
+	if result.Err != nil && !errors.Is(result.Err, ErrOutboxStopped) {
+		slog.Warn("telegram send failed", "chat_id", item.chatID, "attempts", result.Attempts, "error", result.Err)
+	}
+	if item.callback != nil {
+		item.callback(result)
+	}
+}
+
+// chatIDOf - чат получателя из конфигурации сообщения: у всех методов отправки
+// и редактирования есть поле ChatID. 0 - чат неизвестен, действует только общий лимит
+func chatIDOf(c tgbotapi.Chattable) int64 {
+	value := reflect.ValueOf(c)
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return 0
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return 0
+	}
+	field := value.FieldByName("ChatID")
+	if !field.IsValid() || field.Kind() != reflect.Int64 {
+		return 0
+	}
+	return field.Int()
+}
+
+// tokenBucket - capacity сообщений подряд, затем rate сообщений в секунду
+type tokenBucket struct {
+	tokens       float64
+	capacity     float64
+	rate         float64
+	updated      time.Time
+	blockedUntil time.Time
+}
+
+func newTokenBucket(capacity, rate float64) *tokenBucket {
+	return &tokenBucket{tokens: capacity, capacity: capacity, rate: rate}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if !b.updated.IsZero() {
+		b.tokens = min(b.capacity, b.tokens+now.Sub(b.updated).Seconds()*b.rate)
+	}
+	b.updated = now
+}
+
+// wait - сколько ждать до следующего сообщения, 0 - можно сейчас
+func (b *tokenBucket) wait(now time.Time) time.Duration {
+	if now.Before(b.blockedUntil) {
+		return b.blockedUntil.Sub(now)
+	}
+	b.refill(now)
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) take(now time.Time) {
+	b.refill(now)
+	b.tokens--
+}
+
+// block - пауза до until по требованию Telegram; после паузы можно отправить одно сообщение
+func (b *tokenBucket) block(until time.Time) {
+	if until.After(b.blockedUntil) {
+		b.blockedUntil = until
+	}
+	b.tokens = min(b.capacity, 1)
+	b.updated = b.blockedUntil
+}
+
+func (b *tokenBucket) full(now time.Time) bool {
+	return !now.Before(b.blockedUntil) && b.tokens+now.Sub(b.updated).Seconds()*b.rate >= b.capacity
+}
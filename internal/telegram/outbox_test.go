@@ -0,0 +1,212 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"main/internal/entity"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSender - записывает отправленные сообщения, ответы задаются по очереди
+type fakeSender struct {
+	mutex   sync.Mutex
+	sent    []int64
+	replies []error
+}
+
+func (f *fakeSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	var err error
+	if len(f.replies) > 0 {
+		err, f.replies = f.replies[0], f.replies[1:]
+	}
+	chatID := chatIDOf(c)
+	if err == nil {
+		f.sent = append(f.sent, chatID)
+	}
+	return tgbotapi.Message{Chat: &tgbotapi.Chat{ID: chatID}}, err
+}
+
+func newTestOutbox(sender Sender, now *time.Time) *Outbox {
+	outbox := NewOutbox(sender)
+	outbox.now = func() time.Time { return *now }
+	outbox.backoff = func(int) time.Duration { return time.Second }
+	return outbox
+}
+
+func TestChatIDOf(t *testing.T) {
+	if id := chatIDOf(tgbotapi.NewMessage(5, "text")); id != 5 {
+		t.Errorf("сообщение: ожидали 5, получили %d", id)
+	}
+	if id := chatIDOf(tgbotapi.NewEditMessageText(-7, 1, "text")); id != -7 {
+		t.Errorf("редактирование: ожидали -7, получили %d", id)
+	}
+	if id := chatIDOf(tgbotapi.NewPhoto(9, tgbotapi.FileID("x"))); id != 9 {
+		t.Errorf("фото: ожидали 9, получили %d", id)
+	}
+}
+
+func TestOutboxPerChatLimitAndPriority(t *testing.T) {
+	now := time.Unix(1000, 0)
+	outbox := newTestOutbox(&fakeSender{}, &now)
+	outbox.Enqueue(tgbotapi.NewMessage(1, "рассылка"), PriorityMarketing, nil)
+	outbox.Enqueue(tgbotapi.NewMessage(2, "ответ"), PriorityTransactional, nil)
+	outbox.Enqueue(tgbotapi.NewMessage(2, "второй ответ"), PriorityTransactional, nil)
+
+	order := []int64{}
+	for {
+		item, _, _ := outbox.next()
+		if item == nil {
+			break
+		}
+		order = append(order, item.chatID)
+		delete(outbox.busy, item.chatID)
+	}
+	if len(order) != 2 || order[0] != 2 || order[1] != 1 {
+		t.Fatalf("сначала транзакционное, затем рассылка, второй ответ ждет лимита чата: %v", order)
+	}
+	_, delay, _ := outbox.next()
+	if delay <= 0 || delay > time.Second {
+		t.Errorf("второе сообщение в чат должно ждать до секунды, ждем %v", delay)
+	}
+	now = now.Add(time.Second)
+	if item, _, _ := outbox.next(); item == nil || item.chatID != 2 {
+		t.Errorf("через секунду второй ответ должен уйти")
+	}
+}
+
+func TestOutboxWithoutChat(t *testing.T) {
+	now := time.Unix(1000, 0)
+	outbox := newTestOutbox(&fakeSender{}, &now)
+	for _, id := range []string{"1", "2", "3"} {
+		outbox.Enqueue(tgbotapi.NewCallback(id, "Готово"), PriorityTransactional, nil)
+	}
+	// ответы на нажатия не ждут друг друга: ни лимита чата 0, ни очереди за отправляемым
+	for i := 0; i < 3; i++ {
+		if item, delay, _ := outbox.next(); item == nil {
+			t.Fatalf("ответ %d ждет %v", i+1, delay)
+		}
+	}
+	if len(outbox.busy) != 0 || len(outbox.chats) != 0 {
+		t.Errorf("для запросов без чата не должно быть состояния чата: %v %v", outbox.busy, outbox.chats)
+	}
+}
+
+func TestOutboxGroupLimit(t *testing.T) {
+	now := time.Unix(1000, 0)
+	outbox := newTestOutbox(&fakeSender{}, &now)
+	for i := 0; i < 2; i++ {
+		outbox.Enqueue(tgbotapi.NewMessage(-100, "группа"), PriorityMarketing, nil)
+	}
+	item, _, _ := outbox.next()
+	delete(outbox.busy, item.chatID)
+	now = now.Add(time.Second)
+	if item, _, _ := outbox.next(); item != nil {
+		t.Errorf("в группу не больше 20 сообщений в минуту")
+	}
+	now = now.Add(2 * time.Second)
+	if item, _, _ := outbox.next(); item == nil {
+		t.Errorf("через 3 секунды сообщение в группу должно уйти")
+	}
+}
+
+func TestOutboxRetryAfter(t *testing.T) {
+	now := time.Unix(1000, 0)
+	sender := &fakeSender{replies: []error{
+		&tgbotapi.Error{Code: 429, Message: "Too Many Requests",
+			ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 5}},
+	}}
+	outbox := newTestOutbox(sender, &now)
+	var result *SendResult
+	outbox.Enqueue(tgbotapi.NewMessage(1, "счет"), PriorityTransactional, func(r SendResult) { result = &r })
+	outbox.Enqueue(tgbotapi.NewMessage(3, "другой чат"), PriorityTransactional, nil)
+
+	item, _, _ := outbox.next()
+	outbox.send(item)
+	if result != nil {
+		t.Fatalf("после 429 сообщение должно быть повторено, а не завершено")
+	}
+	if item, delay, _ := outbox.next(); item != nil || delay < 4*time.Second {
+		t.Fatalf("retry_after останавливает всю отправку бота, ждем %v", delay)
+	}
+	now = now.Add(5 * time.Second)
+	item, _, _ = outbox.next()
+	if item == nil || item.chatID != 1 {
+		t.Fatalf("после паузы первым повторяется отложенное сообщение")
+	}
+	outbox.send(item)
+	if result == nil || result.Err != nil || result.Attempts != 1 {
+		t.Errorf("ожидали успешную отправку с одной попыткой: %+v", result)
+	}
+}
+
+func TestOutboxPermanentAndTransientErrors(t *testing.T) {
+	now := time.Unix(1000, 0)
+	sender := &fakeSender{replies: []error{
+		&tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"},
+		errors.New("connection reset"),
+	}}
+	outbox := newTestOutbox(sender, &now)
+	unreachable := map[int64]string{}
+	outbox.Unreachable = func(chatID int64, reason string) { unreachable[chatID] = reason }
+	results := map[int64]SendResult{}
+	callback := func(chatID int64) func(SendResult) {
+		return func(r SendResult) { results[chatID] = r }
+	}
+	outbox.Enqueue(tgbotapi.NewMessage(1, "a"), PriorityMarketing, callback(1))
+	outbox.Enqueue(tgbotapi.NewMessage(2, "b"), PriorityMarketing, callback(2))
+	for i := 0; i < 2; i++ {
+		item, _, _ := outbox.next()
+		outbox.send(item)
+	}
+	if r, ok := results[1]; !ok || r.Err == nil || r.Attempts != 1 {
+		t.Errorf("403 не повторяется: %+v", r)
+	}
+	if _, ok := results[2]; ok {
+		t.Fatalf("сетевая ошибка должна повторяться")
+	}
+	now = now.Add(time.Second)
+	item, _, _ := outbox.next()
+	outbox.send(item)
+	if r := results[2]; r.Err != nil || r.Attempts != 2 {
+		t.Errorf("ожидали успех со второй попытки: %+v", r)
+	}
+	if len(unreachable) != 1 || unreachable[1] != entity.UnreachableBlocked {
+		t.Errorf("заблокировавший бота получатель должен отмечаться недоступным: %v", unreachable)
+	}
+}
+
+func TestOutboxRun(t *testing.T) {
+	sender := &fakeSender{}
+	outbox := NewOutbox(sender)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		_ = outbox.Run(ctx)
+		close(stopped)
+	}()
+	message, err := outbox.SendWait(context.Background(), tgbotapi.NewMessage(4, "привет"), PriorityTransactional)
+	if err != nil || message.Chat.ID != 4 {
+		t.Fatalf("ожидали отправку в чат 4: %v", err)
+	}
+	// следующее сообщение в тот же чат ждет лимита, остановка его отменяет
+	var result SendResult
+	done := make(chan struct{})
+	outbox.Enqueue(tgbotapi.NewMessage(4, "рассылка"), PriorityMarketing, func(r SendResult) {
+		result = r
+		close(done)
+	})
+	cancel()
+	<-stopped
+	<-done
+	if !errors.Is(result.Err, ErrOutboxStopped) {
+		t.Errorf("рассылка после остановки должна отменяться, получили %v", result.Err)
+	}
+	if _, err := outbox.SendWait(context.Background(), tgbotapi.NewMessage(5, "поздно"), PriorityTransactional); !errors.Is(err, ErrOutboxStopped) {
+		t.Errorf("после остановки сообщения не принимаются, получили %v", err)
+	}
+}
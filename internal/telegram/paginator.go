@@ -0,0 +1,204 @@
+package telegram
+
+import (
+	"github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log"
+	"main/internal/entity/mapper"
+	"strconv"
+	"strings"
+)
+
+// PageAction - кнопка действия над элементом списка
+type PageAction[Item any] struct {
+	Text   func(item Item) string
+	Handle func(u *telemux.Update, item Item)
+}
+
+// Paginator - постраничный вывод списка с кнопками ◀ ▶, счетчиком страниц
+// и кнопками действий для каждого элемента. Листание редактирует сообщение на месте
+type Paginator[Item any] struct {
+	Name     string
+	Title    string
+	PageSize int
+	// Load - актуальный список, загружается при каждом показе страницы
+	Load func(u *telemux.Update) ([]Item, error)
+	// Format - текст страницы, по умолчанию mapper.TelegramFormatMarkdown
+	Format func(items []Item) (string, error)
+	// Key - идентификатор элемента для кнопок действий, например ID
+	Key     func(item Item) string
+	Actions []PageAction[Item]
+
+	router *CallbackRouter
+}
+
+type pageParams struct {
+	Page int
+}
+
+type pageActionParams struct {
+	Action int
+	Page   int
+	Key    string
+}
+
+// NewPaginator - регистрирует маршруты листания и действий с именем name в router
+func NewPaginator[Item any](router *CallbackRouter, name string, pageSize int, load func(u *telemux.Update) ([]Item, error)) *Paginator[Item] {
+	p := &Paginator[Item]{
+		Name:     name,
+		PageSize: pageSize,
+		Load:     load,
+		Format:   mapper.TelegramFormatMarkdown[Item],
+		router:   router,
+	}
+	Route(router, name, func(u *telemux.Update, params pageParams) {
+		answerCallback(u)
+		p.edit(u, params.Page)
+	})
+	Route(router, name+"_a", func(u *telemux.Update, params pageActionParams) {
+		answerCallback(u)
+		p.handleAction(u, params)
+	})
+	return p
+}
+
+// AddAction - кнопка действия text(item) у каждого элемента страницы. Требует Key.
+// После действия страница показывается заново: элемент мог измениться или исчезнуть
+func (p *Paginator[Item]) AddAction(text func(item Item) string, handle func(u *telemux.Update, item Item)) *Paginator[Item] {
+	p.Actions = append(p.Actions, PageAction[Item]{Text: text, Handle: handle})
+	return p
+}
+
+// Send - отправляет первую страницу новым сообщением
+func (p *Paginator[Item]) Send(u *telemux.Update, chatID int64) error {
+	text, markup, err := p.Render(u, chatID, 0)
+	if err != nil {
+		return err
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeMarkdown
+	if len(markup.InlineKeyboard) > 0 {
+		msg.ReplyMarkup = markup
+	}
+	_, err = u.Bot.Send(msg)
+	return err
+}
+
+// Render - текст и клавиатура страницы page. Номер страницы приводится к допустимому
+func (p *Paginator[Item]) Render(u *telemux.Update, chatID int64, page int) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	items, err := p.Load(u)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+	pages := p.pageCount(len(items))
+	page = max(0, min(page, pages-1))
+	pageItems := items[min(page*p.PageSize, len(items)):min((page+1)*p.PageSize, len(items))]
+
+	body, err := p.Format(pageItems)
+	if err != nil {
+		// mapper.TelegramFormatMarkdown возвращает ошибку «Нет данных.» для пустого списка
+		body = err.Error()
+	}
+	text := body
+	if len(p.Title) > 0 {
+		text = "*" + EscapeMarkdown(p.Title) + "*\n" + text
+	}
+	if pages > 1 {
+		text += "\n\nСтраница " + strconv.Itoa(page+1) + " из " + strconv.Itoa(pages) +
+			", всего " + strconv.Itoa(len(items))
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if p.Key != nil {
+		for _, item := range pageItems {
+			var row []tgbotapi.InlineKeyboardButton
+			for index, action := range p.Actions {
+				button, err := Callback(p.router, action.Text(item), p.Name+"_a",
+					pageActionParams{Action: index, Page: page, Key: p.Key(item)})
+				if err != nil {
+					return "", tgbotapi.InlineKeyboardMarkup{}, err
+				}
+				row = append(row, button)
+			}
+			if len(row) > 0 {
+				rows = append(rows, row)
+			}
+		}
+	}
+	if pages > 1 {
+		navigation := make([]tgbotapi.InlineKeyboardButton, 0, 3)
+		for _, step := range []struct {
+			text string
+			page int
+			show bool
+		}{
+			{"◀", page - 1, page > 0},
+			{strconv.Itoa(page+1) + "/" + strconv.Itoa(pages), page, true},
+			{"▶", page + 1, page < pages-1},
+		} {
+			if !step.show {
+				continue
+			}
+			button, err := Callback(p.router, step.text, p.Name, pageParams{Page: step.page})
+			if err != nil {
+				return "", tgbotapi.InlineKeyboardMarkup{}, err
+			}
+			navigation = append(navigation, button)
+		}
+		rows = append(rows, navigation)
+	}
+	return text, p.router.SignKeyboard(tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}, chatID, GetUserFromId(u)), nil
+}
+
+// edit - показывает страницу page в том же сообщении
+func (p *Paginator[Item]) edit(u *telemux.Update, page int) {
+	message := u.CallbackQuery.Message
+	text, markup, err := p.Render(u, message.Chat.ID, page)
+	if err != nil {
+		log.Println("paginator "+p.Name+":", err)
+		return
+	}
+	edit := tgbotapi.NewEditMessageTextAndMarkup(message.Chat.ID, message.MessageID, text, markup)
+	edit.ParseMode = tgbotapi.ModeMarkdown
+	if u.Bot != nil {
+		// «message is not modified» при нажатии на счетчик страниц не является ошибкой
+		_, _ = u.Bot.Request(edit)
+	}
+}
+
+func (p *Paginator[Item]) handleAction(u *telemux.Update, params pageActionParams) {
+	if params.Action < 0 || params.Action >= len(p.Actions) || p.Key == nil {
+		return
+	}
+	items, err := p.Load(u)
+	if err != nil {
+		log.Println("paginator "+p.Name+":", err)
+		return
+	}
+	for _, item := range items {
+		if p.Key(item) == params.Key {
+			p.Actions[params.Action].Handle(u, item)
+			p.edit(u, params.Page)
+			return
+		}
+	}
+}
+
+func (p *Paginator[Item]) pageCount(total int) int {
+	if p.PageSize <= 0 {
+		p.PageSize = 10
+	}
+	return max(1, (total+p.PageSize-1)/p.PageSize)
+}
+
+// EscapeMarkdown - экранирование для Markdown-разметки Telegram
+func EscapeMarkdown(text string) string {
+	return strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[").Replace(text)
+}
+
+// answerCallback - убирает индикатор загрузки с нажатой кнопки
+func answerCallback(u *telemux.Update) {
+	if u.Bot != nil && u.CallbackQuery != nil {
+		_, _ = u.Bot.Request(tgbotapi.NewCallback(u.CallbackQuery.ID, ""))
+	}
+}
@@ -0,0 +1,93 @@
+package telegram
+
+import (
+	"github.com/and3rson/telemux/v2"
+	"main/internal/database/keyvalue/memorykeyvalue"
+	"main/internal/entity"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPaginator(t *testing.T) {
+	var users []entity.User
+	for i := 1; i <= 25; i++ {
+		users = append(users, entity.User{ID: i, UserName: "user" + strconv.Itoa(i)})
+	}
+	router := NewCallbackRouter(memorykeyvalue.InitMemoryKeyValue[string]())
+	var banned entity.User
+	paginator := NewPaginator(router, "users", 10, func(u *telemux.Update) ([]entity.User, error) {
+		return users, nil
+	}).AddAction(func(user entity.User) string {
+		return "🚫 " + user.UserName
+	}, func(u *telemux.Update, user entity.User) {
+		banned = user
+	})
+	paginator.Key = func(user entity.User) string { return strconv.Itoa(user.ID) }
+	paginator.Title = "Пользователи"
+
+	text, markup, err := paginator.Render(&telemux.Update{}, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(text, "*Пользователи*") || !strings.Contains(text, "Страница 1 из 3, всего 25") ||
+		!strings.Contains(text, "*ID* - 10") || strings.Contains(text, "*ID* - 11") {
+		t.Errorf("неверная первая страница:\n%s", text)
+	}
+	navigation := markup.InlineKeyboard[len(markup.InlineKeyboard)-1]
+	if len(markup.InlineKeyboard) != 11 || len(navigation) != 2 || navigation[0].Text != "1/3" || navigation[1].Text != "▶" {
+		t.Errorf("неверная клавиатура первой страницы: %d строк, навигация %+v", len(markup.InlineKeyboard), navigation)
+	}
+
+	text, markup, _ = paginator.Render(&telemux.Update{}, 1, 7)
+	navigation = markup.InlineKeyboard[len(markup.InlineKeyboard)-1]
+	if !strings.Contains(text, "Страница 3 из 3") || len(markup.InlineKeyboard) != 6 || navigation[0].Text != "◀" {
+		t.Errorf("номер страницы должен ограничиваться последней:\n%s", text)
+	}
+
+	action := markup.InlineKeyboard[2][0]
+	if action.Text != "🚫 user23" {
+		t.Errorf("неверная кнопка действия: %s", action.Text)
+	}
+	if !router.Dispatch(callbackUpdate(*action.CallbackData)) || banned.ID != 23 {
+		t.Errorf("действие должно получить пользователя 23, получено %+v", banned)
+	}
+	if !router.Dispatch(callbackUpdate(*navigation[0].CallbackData)) {
+		t.Errorf("кнопка листания должна обрабатываться маршрутизатором")
+	}
+
+	users = nil
+	text, markup, _ = paginator.Render(&telemux.Update{}, 1, 0)
+	if !strings.Contains(text, "Нет данных.") || len(markup.InlineKeyboard) != 0 {
+		t.Errorf("пустой список: %s", text)
+	}
+}
+
+func TestPaginatorEscapesTitle(t *testing.T) {
+	router := NewCallbackRouter(memorykeyvalue.InitMemoryKeyValue[string]())
+	paginator := NewPaginator(router, "codes", 10, func(u *telemux.Update) ([]entity.PromoCode, error) {
+		return []entity.PromoCode{{ID: 1, Code: "SPRING"}}, nil
+	})
+	paginator.Title = "Промокоды *new_year*"
+	text, _, err := paginator.Render(&telemux.Update{}, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(text, "*Промокоды \\*new\\_year\\**\n") {
+		t.Errorf("заголовок должен экранироваться:\n%s", text)
+	}
+}
+
+func TestPaginatorDefaultFormatEscapes(t *testing.T) {
+	router := NewCallbackRouter(memorykeyvalue.InitMemoryKeyValue[string]())
+	paginator := NewPaginator(router, "codes", 10, func(u *telemux.Update) ([]entity.PromoCode, error) {
+		return []entity.PromoCode{{ID: 1, Code: "NEW_YEAR(2027)"}}, nil
+	})
+	text, _, err := paginator.Render(&telemux.Update{}, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text, "*Code* - NEW\\_YEAR(2027)") || !strings.Contains(text, "*ExpiresAt* - —") {
+		t.Errorf("значения должны экранироваться для Markdown:\n%s", text)
+	}
+}
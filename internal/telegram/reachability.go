@@ -0,0 +1,29 @@
+package telegram
+
+import (
+	"errors"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"main/internal/entity"
+	"net/http"
+	"strings"
+)
+
+// UnreachableReason - причина из entity.Unreachable*, если ошибка отправки означает,
+// что писать пользователю нельзя до его действия: повторять отправку бесполезно.
+// Пустая строка - ошибка другая
+func UnreachableReason(err error) string {
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusForbidden {
+		return ""
+	}
+	message := strings.ToLower(apiErr.Message)
+	switch {
+	case strings.Contains(message, "user is deactivated"):
+		return entity.UnreachableDeactivated
+	case strings.Contains(message, "bot was blocked by the user"):
+		return entity.UnreachableBlocked
+	case strings.Contains(message, "can't initiate conversation"):
+		return entity.UnreachableNotStarted
+	}
+	return ""
+}
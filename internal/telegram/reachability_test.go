@@ -0,0 +1,30 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"main/internal/entity"
+	"testing"
+)
+
+func TestUnreachableReason(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{&tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}, entity.UnreachableBlocked},
+		{&tgbotapi.Error{Code: 403, Message: "Forbidden: user is deactivated"}, entity.UnreachableDeactivated},
+		{&tgbotapi.Error{Code: 403, Message: "Forbidden: bot can't initiate conversation with a user"}, entity.UnreachableNotStarted},
+		{fmt.Errorf("send: %w", &tgbotapi.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}), entity.UnreachableBlocked},
+		{&tgbotapi.Error{Code: 403, Message: "Forbidden: bot is not a member of the channel chat"}, ""},
+		{&tgbotapi.Error{Code: 400, Message: "Bad Request: chat not found"}, ""},
+		{errors.New("bot was blocked by the user"), ""},
+		{nil, ""},
+	}
+	for _, c := range cases {
+		if got := UnreachableReason(c.err); got != c.want {
+			t.Errorf("%v: причина %q, ожидалась %q", c.err, got, c.want)
+		}
+	}
+}
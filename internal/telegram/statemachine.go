@@ -0,0 +1,316 @@
+package telegram
+
+import (
+	"errors"
+	"github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"log/slog"
+	"main/internal/database/keyvalue"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// State - шаг многошагового диалога. StateNone - диалог завершен
+type State string
+
+const StateNone State = ""
+
+// stateLockStripes - число блокировок диалогов: чаты делят их по ID, поэтому память
+// не растет с числом чатов, а разные чаты редко ждут друг друга
+const stateLockStripes = 64
+
+// sessionGrace - сколько хранилище держит сессию после тайм-аута, чтобы на запоздавший
+// ввод ответить TimeoutText, а не молча его проигнорировать
+const sessionGrace = 24 * time.Hour
+
+// Session - состояние диалога в чате, переживает перезапуск бота
+type Session struct {
+	ChatID    int64
+	State     State
+	Data      map[string]string
+	UpdatedAt time.Time
+}
+
+// StateHandler - обработчик ввода в состоянии: текста сообщения или значения кнопки.
+// Возвращает следующее состояние
+type StateHandler func(u *telemux.Update, session *Session, input string) State
+
+// EnterHandler - действие при переходе в состояние, например вопрос пользователю
+type EnterHandler func(u *telemux.Update, session *Session)
+
+// StateMachine - конечный автомат диалога поверх TelegramCommand:
+// команды входа, обработчики состояний, тайм-аут и /cancel
+type StateMachine struct {
+	Name    string
+	Timeout time.Duration
+	// Сообщения пользователю; пустая строка - не отправлять
+	CancelText  string
+	TimeoutText string
+
+	storage   keyvalue.KeyValue[Session]
+	callbacks *CallbackRouter
+	entries   []stateEntry
+	handlers  map[State]StateHandler
+	enters    map[State]EnterHandler
+	locks     [stateLockStripes]stateLock
+}
+
+// stateLock - блокировка диалогов части чатов. Обработчик, вызванный под блокировкой,
+// может снова войти в автомат с тем же обновлением, например вызвать Start
+type stateLock struct {
+	mutex sync.Mutex
+	owner atomic.Pointer[telemux.Update]
+	// generation - число входов под блокировкой: по нему видно, что обработчик
+	// сам перезапустил или отменил диалог и его результат сохранять не нужно
+	generation int
+}
+
+// stateInput - параметры кнопки диалога
+type stateInput struct {
+	Value string
+}
+
+type stateEntry struct {
+	command     string
+	description string
+	state       State
+}
+
+func NewStateMachine(name string, storage keyvalue.KeyValue[Session], timeout time.Duration) *StateMachine {
+	return &StateMachine{
+		Name:        name,
+		Timeout:     timeout,
+		CancelText:  "Действие отменено",
+		TimeoutText: "Время ожидания истекло, начните заново",
+		storage:     storage,
+		handlers:    make(map[State]StateHandler),
+		enters:      make(map[State]EnterHandler),
+	}
+}
+
+// Entry - команда /command начинает диалог с состояния state
+func (m *StateMachine) Entry(command, description string, state State) *StateMachine {
+	m.entries = append(m.entries, stateEntry{command: command, description: description, state: state})
+	return m
+}
+
+// On - обработчик ввода в состоянии state
+func (m *StateMachine) On(state State, handler StateHandler) *StateMachine {
+	m.handlers[state] = handler
+	return m
+}
+
+// OnEnter - действие при переходе в состояние state
+func (m *StateMachine) OnEnter(state State, handler EnterHandler) *StateMachine {
+	m.enters[state] = handler
+	return m
+}
+
+// UseCallbacks - кнопки диалога идут через маршрут Name роутера router,
+// поэтому работают после перезапуска бота и на другой реплике
+func (m *StateMachine) UseCallbacks(router *CallbackRouter) *StateMachine {
+	m.callbacks = router
+	Route(router, m.Name, func(u *telemux.Update, params stateInput) {
+		m.handle(u, params.Value)
+	})
+	return m
+}
+
+// Button - кнопка, нажатие которой передается обработчику текущего состояния как ввод value.
+// Требует UseCallbacks
+func (m *StateMachine) Button(text, value string) (tgbotapi.InlineKeyboardButton, error) {
+	if m.callbacks == nil {
+		return tgbotapi.InlineKeyboardButton{}, errors.New("state machine " + m.Name + ": callbacks are not configured")
+	}
+	return Callback(m.callbacks, text, m.Name, stateInput{Value: value})
+}
+
+// Commands - команды для регистрации в боте: /cancel, входы в диалог и обработка ввода.
+// Регистрировать до команд, реагирующих на произвольный текст
+func (m *StateMachine) Commands() TelegramCommands {
+	commands := TelegramCommands{
+		MakeFullCommand("cancel", "Отменить текущее действие",
+			func(u *telemux.Update) bool {
+				return FilterDefault(u, "cancel") && m.active(u)
+			},
+			SimpleActionStruct{SimpleAction: m.cancel}),
+	}
+	for _, entry := range m.entries {
+		commands = commands.AddCommand(MakeCommandByFilterDefault(entry.command, entry.description,
+			SimpleActionStruct{
+				SimpleAction: func(u *telemux.Update) {
+					m.Start(u, entry.state)
+				},
+			}))
+	}
+	return commands.AddCommand(MakeFullCommand(m.Name, "",
+		func(u *telemux.Update) bool {
+			return u.Message != nil && !strings.HasPrefix(u.Message.Text, "/") && m.active(u)
+		},
+		SimpleActionStruct{
+			SimpleAction: func(u *telemux.Update) {
+				m.handle(u, u.Message.Text)
+			},
+		}))
+}
+
+// Start - начинает диалог в чате с состояния state, прежний диалог сбрасывается
+func (m *StateMachine) Start(u *telemux.Update, state State) {
+	m.StartWith(u, state, nil)
+}
+
+// StartWith - Start с заранее заполненными данными диалога, например ID
+// редактируемой записи из нажатой кнопки
+func (m *StateMachine) StartWith(u *telemux.Update, state State, data map[string]string) {
+	chatID := chatIDFromUpdate(u)
+	defer m.lock(u, chatID)()
+	session := &Session{ChatID: chatID, Data: make(map[string]string, len(data))}
+	for key, value := range data {
+		session.Data[key] = value
+	}
+	m.transit(u, session, state)
+}
+
+// Session - текущее состояние диалога в чате; nil - диалога нет или он истек
+func (m *StateMachine) Session(chatID int64) (*Session, error) {
+	session, err := m.storage.Get(m.key(chatID))
+	if errors.Is(err, keyvalue.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if m.Timeout > 0 && time.Since(session.UpdatedAt) > m.Timeout {
+		return nil, nil
+	}
+	return session, nil
+}
+
+func (m *StateMachine) handle(u *telemux.Update, input string) {
+	chatID := chatIDFromUpdate(u)
+	defer m.lock(u, chatID)()
+	session, err := m.storage.Get(m.key(chatID))
+	if errors.Is(err, keyvalue.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		slog.Error("state machine session", "machine", m.Name, "chat_id", chatID, "error", err)
+		return
+	}
+	if m.Timeout > 0 && time.Since(session.UpdatedAt) > m.Timeout {
+		_ = m.storage.Delete(m.key(chatID))
+		m.notify(u, chatID, m.TimeoutText)
+		return
+	}
+	handler, ok := m.handlers[session.State]
+	if !ok {
+		slog.Error("state machine has no handler for state", "machine", m.Name, "state", session.State)
+		return
+	}
+	if session.Data == nil {
+		session.Data = make(map[string]string)
+	}
+	generation := m.stripe(chatID).generation
+	next := handler(u, session, input)
+	if m.stripe(chatID).generation != generation {
+		// обработчик сам начал или отменил диалог в этом чате
+		return
+	}
+	if next == session.State {
+		m.save(session)
+		return
+	}
+	m.transit(u, session, next)
+}
+
+// transit - переход в состояние state с вызовом его OnEnter
+func (m *StateMachine) transit(u *telemux.Update, session *Session, state State) {
+	if state != StateNone {
+		if _, ok := m.handlers[state]; !ok {
+			slog.Error("state machine unknown state", "machine", m.Name, "state", state)
+			state = StateNone
+		}
+	}
+	session.State = state
+	if enter, ok := m.enters[state]; ok {
+		generation := m.stripe(session.ChatID).generation
+		enter(u, session)
+		if m.stripe(session.ChatID).generation != generation {
+			return
+		}
+	}
+	if state == StateNone {
+		_ = m.storage.Delete(m.key(session.ChatID))
+		return
+	}
+	m.save(session)
+}
+
+// save - сохраняет сессию. Хранилище держит ее дольше тайм-аута, см. sessionGrace
+func (m *StateMachine) save(session *Session) {
+	session.UpdatedAt = time.Now()
+	var ttl time.Duration
+	if m.Timeout > 0 {
+		ttl = m.Timeout + sessionGrace
+	}
+	if err := m.storage.Set(m.key(session.ChatID), *session, ttl); err != nil {
+		slog.Error("state machine save", "machine", m.Name, "chat_id", session.ChatID, "error", err)
+	}
+}
+
+func (m *StateMachine) cancel(u *telemux.Update) {
+	chatID := chatIDFromUpdate(u)
+	defer m.lock(u, chatID)()
+	_ = m.storage.Delete(m.key(chatID))
+	m.notify(u, chatID, m.CancelText)
+}
+
+func (m *StateMachine) active(u *telemux.Update) bool {
+	chatID := chatIDFromUpdate(u)
+	if chatID == 0 {
+		return false
+	}
+	_, err := m.storage.Get(m.key(chatID))
+	return err == nil
+}
+
+func (m *StateMachine) notify(u *telemux.Update, chatID int64, text string) {
+	if len(text) > 0 && u.Bot != nil {
+		_, _ = u.Bot.Send(tgbotapi.NewMessage(chatID, text))
+	}
+}
+
+// lock - обновления одного чата обрабатываются по очереди. Повторный вход с тем же
+// обновлением из обработчика не ждет блокировки, которую уже держит
+func (m *StateMachine) lock(u *telemux.Update, chatID int64) func() {
+	stripe := m.stripe(chatID)
+	if stripe.owner.Load() == u {
+		stripe.generation++
+		return func() {}
+	}
+	stripe.mutex.Lock()
+	stripe.owner.Store(u)
+	stripe.generation++
+	return func() {
+		stripe.owner.Store(nil)
+		stripe.mutex.Unlock()
+	}
+}
+
+func (m *StateMachine) stripe(chatID int64) *stateLock {
+	return &m.locks[uint64(chatID)%stateLockStripes]
+}
+
+func (m *StateMachine) key(chatID int64) string {
+	return m.Name + ":" + strconv.FormatInt(chatID, 10)
+}
+
+func chatIDFromUpdate(u *telemux.Update) int64 {
+	if message := GetMessage(u); message != nil {
+		return message.Chat.ID
+	}
+	return 0
+}
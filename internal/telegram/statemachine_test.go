@@ -0,0 +1,239 @@
+package telegram
+
+import (
+	"github.com/and3rson/telemux/v2"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"main/internal/database/keyvalue"
+	"testing"
+	"time"
+)
+
+// memoryKeyValue - хранилище сессий в памяти, сериализация как в Redis не нужна
+type memoryKeyValue map[string]Session
+
+func (m memoryKeyValue) Set(key string, value Session, ttl time.Duration) error {
+	data := make(map[string]string, len(value.Data))
+	for k, v := range value.Data {
+		data[k] = v
+	}
+	value.Data = data
+	m[key] = value
+	return nil
+}
+
+func (m memoryKeyValue) Get(key string) (*Session, error) {
+	value, ok := m[key]
+	if !ok {
+		return nil, keyvalue.ErrNotFound
+	}
+	return &value, nil
+}
+
+func (m memoryKeyValue) Delete(key string) error {
+	delete(m, key)
+	return nil
+}
+
+const (
+	stateTariff  State = "tariff"
+	statePromo   State = "promo"
+	stateReceipt State = "receipt"
+)
+
+func buyMachine(storage memoryKeyValue) *StateMachine {
+	return NewStateMachine("buy", storage, time.Hour).
+		Entry("buy", "Купить подписку", stateTariff).
+		OnEnter(stateTariff, func(u *telemux.Update, session *Session) {
+			session.Data["asked"] = "tariff"
+		}).
+		On(stateTariff, func(u *telemux.Update, session *Session, input string) State {
+			session.Data["tariff"] = input
+			return statePromo
+		}).
+		On(statePromo, func(u *telemux.Update, session *Session, input string) State {
+			if input != "-" {
+				session.Data["promo"] = input
+			}
+			return stateReceipt
+		}).
+		On(stateReceipt, func(u *telemux.Update, session *Session, input string) State {
+			if input != "чек" {
+				return stateReceipt
+			}
+			return StateNone
+		})
+}
+
+func textUpdate(chatID int64, text string) *telemux.Update {
+	return &telemux.Update{Update: tgbotapi.Update{Message: &tgbotapi.Message{
+		Text: text,
+		Chat: &tgbotapi.Chat{ID: chatID},
+		From: &tgbotapi.User{ID: chatID},
+	}}}
+}
+
+// dispatch - как telemux.Mux: выполняется первая подходящая команда
+func dispatch(commands TelegramCommands, u *telemux.Update) bool {
+	for _, command := range commands {
+		if command.Filter(u) {
+			command.Action.Action(u)
+			return true
+		}
+	}
+	return false
+}
+
+func TestStateMachineFlow(t *testing.T) {
+	storage := make(memoryKeyValue)
+	machine := buyMachine(storage)
+	commands := machine.Commands()
+
+	if dispatch(commands, textUpdate(1, "Месяц")) {
+		t.Fatalf("без начатого диалога текст не должен обрабатываться")
+	}
+	dispatch(commands, textUpdate(1, "/buy"))
+	session, _ := machine.Session(1)
+	if session == nil || session.State != stateTariff || session.Data["asked"] != "tariff" {
+		t.Fatalf("диалог должен начаться с выбора тарифа: %+v", session)
+	}
+	dispatch(commands, textUpdate(1, "Месяц"))
+
+	// перезапуск: новый автомат поверх того же хранилища продолжает диалог
+	machine = buyMachine(storage)
+	commands = machine.Commands()
+	dispatch(commands, textUpdate(1, "LETO"))
+	session, _ = machine.Session(1)
+	if session.State != stateReceipt || session.Data["tariff"] != "Месяц" || session.Data["promo"] != "LETO" {
+		t.Fatalf("неверное состояние после перезапуска: %+v", session)
+	}
+	dispatch(commands, textUpdate(1, "не чек"))
+	if session, _ = machine.Session(1); session.State != stateReceipt {
+		t.Errorf("состояние не должно меняться: %+v", session)
+	}
+	dispatch(commands, textUpdate(1, "чек"))
+	if session, _ = machine.Session(1); session != nil || len(storage) != 0 {
+		t.Errorf("после завершения сессия должна удаляться: %+v", session)
+	}
+}
+
+func TestStateMachineCancelAndTimeout(t *testing.T) {
+	storage := make(memoryKeyValue)
+	machine := buyMachine(storage)
+	commands := machine.Commands()
+
+	if dispatch(commands, textUpdate(2, "/cancel")) {
+		t.Errorf("/cancel без диалога не должен перехватываться")
+	}
+	dispatch(commands, textUpdate(2, "/buy"))
+	dispatch(commands, textUpdate(2, "/cancel"))
+	if session, _ := machine.Session(2); session != nil {
+		t.Errorf("после /cancel сессии быть не должно: %+v", session)
+	}
+
+	dispatch(commands, textUpdate(2, "/buy"))
+	expired := storage[machine.key(2)]
+	expired.UpdatedAt = time.Now().Add(-2 * time.Hour)
+	storage[machine.key(2)] = expired
+	if session, _ := machine.Session(2); session != nil {
+		t.Errorf("истекшая сессия не должна возвращаться")
+	}
+	dispatch(commands, textUpdate(2, "Месяц"))
+	if _, ok := storage[machine.key(2)]; ok {
+		t.Errorf("истекшая сессия должна удаляться при вводе")
+	}
+}
+
+func TestStateMachineButton(t *testing.T) {
+	storage := make(memoryKeyValue)
+	machine := buyMachine(storage)
+	if _, err := machine.Button("Выбрать", "Месяц"); err == nil {
+		t.Errorf("кнопка без роутера должна давать ошибку")
+	}
+	machine.UseCallbacks(NewCallbackRouter(nil))
+	dispatch(machine.Commands(), textUpdate(3, "/buy"))
+
+	first, _ := machine.Button("Выбрать", "Месяц")
+	second, err := machine.Button("Выбрать", "Год")
+	if err != nil || *first.CallbackData == *second.CallbackData {
+		t.Fatalf("кнопки с одинаковым текстом должны различаться: %v", err)
+	}
+	// перезапуск: кнопка из старой клавиатуры обрабатывается новым автоматом
+	machine = buyMachine(storage)
+	router := NewCallbackRouter(nil)
+	machine.UseCallbacks(router)
+	update := callbackUpdate(*second.CallbackData)
+	update.CallbackQuery.Message = textUpdate(3, "").Message
+	if !router.Dispatch(update) {
+		t.Fatalf("нажатие кнопки диалога не обработано")
+	}
+	if session, _ := machine.Session(3); session.State != statePromo || session.Data["tariff"] != "Год" {
+		t.Errorf("нажатие кнопки должно передаваться как ввод: %+v", session)
+	}
+}
+
+func TestStateMachineReentry(t *testing.T) {
+	storage := make(memoryKeyValue)
+	machine := buyMachine(storage)
+	machine.On(statePromo, func(u *telemux.Update, session *Session, input string) State {
+		// начать диалог заново из обработчика того же чата
+		machine.Start(u, stateTariff)
+		return stateReceipt
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		machine.StartWith(textUpdate(5, ""), statePromo, nil)
+		dispatch(machine.Commands(), textUpdate(5, "LETO"))
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("повторный вход в автомат из обработчика не должен блокироваться")
+	}
+	if session, _ := machine.Session(5); session == nil || session.State != stateTariff {
+		t.Errorf("результат Start из обработчика не должен перезаписываться: %+v", session)
+	}
+}
+
+// ttlKeyValue - запоминает время жизни сохраненной сессии
+type ttlKeyValue struct {
+	memoryKeyValue
+	ttl time.Duration
+}
+
+func (m *ttlKeyValue) Set(key string, value Session, ttl time.Duration) error {
+	m.ttl = ttl
+	return m.memoryKeyValue.Set(key, value, ttl)
+}
+
+func TestStateMachineTimeoutText(t *testing.T) {
+	storage := &ttlKeyValue{memoryKeyValue: make(memoryKeyValue)}
+	machine := NewStateMachine("buy", storage, time.Hour).
+		On(stateTariff, func(u *telemux.Update, session *Session, input string) State { return stateTariff })
+	machine.Start(textUpdate(6, ""), stateTariff)
+	if storage.ttl <= machine.Timeout {
+		t.Errorf("сессия должна храниться дольше тайм-аута, чтобы ответить TimeoutText: %v", storage.ttl)
+	}
+	expired := storage.memoryKeyValue[machine.key(6)]
+	expired.UpdatedAt = time.Now().Add(-2 * time.Hour)
+	storage.memoryKeyValue[machine.key(6)] = expired
+	if !dispatch(machine.Commands(), textUpdate(6, "Месяц")) {
+		t.Errorf("ввод после тайм-аута должен обрабатываться, чтобы ответить TimeoutText")
+	}
+	if _, ok := storage.memoryKeyValue[machine.key(6)]; ok {
+		t.Errorf("истекшая сессия должна удаляться при вводе")
+	}
+}
+
+func TestStateMachineStartWith(t *testing.T) {
+	storage := make(memoryKeyValue)
+	machine := buyMachine(storage)
+	data := map[string]string{"tariff": "Год"}
+	machine.StartWith(textUpdate(4, ""), statePromo, data)
+	data["tariff"] = "Месяц"
+	dispatch(machine.Commands(), textUpdate(4, "-"))
+	session, _ := machine.Session(4)
+	if session == nil || session.State != stateReceipt || session.Data["tariff"] != "Год" {
+		t.Errorf("диалог должен продолжиться с переданными данными: %+v", session)
+	}
+}